@@ -0,0 +1,364 @@
+package analysis
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/dance"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"github.com/wieku/rplpa"
+	"math"
+	"sort"
+)
+
+const (
+	// Cursor velocity in osu!pixels per ms (real time) that's not achievable by hand
+	impossibleVelocity = 40.0
+
+	// Minimum distance in osu!pixels for a movement to be checked for impossible velocity
+	impossibleDistance = 100.0
+
+	// Minimum number of frames with identical movement to treat cursor movement as overly consistent
+	linearRunLength = 6
+
+	// Distance in osu!pixels after which a single frame movement followed by stillness is treated as a snap
+	snapDistance = 80.0
+
+	// Median frame time in ms below which the replay is probably timewarped
+	timewarpFrameTime = 13.0
+
+	// Standard deviation of key press durations in ms below which presses are considered inhumanly consistent
+	consistentPressDeviation = 3.0
+
+	durationBinSize  = 10.0
+	frameTimeBinSize = 2.0
+)
+
+type Key int
+
+const (
+	K1 = Key(iota)
+	K2
+	M1
+	M2
+)
+
+var keyNames = []string{"K1", "K2", "M1", "M2"}
+
+func (k Key) String() string {
+	return keyNames[k]
+}
+
+// Distribution summarizes a set of values, all times are in ms
+type Distribution struct {
+	Count     int     `json:"count"`
+	Mean      float64 `json:"mean"`
+	Deviation float64 `json:"deviation"`
+	Median    float64 `json:"median"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	BinSize   float64 `json:"binSize"`
+	Histogram []int   `json:"histogram"`
+}
+
+func newDistribution(values []float64, binSize float64) Distribution {
+	dist := Distribution{
+		Count:   len(values),
+		BinSize: binSize,
+	}
+
+	if len(values) == 0 {
+		return dist
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+
+	sort.Float64s(sorted)
+
+	dist.Min = sorted[0]
+	dist.Max = sorted[len(sorted)-1]
+
+	if l := len(sorted); l%2 == 0 {
+		dist.Median = (sorted[l/2] + sorted[l/2-1]) / 2
+	} else {
+		dist.Median = sorted[l/2]
+	}
+
+	for _, v := range sorted {
+		dist.Mean += v
+	}
+
+	dist.Mean /= float64(len(sorted))
+
+	for _, v := range sorted {
+		dist.Deviation += (v - dist.Mean) * (v - dist.Mean)
+	}
+
+	dist.Deviation = math.Sqrt(dist.Deviation / float64(len(sorted)))
+
+	dist.Histogram = make([]int, int(math.Max(0, dist.Max)/binSize)+1)
+
+	for _, v := range sorted {
+		dist.Histogram[int(math.Max(0, v)/binSize)]++
+	}
+
+	return dist
+}
+
+// Press is a single key press, times are in ms of map time
+type Press struct {
+	Key       Key
+	StartTime float64
+	EndTime   float64
+}
+
+// Frame is a replay frame with absolute time
+type Frame struct {
+	Time     float64
+	Position vector.Vector2f
+	Keys     [4]bool
+}
+
+type InputReport struct {
+	Player   string  `json:"player"`
+	MD5      string  `json:"md5"`
+	Mods     string  `json:"mods"`
+	Duration float64 `json:"duration"`
+
+	Frames    int          `json:"frames"`
+	FrameTime Distribution `json:"frameTime"`
+
+	PressDurations map[string]Distribution `json:"pressDurations"`
+	KeyPresses     map[string]int          `json:"keyPresses"`
+
+	// Ratio of consecutive presses done with different key
+	Alternation float64 `json:"alternation"`
+
+	// Ratio of presses done with the most used key
+	SingleKey float64 `json:"singleKey"`
+
+	ImpossibleFrames int `json:"impossibleFrames"`
+	SnapFrames       int `json:"snapFrames"`
+	LinearFrames     int `json:"linearFrames"`
+	LongestLinearRun int `json:"longestLinearRun"`
+
+	Warnings []string `json:"warnings"`
+
+	frames  []Frame
+	presses []Press
+	mods    difficulty.Modifier
+	diff    *difficulty.Difficulty
+}
+
+// ConvertFrames converts delta-timed replay data to frames with absolute times
+func ConvertFrames(replay *rplpa.Replay) []Frame {
+	rFrames := dance.CleanFrames(replay.ReplayData)
+
+	frames := make([]Frame, 0, len(rFrames))
+
+	time := 0.0
+
+	for _, f := range rFrames {
+		time += float64(f.Time)
+
+		frame := Frame{
+			Time:     time,
+			Position: vector.NewVec2f(f.MouseX, f.MouseY),
+		}
+
+		if f.KeyPressed != nil {
+			frame.Keys[K1] = f.KeyPressed.LeftClick && f.KeyPressed.Key1
+			frame.Keys[K2] = f.KeyPressed.RightClick && f.KeyPressed.Key2
+			frame.Keys[M1] = f.KeyPressed.LeftClick && !f.KeyPressed.Key1
+			frame.Keys[M2] = f.KeyPressed.RightClick && !f.KeyPressed.Key2
+		}
+
+		frames = append(frames, frame)
+	}
+
+	return frames
+}
+
+// AnalyzeInput creates input report of given replay
+func AnalyzeInput(replay *rplpa.Replay) *InputReport {
+	mods := difficulty.Modifier(replay.Mods)
+
+	report := &InputReport{
+		Player:         replay.Username,
+		MD5:            replay.BeatmapMD5,
+		Mods:           mods.String(),
+		PressDurations: make(map[string]Distribution),
+		KeyPresses:     make(map[string]int),
+		frames:         ConvertFrames(replay),
+		mods:           mods,
+		diff:           difficulty.NewDifficulty(5, 5, 5, 5),
+	}
+
+	report.diff.SetMods(mods)
+
+	report.Frames = len(report.frames)
+
+	if len(report.frames) < 2 {
+		report.Warnings = append(report.Warnings, "Replay is missing input data")
+		return report
+	}
+
+	report.Duration = report.frames[len(report.frames)-1].Time
+
+	report.analyzeFrameTimes()
+	report.analyzeKeys()
+	report.analyzeMovement()
+
+	return report
+}
+
+// realTime converts map time to real time
+func (report *InputReport) realTime(time float64) float64 {
+	return report.diff.GetModifiedTime(time)
+}
+
+func (report *InputReport) analyzeFrameTimes() {
+	frameTimes := make([]float64, 0, len(report.frames))
+
+	for i := 1; i < len(report.frames); i++ {
+		if delta := report.frames[i].Time - report.frames[i-1].Time; delta > 0 {
+			frameTimes = append(frameTimes, report.realTime(delta))
+		}
+	}
+
+	report.FrameTime = newDistribution(frameTimes, frameTimeBinSize)
+
+	if report.FrameTime.Median <= timewarpFrameTime && !report.mods.Active(difficulty.Autoplay|difficulty.Relax|difficulty.Relax2) {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("Median frame time is %.2fms, replay was probably timewarped", report.FrameTime.Median))
+	}
+}
+
+func (report *InputReport) analyzeKeys() {
+	var starts [4]float64
+	var last [4]bool
+
+	for _, f := range report.frames {
+		for k := K1; k <= M2; k++ {
+			if f.Keys[k] && !last[k] {
+				starts[k] = f.Time
+			} else if !f.Keys[k] && last[k] {
+				report.presses = append(report.presses, Press{Key: k, StartTime: starts[k], EndTime: f.Time})
+			}
+
+			last[k] = f.Keys[k]
+		}
+	}
+
+	sort.SliceStable(report.presses, func(i, j int) bool {
+		return report.presses[i].StartTime < report.presses[j].StartTime
+	})
+
+	var durations [4][]float64
+
+	for _, p := range report.presses {
+		durations[p.Key] = append(durations[p.Key], report.realTime(p.EndTime-p.StartTime))
+	}
+
+	mostUsed := 0
+
+	for k := K1; k <= M2; k++ {
+		if len(durations[k]) == 0 {
+			continue
+		}
+
+		dist := newDistribution(durations[k], durationBinSize)
+
+		report.PressDurations[k.String()] = dist
+		report.KeyPresses[k.String()] = len(durations[k])
+
+		if len(durations[k]) > mostUsed {
+			mostUsed = len(durations[k])
+		}
+
+		if dist.Count > 50 && dist.Deviation < consistentPressDeviation && !report.mods.Active(difficulty.Relax) {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s press durations are inhumanly consistent (%.2fms deviation)", k.String(), dist.Deviation))
+		}
+	}
+
+	if len(report.presses) == 0 {
+		return
+	}
+
+	report.SingleKey = float64(mostUsed) / float64(len(report.presses))
+
+	alternations := 0
+
+	for i := 1; i < len(report.presses); i++ {
+		if report.presses[i].Key != report.presses[i-1].Key {
+			alternations++
+		}
+	}
+
+	if len(report.presses) > 1 {
+		report.Alternation = float64(alternations) / float64(len(report.presses)-1)
+	}
+}
+
+func (report *InputReport) analyzeMovement() {
+	if report.mods.Active(difficulty.Autoplay | difficulty.Relax2) {
+		return
+	}
+
+	linearRun := 0
+
+	for i := 1; i < len(report.frames); i++ {
+		prev, cur := report.frames[i-1], report.frames[i]
+
+		delta := cur.Time - prev.Time
+		distance := float64(cur.Position.Dst(prev.Position))
+
+		if delta > 0 && distance > impossibleDistance && distance/report.realTime(delta) > impossibleVelocity {
+			report.ImpossibleFrames++
+		}
+
+		// Big movement in a single frame that's followed by a still cursor
+		if distance > snapDistance && i+1 < len(report.frames) && report.frames[i+1].Position == cur.Position {
+			report.SnapFrames++
+		}
+
+		if i < 2 || distance < 0.5 {
+			linearRun = 0
+			continue
+		}
+
+		prevMove := prev.Position.Sub(report.frames[i-2].Position)
+		move := cur.Position.Sub(prev.Position)
+
+		if prevMove.Sub(move).Len() < 0.01 && delta == prev.Time-report.frames[i-2].Time {
+			linearRun++
+
+			if linearRun == linearRunLength {
+				report.LinearFrames += linearRun
+			} else if linearRun > linearRunLength {
+				report.LinearFrames++
+			}
+
+			if linearRun > report.LongestLinearRun {
+				report.LongestLinearRun = linearRun
+			}
+		} else {
+			linearRun = 0
+		}
+	}
+
+	if report.ImpossibleFrames > 0 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%d frames with impossible cursor velocity", report.ImpossibleFrames))
+	}
+
+	if report.LongestLinearRun >= linearRunLength*3 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("Perfectly linear cursor movement over %d frames", report.LongestLinearRun))
+	}
+}
+
+func (report *InputReport) GetFrames() []Frame {
+	return report.frames
+}
+
+func (report *InputReport) GetPresses() []Press {
+	return report.presses
+}
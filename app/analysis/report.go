@@ -0,0 +1,225 @@
+package analysis
+
+import (
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/wieku/danser-go/app/dance"
+	"github.com/wieku/rplpa"
+	"log"
+	"os"
+	"strings"
+)
+
+// LoadReplay reads and parses replay file at given path
+func LoadReplay(path string) (*rplpa.Replay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	replay, err := rplpa.ParseReplay(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if replay.PlayMode != 0 {
+		return nil, fmt.Errorf("modes other than osu!standard are not supported")
+	}
+
+	return replay, nil
+}
+
+// AnalyzeReplays creates and logs input reports of given replays, comparing them if there's more than one
+func AnalyzeReplays(paths []string) []*InputReport {
+	reports := make([]*InputReport, 0, len(paths))
+
+	for _, path := range paths {
+		log.Println("Analysing:", path)
+
+		replay, err := LoadReplay(path)
+		if err != nil {
+			log.Println("Failed to load replay:", err)
+			continue
+		}
+
+		report := AnalyzeInput(replay)
+
+		LogInputReport(report)
+
+		reports = append(reports, report)
+	}
+
+	if len(reports) > 1 {
+		LogInputComparison(reports)
+	}
+
+	return reports
+}
+
+func LogInputReport(report *InputReport) {
+	log.Println(fmt.Sprintf("Input report for \"%s\" (%s), beatmap md5: %s", report.Player, report.Mods, report.MD5))
+	log.Println(fmt.Sprintf("\tFrames: %d, duration: %.0fms", report.Frames, report.Duration))
+	log.Println(fmt.Sprintf("\tFrame time: median %.2fms, mean %.2fms, deviation %.2fms", report.FrameTime.Median, report.FrameTime.Mean, report.FrameTime.Deviation))
+
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"Key", "Presses", "Mean", "Deviation", "Median", "Min", "Max"})
+
+	for k := K1; k <= M2; k++ {
+		dist, ok := report.PressDurations[k.String()]
+		if !ok {
+			continue
+		}
+
+		table.Append([]string{
+			k.String(),
+			fmt.Sprintf("%d", dist.Count),
+			fmt.Sprintf("%.2fms", dist.Mean),
+			fmt.Sprintf("%.2fms", dist.Deviation),
+			fmt.Sprintf("%.2fms", dist.Median),
+			fmt.Sprintf("%.0fms", dist.Min),
+			fmt.Sprintf("%.0fms", dist.Max),
+		})
+	}
+
+	table.Render()
+
+	for _, s := range strings.Split(strings.TrimSpace(tableString.String()), "\n") {
+		log.Println(s)
+	}
+
+	log.Println(fmt.Sprintf("\tAlternation: %.1f%%, most used key: %.1f%%", report.Alternation*100, report.SingleKey*100))
+	log.Println(fmt.Sprintf("\tImpossible velocity frames: %d, snap frames: %d", report.ImpossibleFrames, report.SnapFrames))
+	log.Println(fmt.Sprintf("\tLinear movement frames: %d, longest linear run: %d", report.LinearFrames, report.LongestLinearRun))
+
+	if len(report.Warnings) == 0 {
+		log.Println("\tNo anomalies found")
+		return
+	}
+
+	for _, w := range report.Warnings {
+		log.Println("\tWARNING:", w)
+	}
+}
+
+func LogInputComparison(reports []*InputReport) {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"#", "Player", "Mods", "Frame time", "Presses", "Press mean", "Press dev", "Alternation", "Impossible", "Snaps", "Linear", "Warnings"})
+
+	for i, r := range reports {
+		presses := 0
+		mean := 0.0
+		deviation := 0.0
+
+		for _, dist := range r.PressDurations {
+			presses += dist.Count
+			mean += dist.Mean * float64(dist.Count)
+			deviation += dist.Deviation * float64(dist.Count)
+		}
+
+		if presses > 0 {
+			mean /= float64(presses)
+			deviation /= float64(presses)
+		}
+
+		table.Append([]string{
+			fmt.Sprintf("%d", i+1),
+			r.Player,
+			r.Mods,
+			fmt.Sprintf("%.2fms", r.FrameTime.Median),
+			fmt.Sprintf("%d", presses),
+			fmt.Sprintf("%.2fms", mean),
+			fmt.Sprintf("%.2fms", deviation),
+			fmt.Sprintf("%.1f%%", r.Alternation*100),
+			fmt.Sprintf("%d", r.ImpossibleFrames),
+			fmt.Sprintf("%d", r.SnapFrames),
+			fmt.Sprintf("%d", r.LinearFrames),
+			fmt.Sprintf("%d", len(r.Warnings)),
+		})
+	}
+
+	table.Render()
+
+	log.Println("Replay comparison:")
+
+	for _, s := range strings.Split(strings.TrimSpace(tableString.String()), "\n") {
+		log.Println(s)
+	}
+}
+
+// FilterCandidates keeps candidates played on the map with given md5. If md5 is empty, map of the first candidate is used.
+func FilterCandidates(candidates []dance.Candidate, md5 string) []dance.Candidate {
+	if len(candidates) == 0 {
+		return candidates
+	}
+
+	if md5 == "" {
+		md5 = candidates[0].Replay.BeatmapMD5
+	}
+
+	filtered := make([]dance.Candidate, 0, len(candidates))
+
+	for _, c := range candidates {
+		if !strings.EqualFold(c.Replay.BeatmapMD5, md5) {
+			log.Println("Excluding replay of a different map:", c.Path)
+			continue
+		}
+
+		filtered = append(filtered, c)
+	}
+
+	return filtered
+}
+
+// CompareCandidates compares every pair of knockout candidates and logs them sorted by suspicion
+func CompareCandidates(candidates []dance.Candidate) []*Similarity {
+	reports := make([]*InputReport, 0, len(candidates))
+
+	for _, c := range candidates {
+		reports = append(reports, AnalyzeInput(c.Replay))
+	}
+
+	similarities := CompareAll(reports)
+
+	LogSimilarity(similarities)
+
+	return similarities
+}
+
+func LogSimilarity(similarities []*Similarity) {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"#", "Player A", "Player B", "Transform", "Offset", "Mean dist", "Median dist", "Close", "Press match", "Press dev", "Duration corr", "Suspicion"})
+
+	for i, s := range similarities {
+		table.Append([]string{
+			fmt.Sprintf("%d", i+1),
+			s.PlayerA,
+			s.PlayerB,
+			s.Transform.String(),
+			fmt.Sprintf("%.0fms", s.Offset),
+			fmt.Sprintf("%.2fpx", s.MeanDistance),
+			fmt.Sprintf("%.2fpx", s.MedianDistance),
+			fmt.Sprintf("%.1f%%", s.CloseRatio*100),
+			fmt.Sprintf("%.1f%%", s.PressMatch*100),
+			fmt.Sprintf("%.2fms", s.PressOffsetDeviation),
+			fmt.Sprintf("%.3f", s.DurationCorrelation),
+			fmt.Sprintf("%.1f", s.Suspicion),
+		})
+	}
+
+	table.Render()
+
+	log.Println("Replay similarity:")
+
+	for _, s := range strings.Split(strings.TrimSpace(tableString.String()), "\n") {
+		log.Println(s)
+	}
+
+	for _, s := range similarities {
+		if s.IsSuspicious() {
+			log.Println(fmt.Sprintf("WARNING: \"%s\" and \"%s\" are suspiciously similar (%.1f)", s.PlayerA, s.PlayerB, s.Suspicion))
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package analysis
+
+import (
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"math"
+	"sort"
+)
+
+const (
+	// Interval in ms of map time at which cursor paths are compared
+	similarityInterval = 10.0
+
+	// Maximum time shift in ms of map time checked when aligning two cursor paths
+	maxAlignOffset = 50.0
+	alignStep      = 5.0
+
+	// Distance in osu!pixels below which cursors are considered to be in the same place
+	closeDistance = 10.0
+
+	// Maximum difference in ms between key presses of two replays to be treated as the same press
+	pressMatchWindow = 50.0
+
+	// Suspicion above which the pair is reported as likely copied
+	suspiciousThreshold = 60.0
+)
+
+type Transform int
+
+const (
+	NoTransform = Transform(iota)
+	FlipX
+	FlipY
+	FlipXY
+)
+
+var transformNames = []string{"None", "Flip X", "Flip Y", "Flip XY"}
+
+func (t Transform) String() string {
+	return transformNames[t]
+}
+
+func (t Transform) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t Transform) apply(position vector.Vector2f) vector.Vector2f {
+	if t == FlipX || t == FlipXY {
+		position.X = 512 - position.X
+	}
+
+	if t == FlipY || t == FlipXY {
+		position.Y = 384 - position.Y
+	}
+
+	return position
+}
+
+// Similarity describes how alike cursor movement and key presses of two replays are
+type Similarity struct {
+	// Indexes of compared replays
+	A int `json:"a"`
+	B int `json:"b"`
+
+	PlayerA string `json:"playerA"`
+	PlayerB string `json:"playerB"`
+
+	// Transform applied to B's cursor path after HR normalization that made it closest to A's
+	Transform Transform `json:"transform"`
+
+	// Time shift in ms applied to B that aligned it best with A
+	Offset float64 `json:"offset"`
+
+	// Compared time span in ms
+	Overlap float64 `json:"overlap"`
+
+	MeanDistance   float64 `json:"meanDistance"`
+	MedianDistance float64 `json:"medianDistance"`
+
+	// Ratio of samples where cursors are closer than closeDistance
+	CloseRatio float64 `json:"closeRatio"`
+
+	// Ratio of key presses that have a counterpart in the other replay
+	PressMatch float64 `json:"pressMatch"`
+
+	PressOffsetMean      float64 `json:"pressOffsetMean"`
+	PressOffsetDeviation float64 `json:"pressOffsetDeviation"`
+
+	// Pearson correlation of durations of matched presses
+	DurationCorrelation float64 `json:"durationCorrelation"`
+
+	// Combined score from 0 to 100, higher means the replays are more likely copies of each other
+	Suspicion float64 `json:"suspicion"`
+
+	// Cursor distance sampled every DivergenceInterval ms starting at StartTime
+	StartTime          float64   `json:"startTime"`
+	DivergenceInterval float64   `json:"divergenceInterval"`
+	Divergence         []float64 `json:"divergence"`
+
+	// B's cursor path sampled like Divergence, with Offset and Transform applied, in normal (non-HR) playfield
+	AlignedPath []vector.Vector2f `json:"-"`
+}
+
+// CompareAll compares every pair of given replays, sorted from the most suspicious one
+func CompareAll(reports []*InputReport) []*Similarity {
+	similarities := make([]*Similarity, 0, len(reports)*(len(reports)-1)/2)
+
+	for i := 0; i < len(reports); i++ {
+		for j := i + 1; j < len(reports); j++ {
+			s := CompareReplays(reports[i], reports[j])
+			s.A, s.B = i, j
+
+			similarities = append(similarities, s)
+		}
+	}
+
+	sort.SliceStable(similarities, func(i, j int) bool {
+		return similarities[i].Suspicion > similarities[j].Suspicion
+	})
+
+	return similarities
+}
+
+// CompareReplays compares cursor paths and key presses of two replays of the same beatmap
+func CompareReplays(a, b *InputReport) *Similarity {
+	s := &Similarity{
+		B:                  1,
+		PlayerA:            a.Player,
+		PlayerB:            b.Player,
+		DivergenceInterval: similarityInterval,
+	}
+
+	startTime, endTime := getActiveSpan(a)
+	startB, endB := getActiveSpan(b)
+
+	startTime = math.Max(startTime, startB)
+	endTime = math.Min(endTime, endB)
+
+	count := int((endTime-startTime)/similarityInterval) + 1
+
+	if len(a.frames) < 2 || len(b.frames) < 2 || count < 2 {
+		return s
+	}
+
+	s.StartTime = startTime
+	s.Overlap = endTime - startTime
+
+	samplesA := samplePath(a, startTime, count, 0)
+
+	var bestSamples []vector.Vector2f
+
+	bestDistance := math.Inf(1)
+
+	for offset := -maxAlignOffset; offset <= maxAlignOffset; offset += alignStep {
+		samplesB := samplePath(b, startTime, count, offset)
+
+		for t := NoTransform; t <= FlipXY; t++ {
+			distance := 0.0
+
+			for i := range samplesA {
+				distance += float64(samplesA[i].Dst(t.apply(samplesB[i])))
+			}
+
+			if distance < bestDistance {
+				bestDistance = distance
+				bestSamples = samplesB
+
+				s.Offset = offset
+				s.Transform = t
+			}
+		}
+	}
+
+	s.Divergence = make([]float64, count)
+	s.AlignedPath = make([]vector.Vector2f, count)
+
+	closeSamples := 0
+
+	for i := range samplesA {
+		s.AlignedPath[i] = s.Transform.apply(bestSamples[i])
+		s.Divergence[i] = float64(samplesA[i].Dst(s.AlignedPath[i]))
+
+		if s.Divergence[i] < closeDistance {
+			closeSamples++
+		}
+	}
+
+	distances := newDistribution(s.Divergence, closeDistance)
+
+	s.MeanDistance = distances.Mean
+	s.MedianDistance = distances.Median
+	s.CloseRatio = float64(closeSamples) / float64(count)
+
+	s.comparePresses(a.presses, b.presses)
+
+	cursorScore := math.Exp(-s.MedianDistance / 8)
+	pressScore := s.PressMatch * math.Exp(-s.PressOffsetDeviation/5)
+
+	s.Suspicion = 100 * (0.5*cursorScore + 0.3*pressScore + 0.2*math.Max(0, s.DurationCorrelation))
+
+	return s
+}
+
+func (s *Similarity) comparePresses(pressesA, pressesB []Press) {
+	if len(pressesA) == 0 || len(pressesB) == 0 {
+		return
+	}
+
+	startsB := make([]float64, len(pressesB))
+	for i, p := range pressesB {
+		startsB[i] = p.StartTime - s.Offset
+	}
+
+	used := make([]bool, len(pressesB))
+
+	var offsets, durationsA, durationsB []float64
+
+	for _, p := range pressesA {
+		index := sort.SearchFloat64s(startsB, p.StartTime)
+
+		best := -1
+
+		for _, j := range []int{index - 1, index} {
+			if j < 0 || j >= len(startsB) || used[j] || math.Abs(startsB[j]-p.StartTime) > pressMatchWindow {
+				continue
+			}
+
+			if best == -1 || math.Abs(startsB[j]-p.StartTime) < math.Abs(startsB[best]-p.StartTime) {
+				best = j
+			}
+		}
+
+		if best == -1 {
+			continue
+		}
+
+		used[best] = true
+
+		offsets = append(offsets, startsB[best]-p.StartTime)
+		durationsA = append(durationsA, p.EndTime-p.StartTime)
+		durationsB = append(durationsB, pressesB[best].EndTime-pressesB[best].StartTime)
+	}
+
+	s.PressMatch = float64(len(offsets)) / math.Max(float64(len(pressesA)), float64(len(pressesB)))
+
+	if len(offsets) == 0 {
+		return
+	}
+
+	dist := newDistribution(offsets, pressMatchWindow)
+
+	s.PressOffsetMean = dist.Mean
+	s.PressOffsetDeviation = dist.Deviation
+
+	if len(offsets) >= 3 {
+		s.DurationCorrelation = correlation(durationsA, durationsB)
+	}
+}
+
+// GetDivergenceAt returns cursor distance at given time in ms of map time, NaN if time is outside compared span
+func (s *Similarity) GetDivergenceAt(time float64) float64 {
+	index := int((time - s.StartTime) / s.DivergenceInterval)
+
+	if time < s.StartTime || index >= len(s.Divergence) {
+		return math.NaN()
+	}
+
+	return s.Divergence[index]
+}
+
+// GetAlignedPositionAt returns aligned position of B's cursor at given time in ms of map time, false if time is outside compared span
+func (s *Similarity) GetAlignedPositionAt(time float64) (vector.Vector2f, bool) {
+	progress := (time - s.StartTime) / s.DivergenceInterval
+	index := int(progress)
+
+	if time < s.StartTime || index >= len(s.AlignedPath) {
+		return vector.Vector2f{}, false
+	}
+
+	if index == len(s.AlignedPath)-1 {
+		return s.AlignedPath[index], true
+	}
+
+	return s.AlignedPath[index].Lerp(s.AlignedPath[index+1], float32(progress-float64(index))), true
+}
+
+// IsSuspicious returns true if suspicion is high enough to treat the pair as likely copied
+func (s *Similarity) IsSuspicious() bool {
+	return s.Suspicion >= suspiciousThreshold
+}
+
+// getActiveSpan returns time span between first and last press, or the whole replay if there are no presses
+func getActiveSpan(report *InputReport) (float64, float64) {
+	if len(report.presses) > 0 {
+		endTime := 0.0
+		for _, p := range report.presses {
+			endTime = math.Max(endTime, p.EndTime)
+		}
+
+		return report.presses[0].StartTime, endTime
+	}
+
+	if len(report.frames) == 0 {
+		return 0, 0
+	}
+
+	return report.frames[0].Time, report.frames[len(report.frames)-1].Time
+}
+
+// samplePath samples cursor positions every similarityInterval ms, positions of HR replays are flipped back to normal playfield
+func samplePath(report *InputReport, startTime float64, count int, offset float64) []vector.Vector2f {
+	samples := make([]vector.Vector2f, count)
+
+	hr := report.mods.Active(difficulty.HardRock)
+
+	index := 0
+
+	for i := range samples {
+		time := startTime + float64(i)*similarityInterval + offset
+
+		for index < len(report.frames)-1 && report.frames[index+1].Time <= time {
+			index++
+		}
+
+		position := report.frames[index].Position
+
+		if index < len(report.frames)-1 && time > report.frames[index].Time {
+			f1, f2 := report.frames[index], report.frames[index+1]
+
+			position = f1.Position.Lerp(f2.Position, float32((time-f1.Time)/(f2.Time-f1.Time)))
+		}
+
+		if hr {
+			position.Y = 384 - position.Y
+		}
+
+		samples[i] = position
+	}
+
+	return samples
+}
+
+func correlation(x, y []float64) float64 {
+	meanX, meanY := 0.0, 0.0
+
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+
+	meanX /= float64(len(x))
+	meanY /= float64(len(y))
+
+	cov, varX, varY := 0.0, 0.0, 0.0
+
+	for i := range x {
+		cov += (x[i] - meanX) * (y[i] - meanY)
+		varX += (x[i] - meanX) * (x[i] - meanX)
+		varY += (y[i] - meanY) * (y[i] - meanY)
+	}
+
+	if varX == 0 || varY == 0 {
+		return 0
+	}
+
+	return cov / math.Sqrt(varX*varY)
+}
@@ -12,10 +12,12 @@ import (
 	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/host"
 	"github.com/shirou/gopsutil/mem"
+	"github.com/wieku/danser-go/app/analysis"
 	"github.com/wieku/danser-go/app/audio"
 	"github.com/wieku/danser-go/app/beatmap"
 	difficulty2 "github.com/wieku/danser-go/app/beatmap/difficulty"
 	camera2 "github.com/wieku/danser-go/app/bmath/camera"
+	"github.com/wieku/danser-go/app/dance"
 	"github.com/wieku/danser-go/app/database"
 	"github.com/wieku/danser-go/app/discord"
 	"github.com/wieku/danser-go/app/ffmpeg"
@@ -62,6 +64,9 @@ const (
 	shorthand      = " (shorthand)"
 )
 
+// Seed of visual randomness in recordings and screenshots, so the same map renders the same frames every time
+const renderSeed = 727
+
 var player states.State
 
 var scheduleScreenshot = false
@@ -82,6 +87,14 @@ var screenshotTime float64
 
 var preciseProgress bool
 
+var resumeRecording bool
+
+// Address of the render server, empty if danser isn't running in serve mode
+var serverAddress string
+
+// Index and number of parts if this process records only a part of the video for the coordinating process
+var partIndex, partCount int
+
 var monitorHz int
 
 func run() {
@@ -114,7 +127,7 @@ func run() {
 		tag := flag.Int("tag", 1, "How many cursors should be \"playing\" specific map. 2 means that 1st cursor clicks the 1st object, 2nd clicks 2nd object, 1st clicks 3rd and so on")
 
 		knockout := flag.Bool("knockout", false, "Use (classic) knockout feature. Replays are sourced from \"replays/{a}\" where {a} is an md5 hash of .osu file. Danser automatically organizes replay files put directly in \"replays\", using maps' md5s provided by the replay files.")
-		knockout2 := flag.String("knockout2", "", "Use (new) knockout feature, JSON list of paths to compatible replay files has to be provided. \"Knockout.ExcludeMods\" and \"Knockout.MaxPlayers\" options are ignored, they have to be filtered beforehand.")
+		knockout2 := flag.String("knockout2", "", "Use (new) knockout feature, JSON list of paths to compatible replay files has to be provided. Entries can also be objects like {\"path\": \"...\", \"team\": \"Red\"} to assign teams. \"Knockout.ExcludeMods\" and \"Knockout.MaxPlayers\" options are ignored, they have to be filtered beforehand.")
 
 		speed := flag.Float64("speed", 1.0, "Specify music's speed, set to 1.5 to have DoubleTime mod experience")
 		pitch := flag.Float64("pitch", 1.0, "Specify music's pitch, set to 1.5 with -speed=1.5 to have Nightcore mod experience")
@@ -123,6 +136,8 @@ func run() {
 		gldebug := flag.Bool("gldebug", false, "Turns on OpenGL debug logging, may reduce performance heavily")
 
 		play := flag.Bool("play", false, "Practice playing osu!standard maps")
+		practice := flag.Bool("practice", false, "Loop the section selected by -start/-end or -objects in -play mode, restarting automatically after every attempt. Loop bounds can be changed live with Input.LoopStartKey and Input.LoopEndKey")
+		objectRange := flag.String("objects", "", "Select section by object numbers instead of time, -objects=10-50 means objects from 10th to 50th. Overrides -start and -end flags")
 		start := flag.Float64("start", 0, "Start at the given time in seconds")
 		end := flag.Float64("end", math.Inf(1), "End at the given time in seconds")
 
@@ -132,6 +147,21 @@ func run() {
 
 		record := flag.Bool("record", false, "Records a video")
 		out := flag.String("out", "", "If -ss flag is used, sets the name of screenshot, extension is PNG. If not, it overrides -record flag, specifies the name of recorded video file, extension is managed by settings")
+
+		workers := flag.Int("workers", 0, "Split recording between given number of danser processes running in parallel and join their videos at the end. Every process plays only its part of the map with a few seconds of lead-in, except the first one, which also records audio of the whole map")
+		jobFile := flag.String("batch", "", "Record all jobs from a JSON job file one after another, reusing loaded beatmaps and skins. Every job is an object with optional fields: output, id, md5, artist, title, difficulty, creator, replay, replays, knockout, mods, skin, settings, start and end. Flags like -settings, -skin or -mods are used as defaults. Result of every job is saved next to the video as {output}.status.json")
+
+		playlistFile := flag.String("playlist", "", "Record maps from a JSON playlist file back to back into one video. The file is an object with fields: output, transition (\"card\", \"fade\" or \"none\"), transitionDuration (in seconds), finalStandings and maps. Every map has the same format as a -batch job. Card transition shows next map's metadata and standings summed over previous maps")
+
+		listen := flag.String("listen", "127.0.0.1:8080", "Address of the HTTP API used by \"danser serve\" mode. Jobs are submitted to /jobs, their progress is available at /jobs/{id}, cancelled with /jobs/{id}/cancel and finished videos are downloaded from /jobs/{id}/download")
+
+		headless := flag.Bool("headless", false, "Render without a window using an EGL context, for servers without a display. Works only on Linux in record and screenshot modes")
+		software := flag.Bool("software", false, "Render on CPU with Mesa's llvmpipe instead of a GPU, implies -headless. Needs Mesa's EGL and DRI drivers (libegl1 and libgl1-mesa-dri on Debian/Ubuntu). Rasterizer uses all CPU cores unless LP_NUM_THREADS environment variable is set")
+		lowCost := flag.Bool("lowcost", false, "Temporarily disable costly effects: bloom, background blur, motion blur, MSAA and cursor trail glow. Recommended with -software")
+
+		part := flag.String("part", "", "Used internally by -workers, -part=1/4 records only the 2nd of 4 parts of the video")
+
+		flag.BoolVar(&resumeRecording, "resume", false, "Continue interrupted recording with the same -out name from the last finished segment. Requires Recording.SegmentLength to be higher than 0")
 		ss := flag.Float64("ss", math.NaN(), "Screenshot mode. Snap single frame from danser at given time in seconds. Specify the name of file by -out, resolution is managed by Recording settings")
 
 		mods := flag.String("mods", "", "Specify beatmap/play mods")
@@ -153,14 +183,28 @@ func run() {
 
 		flag.BoolVar(&preciseProgress, "preciseprogress", false, "Show rendering progress in 1% increments")
 
-		flag.Parse()
+		analyze := flag.String("analyze", "", "Analyse input data of replays and print a report, path to .osr file or JSON list of paths has to be provided. Multiple replays are compared with each other.")
+
+		similarity := flag.String("similarity", "", "Compare cursor paths and key presses of every pair of knockout replays, sourced from -knockout2 list or from \"replays/{md5}\" with -md5, and print them sorted by suspicion. \"report\" only prints the report, \"render\" also plays back the most suspicious pair with a cursor divergence graph.")
+
+		serve := len(os.Args) > 1 && os.Args[1] == "serve"
+
+		if serve {
+			_ = flag.CommandLine.Parse(os.Args[2:])
+		} else {
+			flag.Parse()
+		}
+
+		if *analyze != "" {
+			analysis.AnalyzeReplays(parsePathList(*analyze))
+			os.Exit(0)
+		}
 
 		var knockoutReplays []string
+		var knockoutTeams map[string]string
 
 		if *knockout2 != "" {
-			if err := json.Unmarshal([]byte(*knockout2), &knockoutReplays); err != nil {
-				panic(fmt.Sprintf("Failed to parse replay list: %s", err))
-			}
+			knockoutReplays, knockoutTeams = parseKnockoutReplays(*knockout2)
 
 			*knockout = true
 		}
@@ -176,6 +220,28 @@ func run() {
 			}
 		}
 
+		if *jobFile != "" {
+			batchJobs = loadBatchJobs(*jobFile)
+		}
+
+		if *playlistFile != "" {
+			playlist = loadPlaylist(*playlistFile)
+
+			if *out != "" {
+				playlist.Output = *out
+			}
+		}
+
+		batchMode = *jobFile != "" || serve || playlist != nil
+
+		if batchMode {
+			*record = true
+		}
+
+		if *software {
+			*headless = true
+		}
+
 		recordMode = *record
 		screenshotMode = !math.IsNaN(*ss)
 		screenshotTime = *ss
@@ -192,28 +258,34 @@ func run() {
 			panic("Incompatible flags selected: -ss, -play")
 		} else if screenshotMode && recordMode {
 			panic("Incompatible flags selected: -ss, -record")
+		} else if *jobFile != "" && (*play || screenshotMode || *out != "" || *workers > 1 || *part != "" || *replay != "" || *knockout) {
+			panic("Incompatible flags selected: -batch, -play/-ss/-out/-workers/-part/-replay/-knockout")
+		} else if *playlistFile != "" && (*jobFile != "" || serve || *play || screenshotMode || *workers > 1 || *part != "" || resumeRecording || *replay != "" || *knockout) {
+			panic("Incompatible flags selected: -playlist, -batch/serve/-play/-ss/-workers/-part/-resume/-replay/-knockout")
+		} else if serve && (*jobFile != "" || *play || screenshotMode || *out != "" || *workers > 1 || *part != "" || *replay != "" || *knockout) {
+			panic("Incompatible flags selected: serve, -batch/-play/-ss/-out/-workers/-part/-replay/-knockout")
+		} else if *workers > 1 && !recordMode {
+			panic("Flag -workers requires -record or -out")
+		} else if *workers > 1 && (resumeRecording || *part != "") {
+			panic("Incompatible flags selected: -workers, -resume/-part")
+		} else if resumeRecording && *out == "" {
+			panic("Flag -resume requires -out")
+		} else if *headless && !recordMode && !screenshotMode {
+			panic("Flag -headless requires -record, -out or -ss")
+		} else if *practice && !*play {
+			panic("Flag -practice requires -play")
+		} else if *similarity != "" && *similarity != "report" && *similarity != "render" {
+			panic(fmt.Sprintf("flag -similarity: unknown mode \"%s\"", *similarity))
+		} else if *similarity != "" && (*play || *replay != "") {
+			panic("Incompatible flags selected: -similarity, -play/-replay")
+		} else if *similarity != "" && *md5 == "" && *knockout2 == "" {
+			panic("Flag -similarity requires -md5 or -knockout2")
 		}
 
 		modsParsed := difficulty2.ParseMods(*mods)
 
 		if *replay != "" {
-			bytes, err := ioutil.ReadFile(*replay)
-			if err != nil {
-				panic(err)
-			}
-
-			rp, err := rplpa.ParseReplay(bytes)
-			if err != nil {
-				panic(err)
-			}
-
-			if rp.PlayMode != 0 {
-				panic("Modes other than osu!standard are not supported")
-			}
-
-			if rp.ReplayData == nil || len(rp.ReplayData) < 2 {
-				panic("Replay is missing input data")
-			}
+			rp := loadReplay(*replay)
 
 			*md5 = rp.BeatmapMD5
 			*id = -1
@@ -228,7 +300,7 @@ func run() {
 
 		closeAfterSettingsLoad := false
 
-		if (*md5+*artist+*title+*difficulty+*creator) == "" && *id < 0 {
+		if (*md5+*artist+*title+*difficulty+*creator) == "" && *id < 0 && !batchMode {
 			log.Println("No beatmap specified, closing...")
 			closeAfterSettingsLoad = true
 		}
@@ -236,7 +308,9 @@ func run() {
 		settings.DEBUG = *debug
 		settings.KNOCKOUT = *knockout
 		settings.KNOCKOUTREPLAYS = knockoutReplays
+		settings.KNOCKOUTTEAMS = knockoutTeams
 		settings.PLAY = *play
+		settings.PRACTICE = *practice
 		settings.DIVIDES = *cursors
 		settings.TAG = *tag
 		settings.SPEED = *speed
@@ -258,6 +332,46 @@ func run() {
 			closeAfterSettingsLoad = true
 		}
 
+		if *part != "" {
+			if _, err := fmt.Sscanf(*part, "%d/%d", &partIndex, &partCount); err != nil || partIndex < 0 || partIndex >= partCount {
+				panic(fmt.Sprintf("flag -part: invalid value \"%s\"", *part))
+			}
+		}
+
+		if *workers > 1 {
+			runWorkers(*workers, *out)
+			os.Exit(0)
+		}
+
+		if *similarity != "" {
+			dance.OrganizeReplays()
+
+			candidates := analysis.FilterCandidates(dance.GetCandidates(strings.ToLower(*md5)), *md5)
+			if len(candidates) < 2 {
+				log.Println("At least two replays are needed for comparison, closing...")
+				os.Exit(0)
+			}
+
+			similarities := analysis.CompareCandidates(candidates)
+
+			if *similarity == "report" {
+				os.Exit(0)
+			}
+
+			a, b := candidates[similarities[0].A], candidates[similarities[0].B]
+
+			*md5 = a.Replay.BeatmapMD5
+			*id = -1
+
+			settings.KNOCKOUT = true
+			settings.KNOCKOUTREPLAYS = []string{a.Path, b.Path}
+			settings.SIMILARITY = true
+
+			states.Similarity = similarities[0]
+
+			closeAfterSettingsLoad = false
+		}
+
 		player = nil
 		var beatMap *beatmap.BeatMap = nil
 
@@ -268,51 +382,19 @@ func run() {
 			} else {
 				beatmaps := database.LoadBeatmaps(*noDbCheck, nil)
 
-				if *id > -1 {
-					for _, b := range beatmaps {
-						if b.ID == *id {
-							beatMap = b
-
-							break
-						}
-					}
-				} else if *md5 != "" {
-					for _, b := range beatmaps {
-						if strings.EqualFold(b.MD5, *md5) {
-							beatMap = b
-
-							break
-						}
-					}
+				if batchMode {
+					batchBeatmaps = beatmaps
 				} else {
-					for _, b := range beatmaps {
-						if (*artist == "" || strings.EqualFold(*artist, b.Artist)) &&
-							(*title == "" || strings.EqualFold(*title, b.Name)) &&
-							(*difficulty == "" || strings.EqualFold(*difficulty, b.Difficulty)) &&
-							(*creator == "" || strings.EqualFold(*creator, b.Creator)) {
-							beatMap = b
-
-							break
-						}
-					}
-
-					if beatMap == nil {
-						log.Println("Beatmap with exact parameters not found, searching partially...")
-						for _, b := range beatmaps {
-							if (*artist == "" || strings.Contains(strings.ToLower(b.Artist), strings.ToLower(*artist))) &&
-								(*title == "" || strings.Contains(strings.ToLower(b.Name), strings.ToLower(*title))) &&
-								(*difficulty == "" || strings.Contains(strings.ToLower(b.Difficulty), strings.ToLower(*difficulty))) &&
-								(*creator == "" || strings.Contains(strings.ToLower(b.Creator), strings.ToLower(*creator))) {
-								beatMap = b
-
-								break
-							}
-						}
-					}
+					beatMap = findBeatmap(beatmaps, *id, *md5, *artist, *title, *difficulty, *creator)
 				}
 			}
 
-			if beatMap == nil {
+			if batchMode {
+				if len(batchBeatmaps) == 0 {
+					log.Println("No beatmaps found, closing...")
+					closeAfterSettingsLoad = true
+				}
+			} else if beatMap == nil {
 				log.Println("Beatmap not found, closing...")
 				closeAfterSettingsLoad = true
 			} else {
@@ -320,30 +402,40 @@ func run() {
 				database.UpdatePlayStats(beatMap)
 			}
 
-			database.Close()
+			// Render server keeps its job queue in the database
+			if !serve || closeAfterSettingsLoad {
+				database.Close()
+			}
 		}
 
 		assets.Init(build.Stream == "Dev")
 
-		if !closeAfterSettingsLoad {
-			log.Println("Initializing GLFW...")
-		}
+		var monitor *glfw.Monitor
 
-		err := glfw.Init()
-		if err != nil {
-			panic("Failed to initialize GLFW: " + err.Error())
-		}
+		mWidth, mHeight := 1920, 1080
+		monitorHz = 60
+
+		if !*headless {
+			if !closeAfterSettingsLoad {
+				log.Println("Initializing GLFW...")
+			}
 
-		platform.SetupContext()
+			err := glfw.Init()
+			if err != nil {
+				panic("Failed to initialize GLFW: " + err.Error())
+			}
 
-		glfw.WindowHint(glfw.Resizable, glfw.False)
-		glfw.WindowHint(glfw.Samples, 0)
-		glfw.WindowHint(glfw.Visible, glfw.False)
+			platform.SetupContext()
 
-		monitor := glfw.GetPrimaryMonitor()
-		mWidth, mHeight := monitor.GetVideoMode().Width, monitor.GetVideoMode().Height
+			glfw.WindowHint(glfw.Resizable, glfw.False)
+			glfw.WindowHint(glfw.Samples, 0)
+			glfw.WindowHint(glfw.Visible, glfw.False)
 
-		monitorHz = monitor.GetVideoMode().RefreshRate
+			monitor = glfw.GetPrimaryMonitor()
+			mWidth, mHeight = monitor.GetVideoMode().Width, monitor.GetVideoMode().Height
+
+			monitorHz = monitor.GetVideoMode().RefreshRate
+		}
 
 		if newSettings {
 			settings.Graphics.SetDefaults(int64(mWidth), int64(mHeight))
@@ -377,14 +469,11 @@ func run() {
 		}
 
 		if settings.RECORD {
-			//HACK: some in-app variables depend on these settings so we force them here
-			settings.Graphics.VSync = false
-			settings.Graphics.ShowFPS = false
-			settings.DEBUG = false
-			settings.Graphics.Fullscreen = false
-			settings.Graphics.WindowWidth = int64(settings.Recording.FrameWidth)
-			settings.Graphics.WindowHeight = int64(settings.Recording.FrameHeight)
-			settings.Playfield.LeadInTime = 0
+			applyRecordSettings()
+		}
+
+		if *lowCost {
+			applyLowCostSettings()
 		}
 
 		if screenshotMode {
@@ -393,42 +482,13 @@ func run() {
 			settings.SKIP = false
 		}
 
-		if settings.Graphics.Fullscreen {
-			glfw.WindowHint(glfw.RedBits, monitor.GetVideoMode().RedBits)
-			glfw.WindowHint(glfw.GreenBits, monitor.GetVideoMode().GreenBits)
-			glfw.WindowHint(glfw.BlueBits, monitor.GetVideoMode().BlueBits)
-			glfw.WindowHint(glfw.RefreshRate, monitor.GetVideoMode().RefreshRate)
-			//glfw.WindowHint(glfw.Decorated, glfw.False)
-			win, err = glfw.CreateWindow(int(settings.Graphics.Width), int(settings.Graphics.Height), "danser", monitor, nil)
-		} else {
-			win, err = glfw.CreateWindow(int(settings.Graphics.WindowWidth), int(settings.Graphics.WindowHeight), "danser", nil, nil)
-		}
-
-		if err != nil {
-			panic(err)
-		}
-
-		if !*record {
-			win.SetFocusCallback(func(w *glfw.Window, focused bool) {
-				log.Println("Focus changed: ", focused)
-				input.Focused = focused
-			})
-		}
-
-		win.SetTitle("danser " + build.VERSION + " - " + beatMap.Artist + " - " + beatMap.Name + " [" + beatMap.Difficulty + "]")
-		input.Win = win
-
-		if cTime := time.Now(); cTime.Month() == 12 && cTime.Day() >= 6 {
-			platform.LoadIcons(win, "dansercoin", "-s")
+		if *headless {
+			initHeadless(*software)
 		} else {
-			platform.LoadIcons(win, "dansercoin", "")
+			initWindow(monitor, beatMap)
 		}
 
-		win.MakeContextCurrent()
-
-		log.Println("GLFW initialized!")
-
-		err = platform.GLInit(*gldebug)
+		err := platform.GLInit(*gldebug)
 		if err != nil {
 			panic("Failed to initialize OpenGL: " + err.Error())
 		}
@@ -458,28 +518,30 @@ func run() {
 		font.GetFont("Quicksand Bold").Draw(batch, 0, settings.Graphics.GetHeightF()-10, 32, "Loading...")
 
 		batch.End()
-		win.SwapBuffers()
 
-		glfw.SwapInterval(1)
-		lastVSync = true
+		if !*headless {
+			win.SwapBuffers()
+
+			glfw.SwapInterval(1)
+			lastVSync = true
+		}
 
 		bass.Init(settings.RECORD)
 		audio.LoadSamples()
 
-		speedBefore := settings.SPEED
+		if batchMode {
+			if serve {
+				serverAddress = *listen
+			}
 
-		if modsParsed.Active(difficulty2.Nightcore) {
-			settings.SPEED *= 1.5
-			settings.PITCH *= 1.5
-		} else if modsParsed.Active(difficulty2.DoubleTime) {
-			settings.SPEED *= 1.5
-		} else if modsParsed.Active(difficulty2.Daycore) {
-			settings.PITCH *= 0.75
-			settings.SPEED *= 0.75
-		} else if modsParsed.Active(difficulty2.HalfTime) {
-			settings.SPEED *= 0.75
+			captureBatchDefaults(*settingsVersion, *skin, *mods, *lowCost)
+			return
 		}
 
+		speedBefore := settings.SPEED
+
+		applyModSpeed(modsParsed)
+
 		if settings.PLAY || !settings.KNOCKOUT || allowDA {
 			if !math.IsNaN(*ar) {
 				beatMap.Diff.SetARCustom(*ar)
@@ -504,12 +566,31 @@ func run() {
 		beatmap.ParseTimingPointsAndPauses(beatMap)
 		beatmap.ParseObjects(beatMap, false, true)
 		beatMap.LoadCustomSamples()
+
+		if *objectRange != "" {
+			applyObjectRange(beatMap, *objectRange)
+		}
+
+		if partCount > 0 {
+			limitToPart(beatMap)
+		}
+
+		if settings.RECORD {
+			util.SetRandomSeed(renderSeed)
+		}
+
 		player = states.NewPlayer(beatMap)
 
 		limiter = frame.NewLimiter(int(settings.Graphics.FPSCap))
 	})
 
-	if recordMode {
+	if serverAddress != "" {
+		runServer(serverAddress)
+	} else if playlist != nil {
+		runPlaylist()
+	} else if batchMode {
+		runBatch()
+	} else if recordMode {
 		mainLoopRecord()
 	} else if screenshotMode {
 		mainLoopSS()
@@ -518,57 +599,78 @@ func run() {
 	}
 }
 
-func mainLoopRecord() {
-	count := int64(0)
+// frameRecorder pushes frames and audio of consecutive states to one ffmpeg session
+type frameRecorder struct {
+	fbo *buffer.Framebuffer
+
+	fps      float64
+	audioFPS float64
+
+	updateDelta float64
+	fpsDelta    float64
+	audioDelta  float64
+
+	deltaSumF float64
+	deltaSumA float64
+
+	// Number of rendered frames, including motion blur subframes
+	count int64
+}
 
+func newFrameRecorder() *frameRecorder {
 	fps := float64(settings.Recording.FPS)
-	audioFPS := 1000.0
 
 	if settings.Recording.MotionBlur.Enabled {
 		fps *= float64(settings.Recording.MotionBlur.OversampleMultiplier)
 	}
 
-	w, h := int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight())
-
-	var fbo *buffer.Framebuffer
+	updateFPS := math.Max(fps, 1000)
 
-	mainthread.Call(func() {
-		fbo = buffer.NewFrameMultisampleScreen(w, h, false, 0)
-	})
+	rec := &frameRecorder{
+		fps:         fps,
+		audioFPS:    1000.0,
+		updateDelta: 1000 / updateFPS,
+		fpsDelta:    1000 / fps,
+		deltaSumF:   1000 / fps,
+	}
 
-	ffmpeg.StartFFmpeg(int(fps), w, h, audioFPS, output)
+	rec.audioDelta = 1000 / rec.audioFPS
 
-	updateFPS := math.Max(fps, 1000)
-	updateDelta := 1000 / updateFPS
-	fpsDelta := 1000 / fps
-	audioDelta := 1000.0 / audioFPS
+	mainCall(func() {
+		rec.fbo = buffer.NewFrameMultisampleScreen(int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight()), false, 0)
 
-	deltaSumF := fpsDelta
-	deltaSumA := 0.0
+		settings.TRANSPARENT = settings.Recording.UsesAlpha()
 
-	p, _ := player.(*states.Player)
+		blend.SetAlphaOver(settings.TRANSPARENT)
+	})
 
-	lastCount := int64(0)
-	lastRealTime := qpc.GetMilliTimeF()
+	return rec
+}
 
-	var lastProgress, progress int
+func (rec *frameRecorder) start() {
+	ffmpeg.StartFFmpeg(int(rec.fps), int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight()), rec.audioFPS, output, resumeRecording)
+}
 
-	if preciseProgress {
-		lastProgress = -1
-	}
+// record calls update until it returns true, rendering the global player state. onFrame is called on the main thread after every rendered frame.
+func (rec *frameRecorder) record(update func(delta float64) bool, onFrame func()) {
+	for !update(rec.updateDelta) && !ffmpeg.Finished() {
+		if cancelRecording.Load() {
+			panic(errRecordingCancelled)
+		}
 
-	for !p.Update(updateDelta) {
-		deltaSumA += updateDelta
-		for deltaSumA >= audioDelta {
+		rec.deltaSumA += rec.updateDelta
+		for rec.deltaSumA >= rec.audioDelta {
 			ffmpeg.PushAudio()
 
-			deltaSumA -= audioDelta
+			rec.deltaSumA -= rec.audioDelta
 		}
 
-		deltaSumF += updateDelta
-		if deltaSumF >= fpsDelta {
-			mainthread.Call(func() {
-				fbo.Bind()
+		rec.deltaSumF += rec.updateDelta
+		if rec.deltaSumF >= rec.fpsDelta && ffmpeg.SkipFrame() { // Frame is outside recorded range
+			rec.deltaSumF -= rec.fpsDelta
+		} else if rec.deltaSumF >= rec.fpsDelta {
+			mainCall(func() {
+				rec.fbo.Bind()
 
 				ffmpeg.PreFrame()
 
@@ -578,40 +680,112 @@ func mainLoopRecord() {
 
 				ffmpeg.MakeFrame()
 
-				fbo.Unbind()
+				rec.fbo.Unbind()
 
-				count++
+				rec.count++
 
-				timeOffset := p.GetTimeOffset()
-				progress = int(math.Round(timeOffset / p.RunningTime * 100))
+				if onFrame != nil {
+					onFrame()
+				}
+			})
 
-				if (preciseProgress || progress%5 == 0) && lastProgress != progress {
-					speed := float64(count-lastCount) * (1000 / fps) / (qpc.GetMilliTimeF() - lastRealTime)
+			rec.deltaSumF -= rec.fpsDelta
+		}
+	}
+}
 
-					eta := int((p.RunningTime - timeOffset) / 1000 / speed)
+// getTime returns the length of recorded video in milliseconds
+func (rec *frameRecorder) getTime() float64 {
+	return float64(rec.count) * rec.fpsDelta
+}
 
-					etaText := util.FormatSeconds(eta)
+func (rec *frameRecorder) stop() {
+	mainCall(func() {
+		ffmpeg.StopFFmpeg()
+	})
+}
 
-					if settings.Recording.ShowFFmpegLogs {
-						fmt.Println()
-					}
+// progressLogger logs recording progress of a Player every 5% (or 1% with -preciseprogress)
+type progressLogger struct {
+	rec *frameRecorder
+	p   *states.Player
 
-					log.Println(fmt.Sprintf("Progress: %d%%, Speed: %.2fx, ETA: %s", progress, speed, etaText))
+	prefix string
 
-					lastProgress = progress
+	lastProgress int
+	lastCount    int64
+	lastRealTime float64
+}
 
-					lastCount = count
-					lastRealTime = qpc.GetMilliTimeF()
-				}
-			})
+func newProgressLogger(rec *frameRecorder, p *states.Player, prefix string) *progressLogger {
+	logger := &progressLogger{
+		rec:          rec,
+		p:            p,
+		prefix:       prefix,
+		lastCount:    rec.count,
+		lastRealTime: qpc.GetMilliTimeF(),
+	}
+
+	if preciseProgress {
+		logger.lastProgress = -1
+	}
+
+	return logger
+}
+
+func (logger *progressLogger) frameDone() {
+	timeOffset := logger.p.GetTimeOffset()
+	progress := int(math.Round(timeOffset / logger.p.RunningTime * 100))
+
+	if (preciseProgress || progress%5 == 0) && logger.lastProgress != progress {
+		speed := float64(logger.rec.count-logger.lastCount) * (1000 / logger.rec.fps) / (qpc.GetMilliTimeF() - logger.lastRealTime)
+
+		eta := int((logger.p.RunningTime - timeOffset) / 1000 / speed)
+
+		etaText := util.FormatSeconds(eta)
+
+		if settings.Recording.ShowFFmpegLogs {
+			fmt.Println()
+		}
 
-			deltaSumF -= fpsDelta
+		log.Println(fmt.Sprintf("%sProgress: %d%%, Speed: %.2fx, ETA: %s", logger.prefix, progress, speed, etaText))
+
+		if progressListener != nil {
+			progressListener(progress, speed, eta)
 		}
+
+		logger.lastProgress = progress
+
+		logger.lastCount = logger.rec.count
+		logger.lastRealTime = qpc.GetMilliTimeF()
 	}
+}
 
-	mainthread.Call(func() {
-		ffmpeg.StopFFmpeg()
-	})
+func mainLoopRecord() {
+	rec := newFrameRecorder()
+
+	p, _ := player.(*states.Player)
+
+	if partCount > 0 {
+		setupPart(p)
+	}
+
+	rec.start()
+
+	var highlights *highlightTracker
+	if settings.Recording.Highlights.Enabled {
+		highlights = newHighlightTracker(p)
+	}
+
+	rec.record(p.Update, newProgressLogger(rec, p, "").frameDone)
+
+	setRecordingMetadata(p)
+
+	if highlights != nil {
+		highlights.finish()
+	}
+
+	rec.stop()
 }
 
 func mainLoopSS() {
@@ -648,7 +822,11 @@ func mainLoopSS() {
 func mainLoopNormal() {
 	mainthread.Call(func() {
 		win.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
-			if action == glfw.Press {
+			// HUD editor uses some of the same keys
+			p, ok := player.(*states.Player)
+			editingHUD := ok && p.IsEditingHUD()
+
+			if action == glfw.Press && !editingHUD {
 				switch key {
 				case glfw.KeyEscape:
 					win.SetShouldClose(true)
@@ -740,7 +918,12 @@ func pushFrame() {
 		screenFBO.Bind()
 	}
 
-	gl.ClearColor(0, 0, 0, 1)
+	if settings.TRANSPARENT {
+		gl.ClearColor(0, 0, 0, 0)
+	} else {
+		gl.ClearColor(0, 0, 0, 1)
+	}
+
 	gl.Clear(gl.COLOR_BUFFER_BIT)
 
 	if player != nil {
@@ -802,6 +985,236 @@ func printPlatformInfo() {
 	log.Println("-------------------------------------------------------------------")
 }
 
+// initWindow creates hidden window with OpenGL context and makes the context current
+func initWindow(monitor *glfw.Monitor, beatMap *beatmap.BeatMap) {
+	var err error
+
+	if settings.Graphics.Fullscreen {
+		glfw.WindowHint(glfw.RedBits, monitor.GetVideoMode().RedBits)
+		glfw.WindowHint(glfw.GreenBits, monitor.GetVideoMode().GreenBits)
+		glfw.WindowHint(glfw.BlueBits, monitor.GetVideoMode().BlueBits)
+		glfw.WindowHint(glfw.RefreshRate, monitor.GetVideoMode().RefreshRate)
+		//glfw.WindowHint(glfw.Decorated, glfw.False)
+		win, err = glfw.CreateWindow(int(settings.Graphics.Width), int(settings.Graphics.Height), "danser", monitor, nil)
+	} else {
+		win, err = glfw.CreateWindow(int(settings.Graphics.WindowWidth), int(settings.Graphics.WindowHeight), "danser", nil, nil)
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	if !recordMode {
+		win.SetFocusCallback(func(w *glfw.Window, focused bool) {
+			log.Println("Focus changed: ", focused)
+			input.Focused = focused
+		})
+	}
+
+	if beatMap != nil {
+		win.SetTitle("danser " + build.VERSION + " - " + beatMap.Artist + " - " + beatMap.Name + " [" + beatMap.Difficulty + "]")
+	} else {
+		win.SetTitle("danser " + build.VERSION + " - batch")
+	}
+	input.Win = win
+
+	if cTime := time.Now(); cTime.Month() == 12 && cTime.Day() >= 6 {
+		platform.LoadIcons(win, "dansercoin", "-s")
+	} else {
+		platform.LoadIcons(win, "dansercoin", "")
+	}
+
+	win.MakeContextCurrent()
+
+	log.Println("GLFW initialized!")
+}
+
+// initHeadless creates OpenGL context without a window, if software is true, llvmpipe rasterizer is forced
+func initHeadless(software bool) {
+	if software {
+		platform.SetSoftwareRendering()
+	}
+
+	log.Println("Initializing headless OpenGL context...")
+
+	if err := platform.CreateHeadlessContext(); err != nil {
+		panic(err)
+	}
+
+	log.Println("Headless context initialized!")
+}
+
+// applyRecordSettings overrides settings that are incompatible with recording
+func applyRecordSettings() {
+	//HACK: some in-app variables depend on these settings so we force them here
+	settings.Graphics.VSync = false
+	settings.Graphics.ShowFPS = false
+	settings.DEBUG = false
+	settings.Graphics.Fullscreen = false
+	settings.Graphics.WindowWidth = int64(settings.Recording.FrameWidth)
+	settings.Graphics.WindowHeight = int64(settings.Recording.FrameHeight)
+	settings.Playfield.LeadInTime = 0
+}
+
+// applyLowCostSettings disables effects that are expensive to render, mostly on CPU
+func applyLowCostSettings() {
+	settings.Playfield.Bloom.Enabled = false
+	settings.Playfield.Background.Blur.Enabled = false
+	settings.Recording.MotionBlur.Enabled = false
+	settings.Graphics.MSAA = 0
+	settings.Cursor.EnableTrailGlow = false
+}
+
+// applyModSpeed adjusts playback speed and pitch to rate changing mods
+func applyModSpeed(mods difficulty2.Modifier) {
+	if mods.Active(difficulty2.Nightcore) {
+		settings.SPEED *= 1.5
+		settings.PITCH *= 1.5
+	} else if mods.Active(difficulty2.DoubleTime) {
+		settings.SPEED *= 1.5
+	} else if mods.Active(difficulty2.Daycore) {
+		settings.PITCH *= 0.75
+		settings.SPEED *= 0.75
+	} else if mods.Active(difficulty2.HalfTime) {
+		settings.SPEED *= 0.75
+	}
+}
+
+// loadReplay loads a replay used by -replay flag, panics if it can't be played back
+func loadReplay(path string) *rplpa.Replay {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+
+	rp, err := rplpa.ParseReplay(bytes)
+	if err != nil {
+		panic(err)
+	}
+
+	if rp.PlayMode != 0 {
+		panic("Modes other than osu!standard are not supported")
+	}
+
+	if rp.ReplayData == nil || len(rp.ReplayData) < 2 {
+		panic("Replay is missing input data")
+	}
+
+	return rp
+}
+
+// findBeatmap searches for a beatmap by id, md5 or metadata, in that order of priority. Metadata is matched partially if there's no exact match
+func findBeatmap(beatmaps []*beatmap.BeatMap, id int64, md5, artist, title, difficulty, creator string) *beatmap.BeatMap {
+	if id > -1 {
+		for _, b := range beatmaps {
+			if b.ID == id {
+				return b
+			}
+		}
+
+		return nil
+	}
+
+	if md5 != "" {
+		for _, b := range beatmaps {
+			if strings.EqualFold(b.MD5, md5) {
+				return b
+			}
+		}
+
+		return nil
+	}
+
+	for _, b := range beatmaps {
+		if (artist == "" || strings.EqualFold(artist, b.Artist)) &&
+			(title == "" || strings.EqualFold(title, b.Name)) &&
+			(difficulty == "" || strings.EqualFold(difficulty, b.Difficulty)) &&
+			(creator == "" || strings.EqualFold(creator, b.Creator)) {
+			return b
+		}
+	}
+
+	log.Println("Beatmap with exact parameters not found, searching partially...")
+
+	for _, b := range beatmaps {
+		if (artist == "" || strings.Contains(strings.ToLower(b.Artist), strings.ToLower(artist))) &&
+			(title == "" || strings.Contains(strings.ToLower(b.Name), strings.ToLower(title))) &&
+			(difficulty == "" || strings.Contains(strings.ToLower(b.Difficulty), strings.ToLower(difficulty))) &&
+			(creator == "" || strings.Contains(strings.ToLower(b.Creator), strings.ToLower(creator))) {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// applyObjectRange sets start and end time to cover objects in "from-to" range, object numbers start at 1
+func applyObjectRange(beatMap *beatmap.BeatMap, value string) {
+	var from, to int
+
+	if _, err := fmt.Sscanf(value, "%d-%d", &from, &to); err != nil {
+		panic(fmt.Sprintf("flag -objects: failed to parse object range: %s", err))
+	}
+
+	from = mutils.Clamp(from, 1, len(beatMap.HitObjects))
+	to = mutils.Clamp(to, from, len(beatMap.HitObjects))
+
+	settings.START = (beatMap.HitObjects[from-1].GetStartTime() - 1) / 1000
+	settings.END = (beatMap.HitObjects[to-1].GetEndTime() + 1) / 1000
+}
+
+// parsePathList accepts either a single path or JSON list of paths
+func parsePathList(value string) []string {
+	var paths []string
+
+	if strings.HasPrefix(strings.TrimSpace(value), "[") {
+		if err := json.Unmarshal([]byte(value), &paths); err != nil {
+			panic(fmt.Sprintf("Failed to parse path list: %s", err))
+		}
+
+		return paths
+	}
+
+	return []string{value}
+}
+
+// parseKnockoutReplays parses knockout2 replay list. Entries are either paths or objects with path and team.
+func parseKnockoutReplays(value string) (paths []string, teams map[string]string) {
+	var entries []json.RawMessage
+
+	if err := json.Unmarshal([]byte(value), &entries); err != nil {
+		panic(fmt.Sprintf("Failed to parse replay list: %s", err))
+	}
+
+	teams = make(map[string]string)
+
+	for _, entry := range entries {
+		var path string
+
+		if err := json.Unmarshal(entry, &path); err == nil {
+			paths = append(paths, path)
+			continue
+		}
+
+		var teamEntry struct {
+			Path string `json:"path"`
+			Team string `json:"team"`
+		}
+
+		if err := json.Unmarshal(entry, &teamEntry); err != nil {
+			panic(fmt.Sprintf("Failed to parse replay list: %s", err))
+		}
+
+		paths = append(paths, teamEntry.Path)
+
+		if teamEntry.Team != "" {
+			teams[teamEntry.Path] = teamEntry.Team
+		}
+	}
+
+	return
+}
+
 func Run() {
 	defer func() {
 		var err any
@@ -204,6 +204,8 @@ func PlaySliderTick(sampleSet, index int, volume float64, objNum int64, xPos flo
 }
 
 func LoadBeatmapSamples(dir string) {
+	MapSamples = [3][7]map[int]*bass.Sample{}
+
 	splitBeforeDigit := func(name string) []string {
 		for i, r := range name {
 			if unicode.IsDigit(r) {
@@ -0,0 +1,379 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/faiface/mainthread"
+	"github.com/wieku/danser-go/app/audio"
+	"github.com/wieku/danser-go/app/beatmap"
+	difficulty2 "github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/ffmpeg"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/skin"
+	"github.com/wieku/danser-go/app/states"
+	"github.com/wieku/danser-go/framework/goroutines"
+	"github.com/wieku/danser-go/framework/util"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type batchJob struct {
+	// Name of the video, defaults to job file's name with job's number
+	Output string `json:"output"`
+
+	// Beatmap selector, same as -id, -md5, -artist, -title, -difficulty and -creator flags
+	ID         *int64 `json:"id"`
+	MD5        string `json:"md5"`
+	Artist     string `json:"artist"`
+	Title      string `json:"title"`
+	Difficulty string `json:"difficulty"`
+	Creator    string `json:"creator"`
+
+	// Path to a single replay, same as -replay flag
+	Replay string `json:"replay"`
+
+	// Paths to knockout replays, same as -knockout2 flag
+	Replays []string `json:"replays"`
+
+	// Use classic knockout, same as -knockout flag
+	Knockout bool `json:"knockout"`
+
+	Mods     string `json:"mods"`
+	Skin     string `json:"skin"`
+	Settings string `json:"settings"`
+
+	// Settings changed only for this job, in the same format as settings file
+	Overrides json.RawMessage `json:"overrides"`
+
+	// Section to record in seconds
+	Start float64  `json:"start"`
+	End   *float64 `json:"end"`
+}
+
+type batchResult struct {
+	Index    int       `json:"index"`
+	Output   string    `json:"output"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Video    string    `json:"video,omitempty"`
+	Started  time.Time `json:"started"`
+	Duration float64   `json:"duration"`
+}
+
+// Values given by flags, used by every job that doesn't override them
+type batchDefaults struct {
+	settings string
+	skin     string
+	mods     string
+
+	divides int
+	tag     int
+	speed   float64
+	pitch   float64
+	skip    bool
+	offset  int
+	lowCost bool
+}
+
+// batchMode is true if recordings are driven by a job file or the render server
+var batchMode bool
+
+var batchJobs []*batchJob
+
+var errRecordingCancelled = errors.New("recording cancelled")
+
+// Set to stop current recording
+var cancelRecording atomic.Bool
+
+// Called every time recording progress changes
+var progressListener func(progress int, speed float64, eta int)
+
+var defaults batchDefaults
+
+// Beatmaps loaded from the database, shared by all jobs
+var batchBeatmaps []*beatmap.BeatMap
+
+func loadBatchJobs(path string) []*batchJob {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to read job file: %s", err))
+	}
+
+	var jobs []*batchJob
+
+	if err = json.Unmarshal(data, &jobs); err != nil {
+		panic(fmt.Sprintf("Failed to parse job file: %s", err))
+	}
+
+	if len(jobs) == 0 {
+		panic("Job file doesn't contain any jobs")
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	for i, job := range jobs {
+		if strings.TrimSpace(job.Output) == "" {
+			job.Output = fmt.Sprintf("%s_%d", name, i+1)
+		}
+	}
+
+	return jobs
+}
+
+func captureBatchDefaults(settingsVersion, skinName, mods string, lowCost bool) {
+	defaults = batchDefaults{
+		settings: settingsVersion,
+		skin:     skinName,
+		mods:     mods,
+		divides:  settings.DIVIDES,
+		tag:      settings.TAG,
+		speed:    settings.SPEED,
+		pitch:    settings.PITCH,
+		skip:     settings.SKIP,
+		offset:   settings.LOCALOFFSET,
+		lowCost:  lowCost,
+	}
+}
+
+// runBatch records all jobs one after another, failed jobs are reported and skipped
+func runBatch() {
+	failed := 0
+
+	for i, job := range batchJobs {
+		log.Println(fmt.Sprintf("Starting job %d/%d: %s", i+1, len(batchJobs), job.Output))
+
+		result := &batchResult{
+			Index:   i + 1,
+			Output:  job.Output,
+			Status:  "done",
+			Started: time.Now(),
+		}
+
+		if err := runBatchJob(job); err != nil {
+			log.Println(fmt.Sprintf("Job %d/%d failed: %s", i+1, len(batchJobs), err))
+
+			result.Status = "failed"
+			result.Error = err.Error()
+
+			failed++
+		} else {
+			result.Video = settings.Recording.GetOutputPath(job.Output)
+		}
+
+		result.Duration = time.Since(result.Started).Seconds()
+
+		writeBatchResult(result)
+	}
+
+	log.Println(fmt.Sprintf("Batch finished: %d of %d jobs recorded, %d failed", len(batchJobs)-failed, len(batchJobs), failed))
+}
+
+func runBatchJob(job *batchJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if rErr, ok := r.(error); ok {
+				err = rErr
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+
+			mainthread.Call(func() {
+				defer func() {
+					if r2 := recover(); r2 != nil {
+						log.Println("Failed to abort encoding:", r2)
+					}
+				}()
+
+				ffmpeg.Abort()
+
+				if p, ok := player.(*states.Player); ok && p != nil {
+					p.Dispose()
+				}
+			})
+
+			player = nil
+		}
+	}()
+
+	mainCall(func() {
+		setupBatchJob(job)
+	})
+
+	output = job.Output
+
+	mainLoopRecord()
+
+	mainthread.Call(player.Dispose)
+
+	player = nil
+
+	return nil
+}
+
+// setupBatchJob applies job's settings and creates a Player for its beatmap
+func setupBatchJob(job *batchJob) {
+	settingsVersion := defaults.settings
+	if job.Settings != "" {
+		settingsVersion = job.Settings
+	}
+
+	settings.LoadSettings(settingsVersion)
+
+	if len(job.Overrides) > 0 {
+		if err := settings.ApplyOverrides(job.Overrides); err != nil {
+			panic(fmt.Sprintf("Failed to apply settings overrides: %s", err))
+		}
+	}
+
+	if restoreRecording != nil {
+		restoreRecording()
+	}
+
+	applyRecordSettings()
+
+	if defaults.lowCost {
+		applyLowCostSettings()
+	}
+
+	if job.Skin != "" {
+		settings.Skin.CurrentSkin = job.Skin
+	} else if strings.TrimSpace(defaults.skin) != "" {
+		settings.Skin.CurrentSkin = defaults.skin
+	}
+
+	skin.Reload()
+	audio.LoadSamples()
+
+	settings.DIVIDES = defaults.divides
+	settings.TAG = defaults.tag
+	settings.SPEED = defaults.speed
+	settings.PITCH = defaults.pitch
+	settings.SKIP = defaults.skip
+	settings.LOCALOFFSET = defaults.offset
+
+	settings.START = job.Start
+	settings.END = math.Inf(1)
+
+	if job.End != nil {
+		settings.END = *job.End
+	}
+
+	settings.KNOCKOUT = job.Knockout || len(job.Replays) > 0
+	settings.KNOCKOUTREPLAYS = job.Replays
+	settings.REPLAY = ""
+
+	modString := defaults.mods
+	if job.Mods != "" {
+		modString = job.Mods
+	}
+
+	mods := difficulty2.ParseMods(modString)
+
+	id := int64(-1)
+	if job.ID != nil {
+		id = *job.ID
+	}
+
+	md5 := job.MD5
+
+	if job.Replay != "" {
+		rp := loadReplay(job.Replay)
+
+		md5 = rp.BeatmapMD5
+		id = -1
+		mods = difficulty2.Modifier(rp.Mods)
+
+		settings.KNOCKOUT = true
+		settings.REPLAY = job.Replay
+	}
+
+	if !mods.Compatible() {
+		panic("Incompatible mods selected!")
+	}
+
+	entry := findBeatmap(batchBeatmaps, id, md5, job.Artist, job.Title, job.Difficulty, job.Creator)
+	if entry == nil {
+		panic("Beatmap not found")
+	}
+
+	allowDA := false
+
+	if !settings.KNOCKOUT && mods.Active(difficulty2.Autoplay) {
+		settings.KNOCKOUT = true
+		settings.Knockout.MaxPlayers = 0
+		allowDA = true
+	}
+
+	beatMap := entry.Copy()
+
+	speedBefore := settings.SPEED
+
+	applyModSpeed(mods)
+
+	if !settings.KNOCKOUT || allowDA {
+		beatMap.Diff.SetCustomSpeed(speedBefore)
+	}
+
+	beatMap.Diff.SetMods(mods)
+
+	skin.ClearBeatmapColors()
+
+	beatmap.ParseTimingPointsAndPauses(beatMap)
+	beatmap.ParseObjects(beatMap, false, true)
+	beatMap.LoadCustomSamples()
+
+	util.SetRandomSeed(renderSeed)
+
+	player = states.NewPlayer(beatMap)
+}
+
+func writeBatchResult(result *batchResult) {
+	data, err := json.MarshalIndent(result, "", "\t")
+	if err != nil {
+		log.Println("Failed to encode job result:", err)
+		return
+	}
+
+	path := filepath.Join(settings.Recording.GetOutputDir(), result.Output+".status.json")
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil && !os.IsExist(err) {
+		log.Println("Failed to write job result:", err)
+		return
+	}
+
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		log.Println("Failed to write job result:", err)
+	}
+}
+
+// mainCall runs f on the main thread. In batch mode panics are passed back to the caller, so a failed job doesn't close danser.
+func mainCall(f func()) {
+	if !batchMode {
+		mainthread.Call(f)
+		return
+	}
+
+	var err any
+
+	mainthread.Call(func() {
+		defer func() {
+			if err = recover(); err != nil {
+				for _, s := range goroutines.GetStackTrace(4) {
+					log.Println(s)
+				}
+			}
+		}()
+
+		f()
+	})
+
+	if err != nil {
+		panic(err)
+	}
+}
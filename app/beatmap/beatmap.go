@@ -85,6 +85,21 @@ func (beatMap *BeatMap) Reset() {
 	}
 }
 
+// Copy returns a copy of beatmap's metadata without parsed timing points and objects, so the same beatmap can be loaded again with different mods
+func (beatMap *BeatMap) Copy() *BeatMap {
+	bMap := *beatMap
+
+	bMap.Diff = difficulty.NewDifficulty(beatMap.Diff.GetBaseHP(), beatMap.Diff.GetBaseCS(), beatMap.Diff.GetBaseOD(), beatMap.Diff.GetBaseAR())
+	bMap.Timings = objects.NewTimings()
+
+	bMap.HitObjects = nil
+	bMap.Pauses = nil
+	bMap.Queue = nil
+	bMap.processed = nil
+
+	return &bMap
+}
+
 func (beatMap *BeatMap) Clear() {
 	beatMap.HitObjects = make([]objects.IHitObject, 0)
 	beatMap.Timings = objects.NewTimings()
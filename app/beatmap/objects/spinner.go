@@ -16,8 +16,8 @@ import (
 	"github.com/wieku/danser-go/framework/math/math32"
 	"github.com/wieku/danser-go/framework/math/mutils"
 	"github.com/wieku/danser-go/framework/math/vector"
+	"github.com/wieku/danser-go/framework/util"
 	"math"
-	"math/rand"
 	"strconv"
 )
 
@@ -370,7 +370,7 @@ func (spinner *Spinner) UpdateCompletion(completion float64) {
 	} else if spinner.metre != nil {
 		bars := int(math.Min(0.99, completion) * 10)
 
-		if skin.GetInfo().SpinnerNoBlink || rand.Float64() < math.Mod(completion*10, 1) {
+		if skin.GetInfo().SpinnerNoBlink || util.RandomFloat64() < math.Mod(completion*10, 1) {
 			bars++
 		}
 
@@ -158,6 +158,10 @@ func (tim *Timings) GetTickDistance(point TimingPoint) float64 {
 	return tim.GetScoringDistance() / point.GetRatio()
 }
 
+func (tim *Timings) GetPoints() []TimingPoint {
+	return tim.points
+}
+
 func (tim *Timings) HasPoints() bool {
 	return len(tim.points) > 0
 }
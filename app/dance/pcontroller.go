@@ -16,6 +16,7 @@ import (
 	"github.com/wieku/danser-go/app/utils"
 	"github.com/wieku/danser-go/framework/math/vector"
 	"github.com/wieku/danser-go/framework/platform"
+	"github.com/wieku/rplpa"
 	"log"
 	"strings"
 	"time"
@@ -40,6 +41,10 @@ type PlayerController struct {
 
 	quickRestart     bool
 	quickRestartTime float64
+
+	frames        []*rplpa.ReplayData
+	lastFrameTime int64
+	lastKeys      rplpa.KeyPressed
 }
 
 func NewPlayerController() Controller {
@@ -79,6 +84,24 @@ func (controller *PlayerController) InitCursors() {
 	}
 }
 
+// Reset replaces the ruleset with a fresh one, keeping the cursor and input state
+func (controller *PlayerController) Reset() {
+	controller.ruleset = osu.NewOsuRuleset(controller.bMap, controller.cursors, []difficulty.Modifier{controller.bMap.Diff.Mods})
+
+	if controller.relaxController != nil {
+		controller.relaxController = input.NewRelaxInputProcessor(controller.ruleset, controller.cursors[0])
+	}
+
+	if controller.mouseController != nil {
+		controller.mouseController = schedulers.NewGenericScheduler(movers.NewLinearMoverSimple, 0, 0)
+		controller.mouseController.Init(controller.bMap.GetObjectsCopy(), controller.bMap.Diff, controller.cursors[0], spinners.GetMoverCtorByName("circle"), false)
+	}
+
+	controller.quickRestart = false
+
+	controller.frames = nil
+}
+
 func (controller *PlayerController) KeyEvent(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, _ glfw.ModifierKey) {
 	kName, ok := platform.GetKeyName(key, scancode)
 	if !ok {
@@ -165,6 +188,8 @@ func (controller *PlayerController) Update(time float64, delta float64) {
 		controller.cursors[0].IsReplayFrame = false
 	}
 
+	controller.recordFrame(int64(time))
+
 	controller.ruleset.UpdateClickFor(controller.cursors[0], int64(time))
 	controller.ruleset.UpdateNormalFor(controller.cursors[0], int64(time), false)
 	controller.ruleset.UpdatePostFor(controller.cursors[0], int64(time), false)
@@ -175,6 +200,46 @@ func (controller *PlayerController) Update(time float64, delta float64) {
 	controller.cursors[0].Update(delta)
 }
 
+// recordFrame saves cursor state at 60Hz and on every key change, so the play can be saved as a replay
+func (controller *PlayerController) recordFrame(time int64) {
+	cursor := controller.cursors[0]
+
+	keys := rplpa.KeyPressed{
+		LeftClick:  cursor.LeftButton,
+		RightClick: cursor.RightButton,
+		Key1:       cursor.LeftKey,
+		Key2:       cursor.RightKey,
+		Smoke:      cursor.SmokeKey,
+	}
+
+	if len(controller.frames) == 0 {
+		// osu!stable starts replays with two frames outside the playfield
+		controller.frames = append(controller.frames,
+			&rplpa.ReplayData{Time: 0, MouseX: 256, MouseY: -500, KeyPressed: &rplpa.KeyPressed{}},
+			&rplpa.ReplayData{Time: -1, MouseX: 256, MouseY: -500, KeyPressed: &rplpa.KeyPressed{}},
+		)
+
+		controller.lastFrameTime = -1
+	} else if (!cursor.IsReplayFrame && keys == controller.lastKeys) || time == controller.lastFrameTime {
+		return
+	}
+
+	controller.frames = append(controller.frames, &rplpa.ReplayData{
+		Time:       time - controller.lastFrameTime,
+		MouseX:     cursor.RawPosition.X,
+		MouseY:     cursor.RawPosition.Y,
+		KeyPressed: &keys,
+	})
+
+	controller.lastFrameTime = time
+	controller.lastKeys = keys
+}
+
+// GetReplayFrames returns recorded frames with times relative to previous frames, like in .osr files
+func (controller *PlayerController) GetReplayFrames() []*rplpa.ReplayData {
+	return controller.frames
+}
+
 func (controller *PlayerController) GetRuleset() *osu.OsuRuleSet {
 	return controller.ruleset
 }
@@ -51,12 +51,14 @@ type RpData struct {
 	Grade     osu.Grade
 	scoreID   int64
 	ScoreTime time.Time
+	Team      string
 }
 
 type subControl struct {
 	danceController Controller
 	replayIndex     int
 	replayTime      int64
+	startTime       int64
 	frames          []*rplpa.ReplayData
 	newHandling     bool
 	lastTime        int64
@@ -89,9 +91,9 @@ func NewReplayController() Controller {
 func (controller *ReplayController) SetBeatMap(beatMap *beatmap.BeatMap) {
 	controller.bMap = beatMap
 
-	organizeReplays()
+	OrganizeReplays()
 
-	candidates := make([]*rplpa.Replay, 0)
+	candidates := make([]Candidate, 0)
 
 	localReplay := false
 	if settings.REPLAY != "" {
@@ -107,12 +109,12 @@ func (controller *ReplayController) SetBeatMap(beatMap *beatmap.BeatMap) {
 		if replayD.ReplayData == nil || len(replayD.ReplayData) == 0 {
 			log.Println("Excluding for missing input data:", replayD.Username)
 		} else {
-			candidates = append(candidates, replayD)
+			candidates = append(candidates, Candidate{settings.REPLAY, replayD})
 
 			localReplay = true
 		}
 	} else if settings.Knockout.MaxPlayers > 0 || (settings.KNOCKOUTREPLAYS != nil && len(settings.KNOCKOUTREPLAYS) > 0) { // ignore max player limit with new knockout
-		candidates = controller.getCandidates()
+		candidates = GetCandidates(controller.bMap.MD5)
 	}
 
 	if !localReplay {
@@ -128,7 +130,9 @@ func (controller *ReplayController) SetBeatMap(beatMap *beatmap.BeatMap) {
 
 	displayedMods := ^difficulty.ParseMods(settings.Knockout.HideMods)
 
-	for i, replay := range candidates {
+	for i, candidate := range candidates {
+		replay := candidate.Replay
+
 		log.Println(fmt.Sprintf("Loading replay for \"%s\":", replay.Username))
 
 		control := NewSubControl()
@@ -143,21 +147,35 @@ func (controller *ReplayController) SetBeatMap(beatMap *beatmap.BeatMap) {
 		control.newHandling = replay.OsuVersion >= 20190506 // This was when slider scoring was changed, so *I think* replay handling as well: https://osu.ppy.sh/home/changelog/cuttingedge/20190506
 		control.oldSpinners = replay.OsuVersion < 20190510  // This was when spinner scoring was changed: https://osu.ppy.sh/home/changelog/cuttingedge/20190510.2
 
-		controller.replays = append(controller.replays, RpData{replay.Username + string(rune(unicode.MaxRune-i)), (control.mods & displayedMods).String(), control.mods, 100, 0, int64(mxCombo), osu.NONE, replay.ScoreID, replay.Timestamp})
+		controller.replays = append(controller.replays, RpData{replay.Username + string(rune(unicode.MaxRune-i)), (control.mods & displayedMods).String(), control.mods, 100, 0, int64(mxCombo), osu.NONE, replay.ScoreID, replay.Timestamp, ""})
+
+		if settings.Knockout.Teams.Enabled {
+			team := settings.Knockout.Teams.FindTeam(candidate.Path, replay.Username)
+			controller.replays[len(controller.replays)-1].Team = team
+
+			log.Println("\tTeam:", team)
+		}
 		controller.controllers = append(controller.controllers, control)
 
 		log.Println("\tExpected score:", replay.Score)
 		log.Println("\tReplay loaded!")
 	}
 
-	if !localReplay && (settings.Knockout.AddDanser || len(candidates) == 0) {
+	// Similarity render compares only the two replays
+	addDanser := settings.Knockout.AddDanser && !settings.SIMILARITY
+
+	if !localReplay && (addDanser || len(candidates) == 0) {
 		control := NewSubControl()
 		control.mods = difficulty.Autoplay | beatMap.Diff.Mods
 
 		control.danceController = NewGenericController()
 		control.danceController.SetBeatMap(beatMap)
 
-		controller.replays = append([]RpData{{settings.Knockout.DanserName, control.mods.String(), control.mods, 100, 0, 0, osu.NONE, -1, time.Now()}}, controller.replays...)
+		controller.replays = append([]RpData{{settings.Knockout.DanserName, control.mods.String(), control.mods, 100, 0, 0, osu.NONE, -1, time.Now(), ""}}, controller.replays...)
+
+		if settings.Knockout.Teams.Enabled {
+			controller.replays[0].Team = settings.Knockout.Teams.FindTeam("", settings.Knockout.DanserName)
+		}
 		controller.controllers = append([]*subControl{control}, controller.controllers...)
 
 		if len(candidates) == 0 {
@@ -168,7 +186,8 @@ func (controller *ReplayController) SetBeatMap(beatMap *beatmap.BeatMap) {
 	settings.PLAYERS = len(controller.replays)
 }
 
-func organizeReplays() {
+// OrganizeReplays moves replays put directly in "replays" to "replays/{md5}" directories, using maps' md5s provided by the replay files
+func OrganizeReplays() {
 	replayDir := filepath.Join(env.DataDir(), replaysMaster)
 
 	_ = godirwalk.Walk(replayDir, &godirwalk.Options{
@@ -215,7 +234,14 @@ func organizeReplays() {
 	})
 }
 
-func (controller *ReplayController) getCandidates() (candidates []*rplpa.Replay) {
+// Candidate is a knockout replay together with the file it was loaded from
+type Candidate struct {
+	Path   string
+	Replay *rplpa.Replay
+}
+
+// GetCandidates loads knockout replays from settings.KNOCKOUTREPLAYS if they are provided, otherwise from "replays/{md5}"
+func GetCandidates(md5 string) (candidates []Candidate) {
 	excludedMods := difficulty.ParseMods(settings.Knockout.ExcludeMods)
 
 	tryAddReplay := func(path string, modExclude bool) {
@@ -234,7 +260,7 @@ func (controller *ReplayController) getCandidates() (candidates []*rplpa.Replay)
 		}
 
 		// HACKHACK [xJunko]: yes.
-		// if !strings.EqualFold(replayD.BeatmapMD5, controller.bMap.MD5) {
+		// if !strings.EqualFold(replayD.BeatmapMD5, md5) {
 		// 	log.Println("Incompatible maps, skipping", replayD.Username)
 		// 	return
 		// }
@@ -254,7 +280,7 @@ func (controller *ReplayController) getCandidates() (candidates []*rplpa.Replay)
 			return
 		}
 
-		candidates = append(candidates, replayD)
+		candidates = append(candidates, Candidate{path, replayD})
 	}
 
 	if settings.KNOCKOUTREPLAYS != nil && len(settings.KNOCKOUTREPLAYS) > 0 {
@@ -274,7 +300,7 @@ func (controller *ReplayController) getCandidates() (candidates []*rplpa.Replay)
 			tryAddReplay(r, false)
 		}
 	} else {
-		replayDir := filepath.Join(env.DataDir(), replaysMaster, controller.bMap.MD5)
+		replayDir := filepath.Join(env.DataDir(), replaysMaster, md5)
 
 		_ = godirwalk.Walk(replayDir, &godirwalk.Options{
 			Callback: func(osPathname string, de *godirwalk.Dirent) error {
@@ -296,7 +322,8 @@ func (controller *ReplayController) getCandidates() (candidates []*rplpa.Replay)
 	return
 }
 
-func loadFrames(subController *subControl, frames []*rplpa.ReplayData) {
+// CleanFrames removes mania seed frame and incorrect first frame from replay data
+func CleanFrames(frames []*rplpa.ReplayData) []*rplpa.ReplayData {
 	// Remove mania seed frame if its present
 	for i, frame := range frames {
 		if frame.Time == -12345 {
@@ -306,10 +333,16 @@ func loadFrames(subController *subControl, frames []*rplpa.ReplayData) {
 	}
 
 	// Remove incorrect first frame if its delta is 0
-	if frames[0].Time == 0 {
+	if len(frames) > 0 && frames[0].Time == 0 {
 		frames = frames[1:]
 	}
 
+	return frames
+}
+
+func loadFrames(subController *subControl, frames []*rplpa.ReplayData) {
+	frames = CleanFrames(frames)
+
 	times := make([]float64, 0, len(frames))
 
 	duration := 0
@@ -349,8 +382,6 @@ func loadFrames(subController *subControl, frames []*rplpa.ReplayData) {
 }
 
 func (controller *ReplayController) InitCursors() {
-	var modifiers []difficulty.Modifier
-
 	for i, c := range controller.controllers {
 		if controller.controllers[i].danceController != nil {
 			controller.controllers[i].danceController.InitCursors()
@@ -381,14 +412,71 @@ func (controller *ReplayController) InitCursors() {
 			c.replayTime += c.frames[0].Time
 			c.frames = c.frames[1:]
 
+			c.startTime = c.replayTime
+
 			controller.cursors = append(controller.cursors, cursor)
 		}
 
 		if controller.bMap.Diff.Mods.Active(difficulty.HardRock) != controller.replays[i].ModsV.Active(difficulty.HardRock) {
 			controller.cursors[i].InvertDisplay = true
 		}
+	}
+
+	controller.initRuleset()
+}
+
+// Reset rewinds replays to their first frame and replaces the ruleset with a fresh one, so the map can be simulated again from the start.
+// Replay cursors are kept, danser's cursor is recreated along with its controller.
+func (controller *ReplayController) Reset() {
+	for i, c := range controller.controllers {
+		cursor := controller.cursors[i]
+
+		if c.danceController != nil {
+			c.danceController = NewGenericController()
+			c.danceController.SetBeatMap(controller.bMap)
+			c.danceController.InitCursors()
+
+			newCursor := c.danceController.GetCursors()[0]
+			newCursor.IsPlayer = true
+			newCursor.IsAutoplay = true
+			newCursor.Name = cursor.Name
+			newCursor.ScoreTime = cursor.ScoreTime
+			newCursor.ScoreID = cursor.ScoreID
+			newCursor.InvertDisplay = cursor.InvertDisplay
+
+			controller.cursors[i] = newCursor
+
+			c.lastTime = 0
+
+			continue
+		}
+
+		c.replayIndex = 0
+		c.replayTime = c.startTime
+
+		cursor.LeftKey = false
+		cursor.RightKey = false
+		cursor.LeftMouse = false
+		cursor.RightMouse = false
+		cursor.LeftButton = false
+		cursor.RightButton = false
+		cursor.SmokeKey = false
+
+		cursor.LastFrameTime = 0
+		cursor.CurrentFrameTime = 0
+	}
+
+	controller.lastTime = -200
+
+	controller.initRuleset()
+}
+
+// initRuleset creates the ruleset for current cursors, along with input processors of relax and autopilot replays
+func (controller *ReplayController) initRuleset() {
+	modifiers := make([]difficulty.Modifier, 0, len(controller.replays))
 
-		modifiers = append(modifiers, controller.replays[i].ModsV)
+	for _, r := range controller.replays {
+		modifiers = append(modifiers, r.ModsV)
 	}
 
 	controller.ruleset = osu.NewOsuRuleset(controller.bMap, controller.cursors, modifiers)
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	JobQueued    = "queued"
+	JobRendering = "rendering"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// RenderJob is a recording queued by the render server
+type RenderJob struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+
+	// Job parameters as submitted
+	Request json.RawMessage `json:"request"`
+
+	// Name of the video in Recording.OutputDir, without extension
+	Output string `json:"output"`
+
+	// Absolute path of the finished recording, local to the server
+	OutputPath string `json:"-"`
+
+	Error string `json:"error,omitempty"`
+
+	Progress int     `json:"progress"`
+	Speed    float64 `json:"speed"`
+	ETA      int     `json:"eta"`
+
+	Created int64 `json:"created"`
+	Updated int64 `json:"updated"`
+}
+
+var ErrJobNotFound = errors.New("job not found")
+
+// Queue is updated by the renderer and HTTP handlers at the same time
+var jobLock = &sync.Mutex{}
+
+const jobFields = "id, status, request, output, output_path, error, progress, speed, eta, created, updated"
+
+func AddRenderJob(request []byte, outputPrefix string) (*RenderJob, error) {
+	jobLock.Lock()
+	defer jobLock.Unlock()
+
+	now := time.Now().Unix()
+
+	res, err := dbFile.Exec("INSERT INTO render_jobs (status, request, output, error, created, updated) VALUES (?, ?, '', '', ?, ?)", JobQueued, string(request), now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &RenderJob{
+		ID:      id,
+		Status:  JobQueued,
+		Request: request,
+		Output:  fmt.Sprintf("%s_%d", outputPrefix, id),
+		Created: now,
+		Updated: now,
+	}
+
+	if _, err = dbFile.Exec("UPDATE render_jobs SET output = ? WHERE id = ?", job.Output, id); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func GetRenderJob(id int64) (*RenderJob, error) {
+	jobLock.Lock()
+	defer jobLock.Unlock()
+
+	job, err := scanRenderJob(dbFile.QueryRow("SELECT "+jobFields+" FROM render_jobs WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+
+	return job, err
+}
+
+// GetNextRenderJob returns the oldest queued job, nil if there are none
+func GetNextRenderJob() (*RenderJob, error) {
+	jobLock.Lock()
+	defer jobLock.Unlock()
+
+	job, err := scanRenderJob(dbFile.QueryRow("SELECT "+jobFields+" FROM render_jobs WHERE status = ? ORDER BY id LIMIT 1", JobQueued))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return job, err
+}
+
+func GetRenderJobs() ([]*RenderJob, error) {
+	jobLock.Lock()
+	defer jobLock.Unlock()
+
+	rows, err := dbFile.Query("SELECT " + jobFields + " FROM render_jobs ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	jobs := make([]*RenderJob, 0)
+
+	for rows.Next() {
+		job, err := scanRenderJob(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+func UpdateRenderJob(job *RenderJob) error {
+	jobLock.Lock()
+	defer jobLock.Unlock()
+
+	job.Updated = time.Now().Unix()
+
+	_, err := dbFile.Exec("UPDATE render_jobs SET status = ?, output_path = ?, error = ?, progress = ?, speed = ?, eta = ?, updated = ? WHERE id = ?", job.Status, job.OutputPath, job.Error, job.Progress, job.Speed, job.ETA, job.Updated, job.ID)
+
+	return err
+}
+
+// CancelRenderJob cancels a queued job, returns false if job is not queued anymore
+func CancelRenderJob(id int64) (bool, error) {
+	jobLock.Lock()
+	defer jobLock.Unlock()
+
+	res, err := dbFile.Exec("UPDATE render_jobs SET status = ?, updated = ? WHERE id = ? AND status = ?", JobCancelled, time.Now().Unix(), id, JobQueued)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+
+	return affected > 0, err
+}
+
+// RequeueRenderJobs puts jobs interrupted by closing danser back into the queue
+func RequeueRenderJobs() error {
+	jobLock.Lock()
+	defer jobLock.Unlock()
+
+	_, err := dbFile.Exec("UPDATE render_jobs SET status = ?, progress = 0, speed = 0, eta = 0 WHERE status = ?", JobQueued, JobRendering)
+
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRenderJob(row rowScanner) (*RenderJob, error) {
+	job := new(RenderJob)
+
+	var request string
+
+	err := row.Scan(&job.ID, &job.Status, &request, &job.Output, &job.OutputPath, &job.Error, &job.Progress, &job.Speed, &job.ETA, &job.Created, &job.Updated)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Request = json.RawMessage(request)
+
+	return job, nil
+}
@@ -0,0 +1,27 @@
+package database
+
+import (
+	"github.com/wieku/danser-go/app/beatmap"
+)
+
+type M20261017 struct{}
+
+func (m *M20261017) RequiredSections() []string {
+	return nil
+}
+
+func (m *M20261017) FieldsToMigrate() []string {
+	return nil
+}
+
+func (m *M20261017) GetValues(_ *beatmap.BeatMap) []interface{} {
+	return nil
+}
+
+func (m *M20261017) Date() int {
+	return 20261017
+}
+
+func (m *M20261017) GetMigrationStmts() string {
+	return "CREATE TABLE IF NOT EXISTS render_jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, status TEXT, request TEXT, output TEXT, output_path TEXT DEFAULT '', error TEXT, progress INTEGER DEFAULT 0, speed REAL DEFAULT 0, eta INTEGER DEFAULT 0, created INTEGER, updated INTEGER);"
+}
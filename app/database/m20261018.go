@@ -0,0 +1,30 @@
+package database
+
+import (
+	"github.com/wieku/danser-go/app/beatmap"
+)
+
+type M20261018 struct{}
+
+func (m *M20261018) RequiredSections() []string {
+	return nil
+}
+
+func (m *M20261018) FieldsToMigrate() []string {
+	return nil
+}
+
+func (m *M20261018) GetValues(_ *beatmap.BeatMap) []interface{} {
+	return nil
+}
+
+func (m *M20261018) Date() int {
+	return 20261018
+}
+
+func (m *M20261018) GetMigrationStmts() string {
+	return `
+		CREATE TABLE IF NOT EXISTS scores (id INTEGER PRIMARY KEY AUTOINCREMENT, md5 TEXT, name TEXT, mods INTEGER, score INTEGER, combo INTEGER, accuracy REAL, date INTEGER, count300 INTEGER DEFAULT 0, count100 INTEGER DEFAULT 0, count50 INTEGER DEFAULT 0, countMiss INTEGER DEFAULT 0, countGeki INTEGER DEFAULT 0, countKatu INTEGER DEFAULT 0, pp REAL DEFAULT 0, ur REAL DEFAULT 0, replay TEXT DEFAULT '');
+		CREATE INDEX IF NOT EXISTS scores_md5 ON scores (md5);
+	`
+}
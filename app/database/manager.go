@@ -27,7 +27,10 @@ import (
 
 var dbFile *sql.DB
 
-const databaseVersion = 20220622
+const databaseVersion = 20261018
+
+// Schema version of tables created by Init in a new database, later migrations are applied on top of it
+const baseSchemaVersion = 20220622
 
 var currentPreVersion = databaseVersion
 var currentSchemaPreVersion = databaseVersion
@@ -67,6 +70,8 @@ func Init() error {
 		&M20210423{},
 		&M20220605{},
 		&M20220622{},
+		&M20261017{},
+		&M20261018{},
 	}
 
 	dbFile, err = sql.Open("sqlite3", filepath.Join(env.DataDir(), "danser.db"))
@@ -84,6 +89,7 @@ func Init() error {
 		return err
 	}
 
+	versionExists := false
 	schemaVersionExists := false
 
 	res, err := dbFile.Query("SELECT key, value FROM info")
@@ -100,6 +106,7 @@ func Init() error {
 		}
 
 		if key == "version" {
+			versionExists = true
 			currentPreVersion, _ = strconv.Atoi(value)
 		}
 
@@ -109,6 +116,10 @@ func Init() error {
 		}
 	}
 
+	if !versionExists {
+		currentPreVersion = baseSchemaVersion
+	}
+
 	if !schemaVersionExists {
 		currentSchemaPreVersion = currentPreVersion
 	}
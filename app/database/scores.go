@@ -0,0 +1,148 @@
+package database
+
+import (
+	"database/sql"
+	"github.com/wieku/danser-go/framework/env"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Score is a result of -play session
+type Score struct {
+	ID       int64
+	MD5      string
+	Name     string
+	Mods     int64
+	Score    int64
+	Combo    int64
+	Accuracy float64
+	Date     int64 // Unix time
+
+	Count300  int64
+	Count100  int64
+	Count50   int64
+	CountMiss int64
+	CountGeki int64
+	CountKatu int64
+
+	PP float64
+	UR float64
+
+	// Path to saved .osr file, empty if replay wasn't saved
+	Replay string
+}
+
+var scoreLock = &sync.Mutex{}
+
+const scoreValues = "md5, name, mods, score, combo, accuracy, date, count300, count100, count50, countMiss, countGeki, countKatu, pp, ur, replay"
+
+const scoreFields = "id, " + scoreValues
+
+// withScores runs f on the database. Database is closed after beatmaps are loaded, so a separate connection is opened in that case.
+func withScores(f func(db *sql.DB) error) error {
+	scoreLock.Lock()
+	defer scoreLock.Unlock()
+
+	if dbFile != nil {
+		return f(dbFile)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(env.DataDir(), "danser.db"))
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	return f(db)
+}
+
+func AddScore(score *Score) error {
+	return withScores(func(db *sql.DB) error {
+		res, err := db.Exec("INSERT INTO scores ("+scoreValues+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			strings.ToLower(score.MD5),
+			score.Name,
+			score.Mods,
+			score.Score,
+			score.Combo,
+			score.Accuracy,
+			score.Date,
+			score.Count300,
+			score.Count100,
+			score.Count50,
+			score.CountMiss,
+			score.CountGeki,
+			score.CountKatu,
+			score.PP,
+			score.UR,
+			score.Replay)
+		if err != nil {
+			return err
+		}
+
+		score.ID, err = res.LastInsertId()
+
+		return err
+	})
+}
+
+// GetScores returns scores set on the map, best first
+func GetScores(md5 string) ([]*Score, error) {
+	return queryScores("WHERE md5 = ? ORDER BY score DESC", strings.ToLower(md5))
+}
+
+// GetPersonalBest returns the best score of the player set on the map with given mods, nil if there's none
+func GetPersonalBest(md5, name string, mods int64) (*Score, error) {
+	scores, err := queryScores("WHERE md5 = ? AND name = ? AND mods = ? ORDER BY score DESC LIMIT 1", strings.ToLower(md5), name, mods)
+	if err != nil || len(scores) == 0 {
+		return nil, err
+	}
+
+	return scores[0], nil
+}
+
+func queryScores(condition string, args ...interface{}) (scores []*Score, err error) {
+	err = withScores(func(db *sql.DB) error {
+		rows, err := db.Query("SELECT "+scoreFields+" FROM scores "+condition, args...)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			score := new(Score)
+
+			err = rows.Scan(
+				&score.ID,
+				&score.MD5,
+				&score.Name,
+				&score.Mods,
+				&score.Score,
+				&score.Combo,
+				&score.Accuracy,
+				&score.Date,
+				&score.Count300,
+				&score.Count100,
+				&score.Count50,
+				&score.CountMiss,
+				&score.CountGeki,
+				&score.CountKatu,
+				&score.PP,
+				&score.UR,
+				&score.Replay,
+			)
+
+			if err != nil {
+				return err
+			}
+
+			scores = append(scores, score)
+		}
+
+		return rows.Err()
+	})
+
+	return
+}
@@ -10,7 +10,6 @@ import (
 	"log"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
@@ -27,7 +26,58 @@ var audioPool chan []byte
 var audioWriteQueue chan []byte
 var endSyncAudio *sync.WaitGroup
 
+// Number of audio chunks pushed so far
+var audioChunks int64
+
+// Chunks up to this number are not recorded, because they are already recorded or recording doesn't need audio
+var skipAudioChunks int64
+
+// Size of the main mix in pooled buffers, if stems are recorded they follow it in the same buffer
+var audioChunkSize int
+
+var recordStems bool
+
+// Parts of the current buffer holding stems
+var stemViews [][]byte
+
+// Raw stem tracks written next to the main audio, nil if stems are not recorded
+var stemFiles []*os.File
+
 func startAudio(audioFPS float64) {
+	initAudio(audioFPS)
+
+	if rawAudio() { // Audio is encoded at the end
+		startAudioFile(tempPath("audio.raw"))
+		startStemFiles("")
+
+		return
+	}
+
+	startAudioProcess(tempPath("audio." + settings.Recording.GetContainer()))
+}
+
+func initAudio(audioFPS float64) {
+	audioChunkSize = bass.GetMixerRequiredBufferSize(1 / audioFPS)
+
+	recordStems = settings.Recording.UsesStems()
+
+	bass.SetStems(recordStems)
+
+	audioBufSize := audioChunkSize
+
+	if recordStems {
+		audioBufSize *= 1 + len(bass.StemNames)
+		stemViews = make([][]byte, len(bass.StemNames))
+	}
+
+	audioPool = make(chan []byte, MaxAudioBuffers)
+
+	for i := 0; i < MaxAudioBuffers; i++ {
+		audioPool <- make([]byte, audioBufSize)
+	}
+}
+
+func startAudioProcess(path string) {
 	inputName := "-"
 
 	if runtime.GOOS != "windows" {
@@ -53,27 +103,17 @@ func startAudio(audioFPS float64) {
 		"-vn",
 	}
 
-	audioFilters := strings.TrimSpace(settings.Recording.AudioFilters)
-	if len(audioFilters) > 0 {
-		options = append(options, "-af", audioFilters)
-	}
+	options = append(options, getAudioEncodingOptions()...)
 
-	options = append(options, "-c:a", settings.Recording.AudioCodec, "-strict", "-2")
-
-	encOptions, err := settings.Recording.GetAudioOptions().GenerateFFmpegArgs()
-	if err != nil {
-		panic(fmt.Sprintf("encoder \"%s\": %s", settings.Recording.AudioCodec, err))
-	} else if encOptions != nil {
-		options = append(options, encOptions...)
-	}
-
-	options = append(options, filepath.Join(settings.Recording.GetOutputDir(), output+"_temp", "audio."+settings.Recording.Container))
+	options = append(options, path)
 
 	log.Println("Running ffmpeg with options:", options)
 
 	cmdAudio = exec.Command(ffmpegExec, options...)
 
 	if runtime.GOOS == "windows" {
+		var err error
+
 		audioPipe, err = cmdAudio.StdinPipe()
 		if err != nil {
 			panic(err)
@@ -85,19 +125,46 @@ func startAudio(audioFPS float64) {
 		cmdAudio.Stderr = os.Stderr
 	}
 
-	err = cmdAudio.Start()
+	err := cmdAudio.Start()
 	if err != nil {
 		panic(fmt.Sprintf("ffmpeg's audio process failed to start! Please check if audio parameters are entered correctly or audio codec is supported by provided container. Error: %s", err))
 	}
 
-	audioBufSize := bass.GetMixerRequiredBufferSize(1 / audioFPS)
+	startAudioWriter()
+}
 
-	audioPool = make(chan []byte, MaxAudioBuffers)
+// startAudioFile writes raw f32le samples to given path, they are encoded when segments are combined
+func startAudioFile(path string) {
+	file, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
 
-	for i := 0; i < MaxAudioBuffers; i++ {
-		audioPool <- make([]byte, audioBufSize)
+	audioPipe = file
+	cmdAudio = nil
+
+	startAudioWriter()
+}
+
+// startStemFiles creates raw files of every stem, suffix is added to their names
+func startStemFiles(suffix string) {
+	if !recordStems {
+		return
+	}
+
+	stemFiles = make([]*os.File, len(bass.StemNames))
+
+	for i, name := range bass.StemNames {
+		file, err := os.Create(tempPath(name + suffix + ".raw"))
+		if err != nil {
+			panic(err)
+		}
+
+		stemFiles[i] = file
 	}
+}
 
+func startAudioWriter() {
 	audioWriteQueue = make(chan []byte, MaxAudioBuffers)
 
 	endSyncAudio = &sync.WaitGroup{}
@@ -105,10 +172,16 @@ func startAudio(audioFPS float64) {
 
 	goroutines.RunOS(func() {
 		for data := range audioWriteQueue {
-			if _, err := audioPipe.Write(data); err != nil {
+			if _, err := audioPipe.Write(data[:audioChunkSize]); err != nil {
 				panic(fmt.Sprintf("ffmpeg's audio process finished abruptly! Please check if you have enough storage or audio parameters are entered correctly. Error: %s", err))
 			}
 
+			for i, file := range stemFiles {
+				if _, err := file.Write(data[audioChunkSize*(i+1) : audioChunkSize*(i+2)]); err != nil {
+					panic(fmt.Sprintf("Failed to write %s stem! Please check if you have enough storage. Error: %s", bass.StemNames[i], err))
+				}
+			}
+
 			audioPool <- data
 		}
 
@@ -116,26 +189,75 @@ func startAudio(audioFPS float64) {
 	})
 }
 
+func getAudioEncodingOptions() []string {
+	var options []string
+
+	audioFilters := strings.TrimSpace(settings.Recording.AudioFilters)
+	if len(audioFilters) > 0 {
+		options = append(options, "-af", audioFilters)
+	}
+
+	options = append(options, "-c:a", settings.Recording.AudioCodec, "-strict", "-2")
+
+	encOptions, err := settings.Recording.GetAudioOptions().GenerateFFmpegArgs()
+	if err != nil {
+		panic(fmt.Sprintf("encoder \"%s\": %s", settings.Recording.AudioCodec, err))
+	} else if encOptions != nil {
+		options = append(options, encOptions...)
+	}
+
+	return options
+}
+
 func stopAudio() {
 	log.Println("Audio finished! Stopping audio pipe...")
 
+	stopAudioWriter()
+
+	if cmdAudio != nil {
+		log.Println("Audio pipe closed. Waiting for audio ffmpeg process to finish...")
+
+		_ = cmdAudio.Wait()
+	}
+
+	log.Println("Audio process finished.")
+}
+
+func stopAudioWriter() {
 	close(audioWriteQueue)
 
 	endSyncAudio.Wait()
 
-	_ = audioPipe.Close()
+	audioWriteQueue = nil
 
-	log.Println("Audio pipe closed. Waiting for audio ffmpeg process to finish...")
+	_ = audioPipe.Close()
 
-	_ = cmdAudio.Wait()
+	for _, file := range stemFiles {
+		_ = file.Close()
+	}
 
-	log.Println("Audio process finished.")
+	stemFiles = nil
 }
 
 func PushAudio() {
 	data := <-audioPool
 
-	bass.ProcessMixer(data)
+	if recordStems {
+		for i := range stemViews {
+			stemViews[i] = data[audioChunkSize*(i+1) : audioChunkSize*(i+2)]
+		}
+
+		bass.ProcessStems(data[:audioChunkSize], stemViews)
+	} else {
+		bass.ProcessMixer(data)
+	}
+
+	audioChunks++
+
+	if audioChunks <= skipAudioChunks { // Mixer is only advanced
+		audioPool <- data
+		return
+	}
 
 	audioWriteQueue <- data
 }
@@ -2,9 +2,11 @@ package ffmpeg
 
 import (
 	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
 	"github.com/wieku/danser-go/app/settings"
 	"github.com/wieku/danser-go/framework/files"
 	"log"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -16,6 +18,8 @@ var ffmpegExec string
 
 var output string
 
+var encoding bool
+
 // check used encoders exist
 func preCheck() {
 	var err error
@@ -44,7 +48,7 @@ func preCheck() {
 		}
 	}
 
-	vcodec := settings.Recording.Encoder
+	vcodec := getVideoEncoder()
 	acodec := settings.Recording.AudioCodec
 	vfound := false
 	afound := false
@@ -73,7 +77,8 @@ func preCheck() {
 	}
 }
 
-func StartFFmpeg(fps, _w, _h int, audioFPS float64, _output string) {
+// StartFFmpeg starts encoding processes, if resume is true and recording is segmented, previously finished segments are kept
+func StartFFmpeg(fps, _w, _h int, audioFPS float64, _output string, resume bool) {
 	preCheck()
 
 	if strings.TrimSpace(_output) == "" {
@@ -84,13 +89,33 @@ func StartFFmpeg(fps, _w, _h int, audioFPS float64, _output string) {
 
 	log.Println("Starting encoding!")
 
-	_ = os.RemoveAll(filepath.Join(settings.Recording.GetOutputDir(), output+"_temp"))
+	resetState()
+
+	encoding = true
+
+	segmented = settings.Recording.SegmentLength > 0 && partCount == 0 && !settings.Recording.IsImageSequence()
+
+	if resume && !segmented {
+		log.Println("Recording.SegmentLength is 0, recording can't be resumed. Starting from scratch")
+	}
+
+	if !resume || !segmented {
+		_ = os.RemoveAll(filepath.Join(settings.Recording.GetOutputDir(), output+"_temp"))
+	}
 
 	err := os.MkdirAll(filepath.Join(settings.Recording.GetOutputDir(), output+"_temp"), 0755)
 	if err != nil && !os.IsExist(err) {
 		panic(err)
 	}
 
+	if partCount > 0 {
+		startPart(fps, _w, _h, audioFPS)
+		return
+	} else if segmented {
+		startSegments(fps, _w, _h, audioFPS, resume)
+		return
+	}
+
 	startVideo(fps, _w, _h)
 	startAudio(audioFPS)
 }
@@ -98,28 +123,141 @@ func StartFFmpeg(fps, _w, _h int, audioFPS float64, _output string) {
 func StopFFmpeg() {
 	log.Println("Finishing rendering...")
 
-	stopVideo()
-	stopAudio()
+	encoding = false
+
+	if partCount > 0 {
+		stopPart()
+
+		log.Println("Ffmpeg finished.")
+
+		return // Parts are joined by the coordinating process
+	} else if segmented {
+		log.Println("Waiting for the last segment to finish...")
+
+		stopSegments()
+	} else {
+		stopVideo()
+		stopAudio()
+	}
+
+	writeMetadata()
+	writeHighlights()
 
 	log.Println("Ffmpeg finished.")
 
 	combine()
 }
 
+// Abort stops encoding processes of a failed recording without joining the output. Intermediate files of segmented recording are kept to be resumed.
+func Abort() {
+	if !encoding {
+		return
+	}
+
+	encoding = false
+
+	log.Println("Aborting encoding...")
+
+	for _, pbo := range frameReadQueue {
+		gl.DeleteSync(pbo.sync)
+		freePBOPool <- pbo
+	}
+
+	frameReadQueue = frameReadQueue[:0]
+
+	if videoWriteQueue != nil {
+		stopVideoProcess()
+	}
+
+	if audioWriteQueue != nil {
+		stopAudioWriter()
+
+		if cmdAudio != nil {
+			_ = cmdAudio.Wait()
+		}
+	}
+
+	if !segmented {
+		cleanup()
+	}
+}
+
+// resetState prepares package's state for a new recording
+func resetState() {
+	frameNumber = -1
+	audioChunks = 0
+	skipAudioChunks = 0
+
+	recordFrom = 0
+	recordTo = math.MaxInt64
+
+	segmentHashes = make(map[int64]uint32)
+	partOutputs = nil
+
+	metadataTags = nil
+	metadataChapters = nil
+
+	recordedHighlights = nil
+
+	cmdVideo = nil
+	cmdAudio = nil
+}
+
 func combine() {
-	options := []string{
-		"-y",
-		"-i", filepath.Join(settings.Recording.GetOutputDir(), output+"_temp", "video."+settings.Recording.Container),
-		"-i", filepath.Join(settings.Recording.GetOutputDir(), output+"_temp", "audio."+settings.Recording.Container),
-		"-c:v", "copy",
-		"-c:a", "copy", "-strict", "-2",
+	if settings.Recording.IsImageSequence() {
+		combineSequence()
+		cleanup()
+
+		return
 	}
 
-	if settings.Recording.Container == "mp4" {
-		options = append(options, "-movflags", "+faststart")
+	options := []string{"-y"}
+
+	var outputOptions []string
+
+	if partOutputs != nil || segmented || rawAudio() {
+		if partOutputs != nil {
+			options = append(options, combineParts()...)
+		} else if segmented {
+			options = append(options, combineSegments()...)
+		} else {
+			options = append(options, "-i", tempPath("video."+settings.Recording.GetContainer()))
+		}
+
+		options = append(options, rawTrackInput("audio")...)
+
+		stemInputs, stemMapping := stemOptions()
+
+		options = append(options, stemInputs...)
+
+		outputOptions = append(stemMapping, "-c:v", "copy")
+		outputOptions = append(outputOptions, getAudioEncodingOptions()...)
+	} else {
+		options = append(options,
+			"-i", tempPath("video."+settings.Recording.GetContainer()),
+			"-i", tempPath("audio."+settings.Recording.GetContainer()),
+		)
+
+		outputOptions = []string{
+			"-c:v", "copy",
+			"-c:a", "copy", "-strict", "-2",
+		}
 	}
 
-	finalOutputPath := filepath.Join(settings.Recording.GetOutputDir(), output+"."+settings.Recording.Container)
+	if metaInput := metadataInput(); metaInput != nil {
+		index := fmt.Sprint(countInputs(options))
+
+		options = append(options, metaInput...)
+		outputOptions = append(outputOptions, "-map_metadata", index, "-map_chapters", index)
+	}
+
+	options = append(options, outputOptions...)
+
+	if container := settings.Recording.GetContainer(); container == "mp4" || container == "mov" {
+		options = append(options, "-movflags", "+faststart+use_metadata_tags")
+	}
+
+	finalOutputPath := settings.Recording.GetOutputPath(output)
 
 	options = append(options, finalOutputPath)
 
@@ -140,16 +278,38 @@ func combine() {
 		} else {
 			log.Println("Finished!")
 			log.Println("Video is available at:", finalOutputPath)
+
+			if settings.Recording.SaveWAV {
+				saveWAV("audio", filepath.Join(settings.Recording.GetOutputDir(), output+".wav"))
+			}
+
+			saveStems()
+
+			extractHighlights(finalOutputPath)
 		}
 	}
 
 	cleanup()
 }
 
+func countInputs(options []string) (count int) {
+	for _, o := range options {
+		if o == "-i" {
+			count++
+		}
+	}
+
+	return
+}
+
 func cleanup() {
 	log.Println("Cleaning up intermediate files...")
 
 	_ = os.RemoveAll(filepath.Join(settings.Recording.GetOutputDir(), output+"_temp"))
 
+	for _, part := range partOutputs {
+		_ = os.RemoveAll(filepath.Join(settings.Recording.GetOutputDir(), part+"_temp"))
+	}
+
 	log.Println("Finished.")
 }
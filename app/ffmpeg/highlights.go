@@ -0,0 +1,241 @@
+package ffmpeg
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+const highlightsName = "highlights.json"
+
+// Highlight is a scored moment of the recording, Time is in milliseconds since the start of the video
+type Highlight struct {
+	Time  float64 `json:"time"`
+	Score float64 `json:"score"`
+	Title string  `json:"title"`
+}
+
+type highlightEntry struct {
+	Title     string  `json:"title"`
+	Score     float64 `json:"score"`
+	Time      float64 `json:"time"`
+	Start     float64 `json:"start"`
+	End       float64 `json:"end"`
+	Thumbnail string  `json:"thumbnail,omitempty"`
+	Clip      string  `json:"clip,omitempty"`
+}
+
+var recordedHighlights []Highlight
+
+// SetHighlights sets moments considered for thumbnails and clips, has to be called before StopFFmpeg
+func SetHighlights(highlights []Highlight) {
+	recordedHighlights = highlights
+}
+
+// writeHighlights saves moments that happened during the recording, every part saves its own
+func writeHighlights() {
+	if !settings.Recording.Highlights.Enabled || len(recordedHighlights) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(recordedHighlights)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = os.WriteFile(tempPath(highlightsName), data, 0644); err != nil {
+		panic(fmt.Sprintf("Failed to write highlights: %s", err))
+	}
+
+	recordedHighlights = nil
+}
+
+// loadHighlights reads moments saved by the recording or all of its parts, moments seen by several parts are merged
+func loadHighlights() (highlights []Highlight) {
+	paths := []string{tempPath(highlightsName)}
+
+	if partOutputs != nil {
+		paths = paths[:0]
+
+		for _, part := range partOutputs {
+			paths = append(paths, filepath.Join(settings.Recording.GetOutputDir(), part+"_temp", highlightsName))
+		}
+	}
+
+	seen := make(map[Highlight]bool)
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		var list []Highlight
+
+		if err = json.Unmarshal(data, &list); err != nil {
+			log.Println("Failed to read highlights:", err)
+			continue
+		}
+
+		for _, h := range list {
+			if !seen[h] {
+				seen[h] = true
+				highlights = append(highlights, h)
+			}
+		}
+	}
+
+	return
+}
+
+// pickHighlights returns the best scored moments, moments closer than the clip length to a better one are skipped
+func pickHighlights(highlights []Highlight, count int, spacing float64) (picked []Highlight) {
+	sort.SliceStable(highlights, func(i, j int) bool {
+		return highlights[i].Score > highlights[j].Score
+	})
+
+	for _, h := range highlights {
+		if len(picked) >= count {
+			break
+		}
+
+		tooClose := false
+
+		for _, p := range picked {
+			if math.Abs(p.Time-h.Time) < spacing {
+				tooClose = true
+				break
+			}
+		}
+
+		if !tooClose {
+			picked = append(picked, h)
+		}
+	}
+
+	return
+}
+
+// extractHighlights saves thumbnails, clips and their time ranges of the best moments from the finished recording
+func extractHighlights(finalOutputPath string) {
+	hSettings := settings.Recording.Highlights
+
+	if !hSettings.Enabled {
+		return
+	}
+
+	highlights := loadHighlights()
+	if len(highlights) == 0 {
+		log.Println("No highlights were found")
+		return
+	}
+
+	clipLength := hSettings.ClipLength * 1000
+
+	picked := pickHighlights(highlights, mutils.Max(hSettings.Thumbnails, hSettings.Clips), clipLength)
+
+	dir := filepath.Join(settings.Recording.GetOutputDir(), output+"_highlights")
+
+	_ = os.RemoveAll(dir)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		panic(err)
+	}
+
+	log.Println("Extracting highlights...")
+
+	entries := make([]highlightEntry, 0, len(picked))
+
+	for i, h := range picked {
+		entry := highlightEntry{
+			Title: h.Title,
+			Score: h.Score,
+			Time:  h.Time / 1000,
+			Start: math.Max(0, h.Time-clipLength*0.6) / 1000,
+		}
+
+		entry.End = entry.Start + clipLength/1000
+
+		if i < hSettings.Thumbnails {
+			entry.Thumbnail = fmt.Sprintf("thumbnail_%02d.png", i+1)
+
+			if err := saveThumbnail(finalOutputPath, h.Time, filepath.Join(dir, entry.Thumbnail)); err != nil {
+				log.Println("Failed to save thumbnail:", err)
+				entry.Thumbnail = ""
+			}
+		}
+
+		if i < hSettings.Clips && !settings.Recording.IsImageSequence() {
+			entry.Clip = fmt.Sprintf("clip_%02d.%s", i+1, settings.Recording.GetContainer())
+
+			if err := saveClip(finalOutputPath, entry.Start, entry.End, filepath.Join(dir, entry.Clip)); err != nil {
+				log.Println("Failed to save clip:", err)
+				entry.Clip = ""
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	data, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		panic(err)
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, highlightsName), data, 0644); err != nil {
+		panic(fmt.Sprintf("Failed to write highlights: %s", err))
+	}
+
+	log.Println("Highlights are available at:", dir)
+}
+
+func saveThumbnail(finalOutputPath string, time float64, path string) error {
+	var options []string
+
+	if settings.Recording.IsImageSequence() {
+		frame := int64(time / 1000 * float64(settings.Recording.FPS))
+
+		options = []string{"-y", "-i", fmt.Sprintf(sequencePattern(finalOutputPath), frame)}
+	} else {
+		options = []string{"-y", "-ss", strconv.FormatFloat(time/1000, 'f', 3, 64), "-i", finalOutputPath}
+	}
+
+	options = append(options, "-frames:v", "1", path)
+
+	return runHighlightCommand(options)
+}
+
+// saveClip cuts the clip without re-encoding, so it starts at the closest keyframe before start
+func saveClip(finalOutputPath string, start, end float64, path string) error {
+	return runHighlightCommand([]string{
+		"-y",
+		"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+		"-i", finalOutputPath,
+		"-t", strconv.FormatFloat(end-start, 'f', 3, 64),
+		"-map", "0",
+		"-map_chapters", "-1",
+		"-c", "copy",
+		path,
+	})
+}
+
+func runHighlightCommand(options []string) error {
+	log.Println("Running ffmpeg with options:", options)
+
+	cmd := exec.Command(ffmpegExec, options...)
+
+	if settings.Recording.ShowFFmpegLogs {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}
+
+	return cmd.Run()
+}
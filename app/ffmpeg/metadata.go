@@ -0,0 +1,98 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const metadataName = "metadata.txt"
+
+// Chapter is a named point of the recording, Time is in milliseconds since the start of the video
+type Chapter struct {
+	Time  float64
+	Title string
+}
+
+var metadataTags map[string]string
+var metadataChapters []Chapter
+
+var metadataEscaper = strings.NewReplacer("\\", "\\\\", "=", "\\=", ";", "\\;", "#", "\\#", "\n", "\\\n")
+
+// SetMetadata sets tags and chapters embedded in the recorded video, has to be called before StopFFmpeg
+func SetMetadata(tags map[string]string, chapters []Chapter) {
+	metadataTags = tags
+	metadataChapters = chapters
+}
+
+// writeMetadata saves tags and chapters in FFMETADATA format, chapters outside the recording are dropped
+func writeMetadata() {
+	if metadataTags == nil && metadataChapters == nil {
+		return
+	}
+
+	duration := float64(frameNumber/oversample+1) * 1000 / float64(videoFPS)
+
+	var sb strings.Builder
+
+	sb.WriteString(";FFMETADATA1\n")
+
+	keys := make([]string, 0, len(metadataTags))
+	for k := range metadataTags {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("%s=%s\n", metadataEscaper.Replace(k), metadataEscaper.Replace(metadataTags[k])))
+	}
+
+	chapters := make([]Chapter, 0, len(metadataChapters))
+
+	for _, c := range metadataChapters {
+		if c.Time >= 0 && c.Time < duration {
+			chapters = append(chapters, c)
+		}
+	}
+
+	sort.SliceStable(chapters, func(i, j int) bool {
+		return chapters[i].Time < chapters[j].Time
+	})
+
+	for i, c := range chapters {
+		end := duration
+		if i < len(chapters)-1 {
+			end = chapters[i+1].Time
+		}
+
+		sb.WriteString(fmt.Sprintf("\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n", int64(c.Time), int64(end), metadataEscaper.Replace(c.Title)))
+	}
+
+	if err := os.WriteFile(tempPath(metadataName), []byte(sb.String()), 0644); err != nil {
+		panic(fmt.Sprintf("Failed to write video metadata: %s", err))
+	}
+
+	metadataTags = nil
+	metadataChapters = nil
+}
+
+// metadataInput returns ffmpeg's input with metadata written by the process that rendered the whole map, nil if there's none
+func metadataInput() []string {
+	path := tempPath(metadataName)
+
+	if partOutputs != nil {
+		path = filepath.Join(filepath.Dir(rawTrackPath("audio")), metadataName)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+
+	return []string{
+		"-f", "ffmetadata",
+		"-i", path,
+	}
+}
@@ -0,0 +1,207 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/bass"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Frames are read back with alpha channel
+var readAlpha bool
+
+func getOutputType() string {
+	return strings.ToLower(settings.Recording.OutputType)
+}
+
+func getVideoEncoder() string {
+	switch getOutputType() {
+	case "ffv1":
+		return "ffv1"
+	case "prores":
+		return "prores_ks"
+	case "png", "tiff", "exr":
+		return getOutputType()
+	}
+
+	return strings.ToLower(settings.Recording.Encoder)
+}
+
+// getLosslessPixelFormat returns pixel format of lossless outputs, frames are always read as RGB(A) and converted by ffmpeg
+func getLosslessPixelFormat() string {
+	switch getOutputType() {
+	case "ffv1":
+		if readAlpha {
+			return "bgra"
+		}
+
+		return "bgr0"
+	case "prores":
+		if readAlpha {
+			return "yuva444p10le"
+		}
+
+		return "yuv444p10le"
+	case "exr":
+		if readAlpha {
+			return "gbrapf32le"
+		}
+
+		return "gbrpf32le"
+	}
+
+	if readAlpha {
+		return "rgba"
+	}
+
+	return "rgb24"
+}
+
+func getLosslessOptions() []string {
+	switch getOutputType() {
+	case "ffv1":
+		return []string{"-level", "3", "-g", "1", "-slicecrc", "1"}
+	case "prores":
+		options := []string{"-profile:v", "4444", "-vendor", "apl0"}
+
+		if readAlpha {
+			options = append(options, "-alpha_bits", "16")
+		}
+
+		return options
+	case "tiff":
+		return []string{"-compression_algo", "deflate"}
+	case "exr":
+		return []string{"-compression", "zip1"}
+	}
+
+	return nil
+}
+
+func sequencePattern(dir string) string {
+	return filepath.Join(dir, "%06d."+settings.Recording.GetContainer())
+}
+
+// rawAudio returns true if audio of a recording in one piece is saved unencoded and encoded at the end
+func rawAudio() bool {
+	return settings.Recording.IsImageSequence() || settings.Recording.SaveWAV || recordStems
+}
+
+// rawTrackPath returns the path of unencoded audio track or stem of the whole recording
+func rawTrackPath(name string) string {
+	if partOutputs != nil {
+		return filepath.Join(settings.Recording.GetOutputDir(), partOutputs[0]+"_temp", name+".raw")
+	}
+
+	return tempPath(name + ".raw")
+}
+
+func rawTrackInput(name string) []string {
+	return []string{
+		"-f", "f32le",
+		"-ar", "48000",
+		"-ac", "2",
+		"-i", rawTrackPath(name),
+	}
+}
+
+// stemsAsWAV returns true if stems are saved as separate files instead of tracks in the video
+func stemsAsWAV() bool {
+	return recordStems && (settings.Recording.AudioStems == "wav" || settings.Recording.IsImageSequence())
+}
+
+// stemOptions returns inputs and stream mapping of stems added as tracks after the main mix
+func stemOptions() (inputs, mapping []string) {
+	if !recordStems || stemsAsWAV() {
+		return nil, nil
+	}
+
+	mapping = []string{"-map", "0:v", "-map", "1:a"}
+
+	for i, name := range bass.StemNames {
+		inputs = append(inputs, rawTrackInput(name)...)
+
+		mapping = append(mapping, "-map", fmt.Sprintf("%d:a", i+2), fmt.Sprintf("-metadata:s:a:%d", i+1), "title="+name)
+	}
+
+	return
+}
+
+// saveStems saves stems as separate WAV files if they are not added to the video
+func saveStems() {
+	if !stemsAsWAV() {
+		return
+	}
+
+	for _, name := range bass.StemNames {
+		saveWAV(name, filepath.Join(settings.Recording.GetOutputDir(), output+"_"+name+".wav"))
+	}
+}
+
+// combineSequence gathers frames recorded by parts into one directory and saves the audio
+func combineSequence() {
+	if partOutputs != nil {
+		dir := settings.Recording.GetOutputPath(output)
+
+		_ = os.RemoveAll(dir)
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			panic(err)
+		}
+
+		for _, part := range partOutputs {
+			partDir := filepath.Join(settings.Recording.GetOutputDir(), part+"_temp", "frames")
+
+			frames, err := os.ReadDir(partDir)
+			if err != nil {
+				log.Println("Skipping empty part:", part)
+				continue
+			}
+
+			for _, frame := range frames {
+				if err = os.Rename(filepath.Join(partDir, frame.Name()), filepath.Join(dir, frame.Name())); err != nil {
+					panic(fmt.Sprintf("Failed to move frames of part %s: %s", part, err))
+				}
+			}
+		}
+	}
+
+	saveWAV("audio", filepath.Join(settings.Recording.GetOutputDir(), output+".wav"))
+	saveStems()
+
+	log.Println("Finished!")
+	log.Println("Frames are available at:", settings.Recording.GetOutputPath(output))
+
+	extractHighlights(settings.Recording.GetOutputPath(output))
+}
+
+// saveWAV encodes the whole raw audio track or stem as a WAV file
+func saveWAV(name, path string) {
+	options := append([]string{"-y"}, rawTrackInput(name)...)
+
+	if audioFilters := strings.TrimSpace(settings.Recording.AudioFilters); len(audioFilters) > 0 {
+		options = append(options, "-af", audioFilters)
+	}
+
+	options = append(options, "-c:a", "pcm_s24le", path)
+
+	log.Println(fmt.Sprintf("Saving %s as WAV...", name))
+	log.Println("Running ffmpeg with options:", options)
+
+	cmd := exec.Command(ffmpegExec, options...)
+
+	if settings.Recording.ShowFFmpegLogs {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}
+
+	if err := cmd.Run(); err != nil {
+		panic(fmt.Sprintf("Failed to save %s as WAV! Please check if you have enough storage. Error: %s", name, err))
+	}
+
+	log.Println("Audio is available at:", path)
+}
@@ -0,0 +1,118 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/settings"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Index and number of parts when recording is split between multiple processes, partCount is 0 if it isn't
+var partIndex, partCount int
+
+// Range of output frames recorded by this part
+var partFrom, partTo int64
+
+// Names of part recordings joined by CombineParts
+var partOutputs []string
+
+// SetPart makes this process record only output frames in [from, to) as index-th of count parts of the whole video.
+// Only the first part records audio, so it has to play the whole map.
+func SetPart(index, count int, from, to int64) {
+	partIndex, partCount = index, count
+	partFrom, partTo = from, to
+}
+
+// Finished returns true if there's nothing left to record
+func Finished() bool {
+	return (frameNumber+1)/oversample >= recordTo && partIndex > 0
+}
+
+func startPart(fps, _w, _h int, audioFPS float64) {
+	recordFrom, recordTo = partFrom, partTo
+
+	initVideo(fps, _w, _h)
+	initAudio(audioFPS)
+
+	if recordTo <= recordFrom {
+		log.Println(fmt.Sprintf("Part %d has no frames to record", partIndex+1))
+	} else if settings.Recording.IsImageSequence() {
+		if err := os.MkdirAll(tempPath("frames"), 0755); err != nil {
+			panic(err)
+		}
+
+		startVideoProcess(sequencePattern(tempPath("frames")))
+	} else {
+		startVideoProcess(tempPath("video." + settings.Recording.GetContainer()))
+	}
+
+	if partIndex == 0 {
+		startAudioFile(tempPath("audio.raw"))
+		startStemFiles("")
+	} else {
+		skipAudioChunks = math.MaxInt64
+	}
+}
+
+func stopPart() {
+	if videoWriteQueue != nil {
+		stopVideoProcess()
+	}
+
+	if partIndex == 0 {
+		stopAudioWriter()
+		writeMetadata()
+	}
+
+	writeHighlights()
+
+	log.Println(fmt.Sprintf("Part %d finished.", partIndex+1))
+}
+
+// CombineParts joins videos recorded by parallel processes into one file, parts have to be given in order
+func CombineParts(_output string, parts []string) {
+	preCheck()
+
+	output = _output
+	partOutputs = parts
+	recordStems = settings.Recording.UsesStems()
+
+	err := os.MkdirAll(tempPath(""), 0755)
+	if err != nil && !os.IsExist(err) {
+		panic(err)
+	}
+
+	combine()
+}
+
+// combineParts returns ffmpeg's input joining videos of all parts
+func combineParts() []string {
+	var list strings.Builder
+
+	for _, part := range partOutputs {
+		path, err := filepath.Abs(filepath.Join(settings.Recording.GetOutputDir(), part+"_temp", "video."+settings.Recording.GetContainer()))
+		if err != nil {
+			panic(err)
+		}
+
+		if _, err = os.Stat(path); err != nil {
+			log.Println("Skipping empty part:", part)
+			continue
+		}
+
+		list.WriteString(fmt.Sprintf("file '%s'\n", strings.ReplaceAll(path, "'", "'\\''")))
+	}
+
+	if err := os.WriteFile(tempPath("parts.txt"), []byte(list.String()), 0644); err != nil {
+		panic(err)
+	}
+
+	return []string{
+		"-f", "concat",
+		"-safe", "0",
+		"-i", tempPath("parts.txt"),
+	}
+}
@@ -0,0 +1,307 @@
+package ffmpeg
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/bass"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const manifestName = "manifest.json"
+
+type segmentParams struct {
+	FPS           int     `json:"fps"`
+	Width         int     `json:"width"`
+	Height        int     `json:"height"`
+	AudioFPS      float64 `json:"audioFPS"`
+	Container     string  `json:"container"`
+	Encoder       string  `json:"encoder"`
+	PixelFormat   string  `json:"pixelFormat"`
+	Oversample    int     `json:"oversample"`
+	SegmentFrames int64   `json:"segmentFrames"`
+	Stems         bool    `json:"stems"`
+}
+
+type segment struct {
+	Index int `json:"index"`
+
+	// Total number of output frames and audio chunks recorded up to the end of this segment
+	Frames      int64 `json:"frames"`
+	AudioChunks int64 `json:"audioChunks"`
+
+	// Checksum of the last frame, used to verify that resumed rendering matches the original one
+	Hash uint32 `json:"hash"`
+}
+
+type manifest struct {
+	Params   segmentParams `json:"params"`
+	Segments []segment     `json:"segments"`
+}
+
+var segmented bool
+
+var currentManifest *manifest
+
+// Number of output frames in one segment
+var segmentFrames int64
+
+var segmentHashes = make(map[int64]uint32)
+
+func tempPath(name string) string {
+	return filepath.Join(settings.Recording.GetOutputDir(), output+"_temp", name)
+}
+
+func segmentVideoName(index int) string {
+	return fmt.Sprintf("video_%04d.%s", index, settings.Recording.GetContainer())
+}
+
+func segmentAudioName(index int) string {
+	return segmentTrackName("audio", index)
+}
+
+func segmentTrackName(name string, index int) string {
+	return fmt.Sprintf("%s_%04d.raw", name, index)
+}
+
+// startSegments prepares segmented recording, if resume is true recording continues after the last finished segment
+func startSegments(fps, _w, _h int, audioFPS float64, resume bool) {
+	initVideo(fps, _w, _h)
+	initAudio(audioFPS)
+
+	segmentFrames = int64(settings.Recording.SegmentLength * videoFPS)
+
+	params := segmentParams{
+		FPS:           videoFPS,
+		Width:         w,
+		Height:        h,
+		AudioFPS:      audioFPS,
+		Container:     settings.Recording.GetContainer(),
+		Encoder:       videoEncoder,
+		PixelFormat:   outputFormat,
+		Oversample:    int(oversample),
+		SegmentFrames: segmentFrames,
+		Stems:         recordStems,
+	}
+
+	resumed := false
+
+	if resume {
+		if loaded, err := loadManifest(); err != nil {
+			log.Println("Can't resume recording, starting from scratch:", err)
+		} else if loaded.Params != params {
+			log.Println("Can't resume recording, recording settings have changed. Starting from scratch")
+		} else if len(loaded.Segments) == 0 {
+			log.Println("Can't resume recording, there are no finished segments. Starting from scratch")
+		} else {
+			currentManifest = loaded
+			resumed = true
+		}
+	}
+
+	if !resumed {
+		currentManifest = &manifest{Params: params}
+	} else {
+		last := currentManifest.Segments[len(currentManifest.Segments)-1]
+
+		recordFrom = last.Frames
+		skipAudioChunks = last.AudioChunks
+
+		log.Println(fmt.Sprintf("Resuming recording after segment %d at frame %d", last.Index+1, last.Frames))
+	}
+
+	startSegment()
+}
+
+func startSegment() {
+	index := len(currentManifest.Segments)
+
+	startVideoProcess(tempPath(segmentVideoName(index)))
+	startAudioFile(tempPath(segmentAudioName(index)))
+	startStemFiles(fmt.Sprintf("_%04d", index))
+}
+
+// finishSegment waits for all frames of current segment to be written and saves it in the manifest
+func finishSegment(lastFrame int64) {
+	stopVideoProcess()
+	stopAudioWriter()
+
+	index := len(currentManifest.Segments)
+
+	currentManifest.Segments = append(currentManifest.Segments, segment{
+		Index:       index,
+		Frames:      lastFrame + 1,
+		AudioChunks: audioChunks,
+		Hash:        segmentHashes[lastFrame],
+	})
+
+	delete(segmentHashes, lastFrame)
+
+	if err := saveManifest(); err != nil {
+		panic(fmt.Sprintf("Failed to save recording manifest: %s", err))
+	}
+
+	log.Println(fmt.Sprintf("Segment %d finished.", index+1))
+}
+
+func stopSegments() {
+	lastFrame := frameNumber / oversample
+
+	if frameNumber >= 0 && lastFrame >= recordFrom && (lastFrame+1)%segmentFrames != 0 {
+		finishSegment(lastFrame)
+		return
+	}
+
+	// Segment was either finished in the last frame or is empty
+	stopVideoProcess()
+	stopAudioWriter()
+}
+
+// onSegmentFrame is called after output frame has been queued, rotates segments and verifies resumed rendering
+func onSegmentFrame(index int64) {
+	if index == recordFrom-1 && audioChunks != skipAudioChunks {
+		panic(fmt.Sprintf("Resumed audio doesn't match the recorded one (expected %d audio chunks, got %d). Rendering is not deterministic with current settings, please render again without -resume", skipAudioChunks, audioChunks))
+	}
+
+	if index < recordFrom || (index+1)%segmentFrames != 0 {
+		return
+	}
+
+	finishSegment(index)
+	startSegment()
+}
+
+// hashFrame stores checksums of segments' last frames and compares them with resumed ones
+func hashFrame(pbo *PBO) {
+	if (pbo.index+1)%segmentFrames != 0 {
+		return
+	}
+
+	hash := crc32.ChecksumIEEE(pbo.data)
+
+	if pbo.index == recordFrom-1 {
+		if expected := currentManifest.Segments[len(currentManifest.Segments)-1].Hash; hash != expected {
+			panic(fmt.Sprintf("Resumed frame %d doesn't match the recorded one (expected checksum %08x, got %08x). Rendering is not deterministic with current settings, please render again without -resume", pbo.index, expected, hash))
+		}
+
+		log.Println("Resumed rendering matches the recorded one, continuing.")
+
+		return
+	}
+
+	segmentHashes[pbo.index] = hash
+}
+
+func loadManifest() (*manifest, error) {
+	file, err := os.Open(tempPath(manifestName))
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	loaded := new(manifest)
+
+	if err = json.NewDecoder(file).Decode(loaded); err != nil {
+		return nil, err
+	}
+
+	for _, s := range loaded.Segments {
+		if _, err = os.Stat(tempPath(segmentVideoName(s.Index))); err != nil {
+			return nil, err
+		}
+
+		if _, err = os.Stat(tempPath(segmentAudioName(s.Index))); err != nil {
+			return nil, err
+		}
+
+		if !loaded.Params.Stems {
+			continue
+		}
+
+		for _, name := range bass.StemNames {
+			if _, err = os.Stat(tempPath(segmentTrackName(name, s.Index))); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return loaded, nil
+}
+
+// saveManifest writes the manifest to a temporary file first, so interrupted recording never leaves a broken one
+func saveManifest() error {
+	data, err := json.MarshalIndent(currentManifest, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := tempPath(manifestName + ".tmp")
+
+	if err = os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, tempPath(manifestName))
+}
+
+// combineSegments joins recorded segments and their audio, returns ffmpeg's video input
+func combineSegments() []string {
+	var list strings.Builder
+
+	for _, s := range currentManifest.Segments {
+		list.WriteString(fmt.Sprintf("file '%s'\n", segmentVideoName(s.Index)))
+	}
+
+	if err := os.WriteFile(tempPath("segments.txt"), []byte(list.String()), 0644); err != nil {
+		panic(err)
+	}
+
+	joinSegmentTracks("audio")
+
+	if recordStems {
+		for _, name := range bass.StemNames {
+			joinSegmentTracks(name)
+		}
+	}
+
+	return []string{
+		"-f", "concat",
+		"-safe", "0",
+		"-i", tempPath("segments.txt"),
+	}
+}
+
+// joinSegmentTracks joins raw audio of all segments into one file
+func joinSegmentTracks(name string) {
+	file, err := os.Create(tempPath(name + ".raw"))
+	if err != nil {
+		panic(err)
+	}
+
+	defer file.Close()
+
+	for _, s := range currentManifest.Segments {
+		if err = appendFile(file, tempPath(segmentTrackName(name, s.Index))); err != nil {
+			panic(fmt.Sprintf("Failed to join %s segments: %s", name, err))
+		}
+	}
+}
+
+func appendFile(dst io.Writer, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	_, err = io.Copy(dst, src)
+
+	return err
+}
@@ -14,9 +14,9 @@ import (
 	"github.com/wieku/danser-go/framework/util/pixconv"
 	"io"
 	"log"
+	"math"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -59,6 +59,9 @@ type PBO struct {
 	sync uintptr
 
 	convertSync *sync.WaitGroup
+
+	// Number of output frame held by this buffer
+	index int64
 }
 
 func createPBO(format pixconv.PixFmt) *PBO {
@@ -67,6 +70,10 @@ func createPBO(format pixconv.PixFmt) *PBO {
 
 	glSize := w * h * 3
 
+	if readAlpha {
+		glSize = w * h * 4
+	}
+
 	if pbo.convFormat == pixconv.I420 || pbo.convFormat == pixconv.NV12 || pbo.convFormat == pixconv.NV21 {
 		glSize = w * h * 3 / 2
 
@@ -92,17 +99,65 @@ func createPBO(format pixconv.PixFmt) *PBO {
 
 var rgbToYuvConverter *effects.RGBYUV
 
+// Parameters of allocated PBOs and effects
+var videoResources string
+
+var videoFPS int
+
+// Rendered frames per output frame
+var oversample int64
+
+// Number of output frames rendered before recordFrom to warm up motion blur and other effects depending on previous frames
+const warmUpSeconds = 1
+
+// Only output frames in [recordFrom, recordTo) are recorded
+var recordFrom int64
+var recordTo int64 = math.MaxInt64
+var videoEncoder string
+var outputFormat string
+var inputPixFmt string
+
 func startVideo(fps, _w, _h int) {
+	initVideo(fps, _w, _h)
+
+	if settings.Recording.IsImageSequence() {
+		dir := settings.Recording.GetOutputPath(output)
+
+		_ = os.RemoveAll(dir)
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			panic(err)
+		}
+
+		startVideoProcess(sequencePattern(dir))
+
+		return
+	}
+
+	startVideoProcess(tempPath("video." + settings.Recording.GetContainer()))
+}
+
+// initVideo prepares pixel format conversion and buffers shared by all video processes
+func initVideo(fps, _w, _h int) {
 	w, h = _w, _h
 
+	oversample = 1
+
 	if settings.Recording.MotionBlur.Enabled {
 		fps /= settings.Recording.MotionBlur.OversampleMultiplier
+		oversample = int64(settings.Recording.MotionBlur.OversampleMultiplier)
 	}
 
-	encoder := strings.ToLower(settings.Recording.Encoder)
-	outputFormat := strings.ToLower(settings.Recording.PixelFormat)
+	videoFPS = fps
+
+	videoEncoder = getVideoEncoder()
+	outputFormat = strings.ToLower(settings.Recording.PixelFormat)
 
-	if strings.HasSuffix(encoder, "_qsv") { // qsv works best with nv12 format
+	readAlpha = settings.Recording.UsesAlpha()
+
+	if getOutputType() != "video" {
+		outputFormat = getLosslessPixelFormat()
+	} else if strings.HasSuffix(videoEncoder, "_qsv") { // qsv works best with nv12 format
 		outputFormat = "nv12"
 	}
 
@@ -121,16 +176,77 @@ func startVideo(fps, _w, _h int) {
 		parsedFormat = pixconv.NV21
 	}
 
-	inputPixFmt := "rgb24"
-	if parsedFormat != pixconv.ARGB {
+	if getOutputType() != "video" { // lossless outputs are converted by ffmpeg
+		parsedFormat = pixconv.ARGB
+	}
+
+	inputPixFmt = "rgb24"
+	if readAlpha {
+		inputPixFmt = "rgba"
+	} else if parsedFormat != pixconv.ARGB {
 		inputPixFmt = outputFormat
 	}
 
+	// GL resources are reused by following recordings if they have the same parameters
+	resources := fmt.Sprintf("%dx%d %d %t %t %d", w, h, parsedFormat, readAlpha, settings.Recording.MotionBlur.Enabled, settings.Recording.MotionBlur.BlendFrames)
+
+	if resources == videoResources {
+		if blend != nil {
+			mainthread.Call(blend.Reset)
+		}
+
+		limiter = frame.NewLimiter(settings.Recording.EncodingFPSCap)
+
+		return
+	}
+
+	videoResources = resources
+
+	oldPool := freePBOPool
+
+	freePBOPool = make(chan *PBO, MaxVideoBuffers)
+
+	mainthread.Call(func() {
+		if oldPool != nil {
+			for i := 0; i < MaxVideoBuffers; i++ {
+				pbo := <-oldPool
+
+				gl.UnmapNamedBuffer(pbo.handle)
+				gl.DeleteBuffers(1, &pbo.handle)
+			}
+		}
+
+		rgbToYuvConverter = nil
+		blend = nil
+
+		if parsedFormat != pixconv.ARGB {
+			rgbToYuvConverter = effects.NewRGBYUV(w, h, parsedFormat != pixconv.I444 && parsedFormat != pixconv.I422)
+		}
+
+		for i := 0; i < MaxVideoBuffers; i++ {
+			freePBOPool <- createPBO(parsedFormat)
+		}
+
+		if settings.Recording.MotionBlur.Enabled {
+			bFrames := settings.Recording.MotionBlur.BlendFrames
+			blend = effects.NewBlend(w, h, bFrames, calculateWeights(bFrames))
+		}
+	})
+
+	limiter = frame.NewLimiter(settings.Recording.EncodingFPSCap)
+}
+
+// startVideoProcess starts ffmpeg process encoding submitted frames to given path
+func startVideoProcess(path string) {
 	videoFilters := strings.TrimSpace(settings.Recording.Filters)
 	if len(videoFilters) > 0 {
 		videoFilters = "," + videoFilters
 	}
 
+	if readAlpha { // Frames are rendered with premultiplied alpha
+		videoFilters = ",unpremultiply=inplace=1" + videoFilters
+	}
+
 	inputName := "-"
 
 	if runtime.GOOS != "windows" {
@@ -150,37 +266,53 @@ func startVideo(fps, _w, _h int) {
 		"-vcodec", "rawvideo",
 		"-s", fmt.Sprintf("%dx%d", w, h), //size of one frame
 		"-pix_fmt", inputPixFmt,
-		"-r", strconv.Itoa(fps), //frames per second
+		"-r", strconv.Itoa(videoFPS), //frames per second
 		"-i", inputName, //The input comes from a videoPipe
 
 		"-an",
 
 		"-vf", "vflip" + videoFilters,
-		"-c:v", encoder,
-		"-color_range", "1",
-		"-colorspace", "1",
-		"-color_trc", "1",
-		"-color_primaries", "1",
-		"-movflags", "+write_colr",
+		"-c:v", videoEncoder,
+	}
+
+	if outputType := getOutputType(); outputType == "video" || outputType == "prores" { // RGB is converted to YUV
+		options = append(options,
+			"-color_range", "1",
+			"-colorspace", "1",
+			"-color_trc", "1",
+			"-color_primaries", "1",
+		)
+	}
+
+	if settings.Recording.IsImageSequence() {
+		options = append(options, "-start_number", strconv.FormatInt(recordFrom, 10))
+	} else {
+		options = append(options, "-movflags", "+write_colr")
 	}
 
 	if parsedFormat == pixconv.ARGB {
 		options = append(options, "-pix_fmt", outputFormat)
 	}
 
-	encOptions, err := settings.Recording.GetEncoderOptions().GenerateFFmpegArgs()
-	if err != nil {
-		panic(fmt.Sprintf("encoder \"%s\": %s", encoder, err))
-	} else if encOptions != nil {
-		options = append(options, encOptions...)
+	if getOutputType() != "video" {
+		options = append(options, getLosslessOptions()...)
+	} else {
+		encOptions, err := settings.Recording.GetEncoderOptions().GenerateFFmpegArgs()
+		if err != nil {
+			panic(fmt.Sprintf("encoder \"%s\": %s", videoEncoder, err))
+		} else if encOptions != nil {
+			options = append(options, encOptions...)
+		}
 	}
 
-	options = append(options, filepath.Join(settings.Recording.GetOutputDir(), output+"_temp", "video."+settings.Recording.Container))
+	options = append(options, path)
 
 	log.Println("Running ffmpeg with options:", options)
 
 	cmdVideo = exec.Command(ffmpegExec, options...)
 
+	var err error
+
 	if runtime.GOOS == "windows" {
 		videoPipe, err = cmdVideo.StdinPipe()
 		if err != nil {
@@ -209,26 +341,9 @@ func startVideo(fps, _w, _h int) {
 		panic(fmt.Sprintf("ffmpeg's video process failed to start! Please check if video parameters are entered correctly or video codec is supported by provided container. Error: %s", err))
 	}
 
-	freePBOPool = make(chan *PBO, MaxVideoBuffers)
-
-	mainthread.Call(func() {
-		if parsedFormat != pixconv.ARGB {
-			rgbToYuvConverter = effects.NewRGBYUV(w, h, parsedFormat != pixconv.I444 && parsedFormat != pixconv.I422)
-		}
-
-		for i := 0; i < MaxVideoBuffers; i++ {
-			freePBOPool <- createPBO(parsedFormat)
-		}
-
-		if settings.Recording.MotionBlur.Enabled {
-			bFrames := settings.Recording.MotionBlur.BlendFrames
-			blend = effects.NewBlend(w, h, bFrames, calculateWeights(bFrames))
-		}
-	})
-
 	videoWriteQueue = make(chan *PBO, MaxVideoBuffers)
 
-	limiter = frame.NewLimiter(settings.Recording.EncodingFPSCap)
+	videoError = ""
 
 	videoErrorWait = &sync.WaitGroup{}
 	videoErrorWait.Add(1)
@@ -255,7 +370,7 @@ func startVideo(fps, _w, _h int) {
 					strings.Contains(lineLower, "no capable devices found") ||
 					strings.Contains(lineLower, "does not support") {
 
-					videoError = encoder + ": " + cutLine
+					videoError = videoEncoder + ": " + cutLine
 
 					oFile.Close()
 				}
@@ -294,12 +409,21 @@ func startVideo(fps, _w, _h int) {
 func stopVideo() {
 	log.Println("Waiting for video to finish writing...")
 
+	stopVideoProcess()
+
+	log.Println("Video process finished.")
+}
+
+// stopVideoProcess writes all pending frames and waits for ffmpeg to finish encoding them
+func stopVideoProcess() {
 	checkData(true, true)
 
 	close(videoWriteQueue)
 
 	endSyncVideo.Wait()
 
+	videoWriteQueue = nil
+
 	log.Println("Finished! Stopping video pipe...")
 
 	_ = videoPipe.Close()
@@ -307,8 +431,6 @@ func stopVideo() {
 	log.Println("Video pipe closed. Waiting for video ffmpeg process to finish...")
 
 	_ = cmdVideo.Wait()
-
-	log.Println("Video process finished.")
 }
 
 func PreFrame() {
@@ -321,6 +443,20 @@ func PreFrame() {
 
 var frameNumber = int64(-1)
 
+// SkipFrame advances the frame counter without rendering if the frame is outside recorded range
+// and is not needed to warm up the rendering. Returns false if frame has to be rendered.
+func SkipFrame() bool {
+	warmUpStart := (recordFrom - int64(warmUpSeconds*videoFPS)) * oversample
+
+	if frameNumber+1 >= warmUpStart && (frameNumber+1)/oversample < recordTo {
+		return false
+	}
+
+	frameNumber++
+
+	return true
+}
+
 func MakeFrame() {
 	frameNumber++
 
@@ -366,17 +502,28 @@ func MakeFrame() {
 		gl.GetTextureSubImage(yuvFull.GetID(), 0, 0, 0, 0, int32(w), int32(h), 1, gl.GREEN, gl.UNSIGNED_BYTE, int32(w*h), gl.PtrOffset(w*h))
 		gl.GetTextureSubImage(yuvFull.GetID(), 0, 0, 0, 0, int32(w), int32(h), 1, gl.BLUE, gl.UNSIGNED_BYTE, int32(w*h), gl.PtrOffset(w*h*2))
 	} else {
-		gl.ReadPixels(0, 0, int32(w), int32(h), uint32(gl.RGB), gl.UNSIGNED_BYTE, gl.Ptr(nil))
+		format := uint32(gl.RGB)
+		if readAlpha {
+			format = gl.RGBA
+		}
+
+		gl.ReadPixels(0, 0, int32(w), int32(h), format, gl.UNSIGNED_BYTE, gl.Ptr(nil))
 	}
 
 	pbo.sync = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
 
 	gl.Flush()
 
+	pbo.index = frameNumber / oversample
+
 	frameReadQueue = append(frameReadQueue, pbo)
 
 	checkData(false, false)
 
+	if segmented {
+		onSegmentFrame(pbo.index)
+	}
+
 	limiter.Sync()
 }
 
@@ -411,6 +558,15 @@ func checkData(waitForFirst, waitForAll bool) { // I tried to do that on another
 }
 
 func submitFrame(pbo *PBO) {
+	if segmented {
+		hashFrame(pbo)
+	}
+
+	if pbo.index < recordFrom { // Frame was rendered only to warm up or verify the rendering
+		freePBOPool <- pbo
+		return
+	}
+
 	if pbo.convFormat == pixconv.I444 || pbo.convFormat == pixconv.I420 || pbo.convFormat == pixconv.ARGB { // For yuv444p and yuv420p or raw just dump the frame
 		pbo.convData = pbo.data
 	} else {
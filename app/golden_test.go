@@ -0,0 +1,205 @@
+//go:build golden
+
+package app
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/wieku/danser-go/framework/util/imgdiff"
+)
+
+// Golden-image tests render frames with a built danser in screenshot mode and compare them with stored images.
+// They need a danser build and Mesa's llvmpipe, so they are built only with golden tag:
+//
+//	DANSER_EXEC=/path/to/danser go test -tags golden ./app -run TestGolden
+//
+// Add -update to replace stored images with the current rendering after an intended visual change.
+// Cases are listed in testdata/golden/cases.json, e.g.:
+//
+//	[{"name": "hr_slider", "args": ["-md5=...", "-mods=HR"], "times": [12.5, 40]}]
+//
+// Before rendering, testdata/golden/settings.json is installed as "golden" settings and maps from testdata/golden/songs are copied
+// to "golden_songs" in danser's data directory, which these settings use as the Songs folder.
+
+var updateGolden = flag.Bool("update", false, "Replace golden images with rendered frames")
+
+const goldenDir = "testdata/golden"
+
+var defaultTolerance = imgdiff.Tolerance{
+	Channel: 8,
+	Pixels:  0.001,
+}
+
+type goldenCase struct {
+	// Prefix of golden images of this case
+	Name string `json:"name"`
+
+	// Flags selecting the beatmap and the way it's played, e.g. -md5, -mods, -replay or -skin
+	Args []string `json:"args"`
+
+	// Times of rendered frames in seconds
+	Times []float64 `json:"times"`
+
+	Tolerance *imgdiff.Tolerance `json:"tolerance,omitempty"`
+}
+
+func loadGoldenCases(t *testing.T) (cases []goldenCase) {
+	data, err := os.ReadFile(filepath.Join(goldenDir, "cases.json"))
+	if err != nil {
+		t.Fatal("Failed to read golden cases:", err)
+	}
+
+	if err = json.Unmarshal(data, &cases); err != nil {
+		t.Fatal("Failed to parse golden cases:", err)
+	}
+
+	return
+}
+
+func TestGolden(t *testing.T) {
+	danserExec := os.Getenv("DANSER_EXEC")
+	if danserExec == "" {
+		t.Skip("DANSER_EXEC is not set")
+	}
+
+	// Screenshots are saved in danser's data directory, which is the executable's directory unless it's installed as a package
+	dataDir := os.Getenv("DANSER_DATA_DIR")
+	if dataDir == "" {
+		dataDir = filepath.Dir(danserExec)
+	}
+
+	configDir := os.Getenv("DANSER_CONFIG_DIR")
+	if configDir == "" {
+		configDir = filepath.Join(dataDir, "settings")
+	}
+
+	installGoldenData(t, dataDir, configDir)
+
+	for _, c := range loadGoldenCases(t) {
+		tolerance := defaultTolerance
+		if c.Tolerance != nil {
+			tolerance = *c.Tolerance
+		}
+
+		for _, tm := range c.Times {
+			c, tm := c, tm
+
+			name := fmt.Sprintf("%s_%d", c.Name, int64(tm*1000))
+
+			t.Run(name, func(t *testing.T) {
+				actual := renderGoldenFrame(t, danserExec, dataDir, name, c.Args, tm)
+
+				goldenPath := filepath.Join(goldenDir, name+".png")
+
+				if *updateGolden {
+					if err := imgdiff.SavePNG(goldenPath, actual); err != nil {
+						t.Fatal("Failed to save golden image:", err)
+					}
+
+					return
+				}
+
+				expected, err := imgdiff.LoadPNG(goldenPath)
+				if err != nil {
+					t.Fatalf("Failed to load golden image, run with -update to create it: %s", err)
+				}
+
+				result, err := imgdiff.Compare(expected, actual, tolerance)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !result.Passes(tolerance) {
+					failedDir := filepath.Join(os.TempDir(), "danser-golden")
+
+					_ = imgdiff.SavePNG(filepath.Join(failedDir, name+"_actual.png"), actual)
+					_ = imgdiff.SavePNG(filepath.Join(failedDir, name+"_diff.png"), result.Diff)
+
+					t.Errorf("%d of %d pixels (%.3f%%) differ, max channel difference: %d. Rendered frame and diff are saved in %s",
+						result.Different, result.Total, result.Ratio()*100, result.MaxDifference, failedDir)
+				}
+			})
+		}
+	}
+}
+
+// installGoldenData copies golden settings and maps to danser's directories, replacing versions left by previous runs
+func installGoldenData(t *testing.T, dataDir, configDir string) {
+	if err := copyGoldenFile(filepath.Join(goldenDir, "settings.json"), filepath.Join(configDir, "golden.json")); err != nil {
+		t.Fatal("Failed to install golden settings:", err)
+	}
+
+	songsDir := filepath.Join(goldenDir, "songs")
+
+	err := filepath.WalkDir(songsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(songsDir, path)
+		if err != nil {
+			return err
+		}
+
+		return copyGoldenFile(path, filepath.Join(dataDir, "golden_songs", rel))
+	})
+
+	if err != nil {
+		t.Fatal("Failed to install golden maps:", err)
+	}
+}
+
+func copyGoldenFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(dst, data, 0644)
+}
+
+// renderGoldenFrame renders a single frame on CPU with settings/golden.json, so local settings don't affect the result
+func renderGoldenFrame(t *testing.T, danserExec, dataDir, name string, args []string, time float64) image.Image {
+	out := "golden_" + name
+	path := filepath.Join(dataDir, "screenshots", out+".png")
+
+	_ = os.Remove(path)
+
+	options := append([]string{
+		"-software",
+		"-noupdatecheck",
+		"-nodbcheck",
+		"-settings=golden",
+		"-ss=" + strconv.FormatFloat(time, 'f', -1, 64),
+		"-out=" + out,
+	}, args...)
+
+	cmd := exec.Command(danserExec, options...)
+	cmd.Dir = filepath.Dir(danserExec)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("danser failed: %s\n%s", err, output)
+	}
+
+	img, err := imgdiff.LoadPNG(path)
+	if err != nil {
+		t.Fatal("Frame was not rendered:", err)
+	}
+
+	_ = os.Remove(path)
+
+	return img
+}
@@ -2,7 +2,6 @@ package graphics
 
 import (
 	"math"
-	"math/rand"
 	"time"
 
 	"github.com/go-gl/mathgl/mgl32"
@@ -19,6 +18,7 @@ import (
 	color2 "github.com/wieku/danser-go/framework/math/color"
 	"github.com/wieku/danser-go/framework/math/math32"
 	"github.com/wieku/danser-go/framework/math/vector"
+	"github.com/wieku/danser-go/framework/util"
 )
 
 type cursorRenderer interface {
@@ -241,7 +241,7 @@ func (cursor *Cursor) smokeUpdate() {
 
 				smoke := sprite.NewSpriteSingle(cursor.smokeTexture, cursor.time*1000+float64(i), temp.Copy64(), vector.Centre)
 				smoke.SetAdditive(true)
-				smoke.SetRotation(rand.Float64() * 2 * math.Pi)
+				smoke.SetRotation(util.RandomFloat64() * 2 * math.Pi)
 				smoke.SetScale(0.5 / scaling)
 				smoke.AddTransform(animation.NewSingleTransform(animation.Fade, easing.Linear, cursor.time, cursor.time+4000, 0.6, 0.0))
 				smoke.ResetValuesToTransforms()
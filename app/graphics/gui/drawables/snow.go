@@ -9,8 +9,8 @@ import (
 	color2 "github.com/wieku/danser-go/framework/math/color"
 	"github.com/wieku/danser-go/framework/math/mutils"
 	"github.com/wieku/danser-go/framework/math/vector"
+	"github.com/wieku/danser-go/framework/util"
 	"math"
-	"math/rand"
 )
 
 type Snowflake struct {
@@ -39,26 +39,26 @@ func NewSnow() *Snow {
 }
 
 func (vis *Snow) AddSnowflake(onscreen bool) {
-	size := (minSize + rand.Float64()*(maxSize-minSize)) * settings.Graphics.GetHeightF() / 768 * 0.15
-	position := vector.NewVec2d((rand.Float64()*1.4-0.2)*settings.Graphics.GetWidthF(), -size)
+	size := (minSize + util.RandomFloat64()*(maxSize-minSize)) * settings.Graphics.GetHeightF() / 768 * 0.15
+	position := vector.NewVec2d((util.RandomFloat64()*1.4-0.2)*settings.Graphics.GetWidthF(), -size)
 
 	if onscreen {
-		position.Y = rand.Float64() * settings.Graphics.GetHeightF()
+		position.Y = util.RandomFloat64() * settings.Graphics.GetHeightF()
 	}
 
-	texture := graphics.Snowflakes[rand.Intn(len(graphics.Snowflakes))]
+	texture := graphics.Snowflakes[util.RandomIntn(len(graphics.Snowflakes))]
 
 	snowflake := &Snowflake{
 		Sprite:   sprite.NewSpriteSingle(texture, -size, position, vector.Centre),
-		horizVel: (rand.Float64() - 0.5) / 8,
-		wind:     (rand.Float64() - 0.5) / 4000,
+		horizVel: (util.RandomFloat64() - 0.5) / 8,
+		wind:     (util.RandomFloat64() - 0.5) / 4000,
 	}
 
-	snowflake.SetColor(color2.NewL(1 - rand.Float32()*0.3))
-	snowflake.SetRotation(rand.Float64() * math.Pi * 2)
+	snowflake.SetColor(color2.NewL(1 - util.RandomFloat32()*0.3))
+	snowflake.SetRotation(util.RandomFloat64() * math.Pi * 2)
 	snowflake.SetScale(size / float64(snowflake.Texture.Height))
 	snowflake.SetAdditive(true)
-	snowflake.SetAlpha(0.4 + rand.Float32()*0.3)
+	snowflake.SetAlpha(0.4 + util.RandomFloat32()*0.3)
 
 	vis.manager.Add(snowflake)
 }
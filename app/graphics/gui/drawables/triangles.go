@@ -8,8 +8,8 @@ import (
 	"github.com/wieku/danser-go/framework/graphics/sprite"
 	color2 "github.com/wieku/danser-go/framework/math/color"
 	"github.com/wieku/danser-go/framework/math/vector"
+	"github.com/wieku/danser-go/framework/util"
 	"math"
-	"math/rand"
 )
 
 const baseSpeed = 100.0
@@ -60,8 +60,8 @@ func (vis *Triangles) SetTrack(track bass.ITrack) {
 }
 
 func (vis *Triangles) AddTriangle(onscreen bool) {
-	size := (minSize + rand.Float64()*(maxSize-minSize)) * settings.Graphics.GetHeightF() / 768 * vis.scale
-	position := vector.NewVec2d((rand.Float64()-0.5)*settings.Graphics.GetWidthF(), settings.Graphics.GetHeightF()/2+size)
+	size := (minSize + util.RandomFloat64()*(maxSize-minSize)) * settings.Graphics.GetHeightF() / 768 * vis.scale
+	position := vector.NewVec2d((util.RandomFloat64()-0.5)*settings.Graphics.GetWidthF(), settings.Graphics.GetHeightF()/2+size)
 
 	texture := graphics.Triangle
 	if settings.Playfield.Background.Triangles.Shadowed {
@@ -70,8 +70,8 @@ func (vis *Triangles) AddTriangle(onscreen bool) {
 
 	triangle := &Triangle{
 		Sprite: sprite.NewSpriteSingle(texture, -size, position, vector.NewVec2d(0, 0)),
-		shade:  rand.Float32() * 0.2,
-		cIndex: rand.Int(),
+		shade:  util.RandomFloat32() * 0.2,
+		cIndex: util.RandomInt(),
 	}
 
 	if vis.colorPalette == nil || len(vis.colorPalette) == 0 {
@@ -80,11 +80,11 @@ func (vis *Triangles) AddTriangle(onscreen bool) {
 		triangle.SetColor(vis.colorPalette[triangle.cIndex%len(vis.colorPalette)])
 	}
 
-	triangle.SetVFlip(rand.Float64() >= 0.5)
+	triangle.SetVFlip(util.RandomFloat64() >= 0.5)
 	triangle.SetScale(size / float64(graphics.Triangle.Height))
 
 	if onscreen {
-		triangle.SetPosition(vector.NewVec2d(triangle.GetPosition().X, -(rand.Float64()-0.5)*(settings.Graphics.GetHeightF()+size)))
+		triangle.SetPosition(vector.NewVec2d(triangle.GetPosition().X, -(util.RandomFloat64()-0.5)*(settings.Graphics.GetHeightF()+size)))
 	}
 
 	vis.manager.Add(triangle)
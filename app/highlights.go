@@ -0,0 +1,129 @@
+package app
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/ffmpeg"
+	"github.com/wieku/danser-go/app/graphics"
+	"github.com/wieku/danser-go/app/rulesets/osu"
+	"github.com/wieku/danser-go/app/rulesets/osu/performance/pp220930"
+	"github.com/wieku/danser-go/app/states"
+	"github.com/wieku/danser-go/app/states/components/overlays"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"math"
+)
+
+const (
+	fcScore          = 10.0
+	eliminationScore = 3.0
+	minPPJump        = 5.0
+	ppJumpScale      = 5.0
+	maxPPJumpScore   = 4.0
+	kiaiComboScale   = 200.0
+)
+
+// highlightTracker scores moments of the recorded play that are worth a thumbnail or a clip
+type highlightTracker struct {
+	player  *states.Player
+	ruleset *osu.OsuRuleSet
+
+	highlights []ffmpeg.Highlight
+
+	combos map[*graphics.Cursor]int64
+	pp     map[*graphics.Cursor]float64
+
+	kiaiHighlights map[float64]int // Index of the best moment of every kiai section
+	fcHighlights   map[*graphics.Cursor]int
+}
+
+func newHighlightTracker(p *states.Player) *highlightTracker {
+	tracker := &highlightTracker{
+		player:         p,
+		combos:         make(map[*graphics.Cursor]int64),
+		pp:             make(map[*graphics.Cursor]float64),
+		kiaiHighlights: make(map[float64]int),
+		fcHighlights:   make(map[*graphics.Cursor]int),
+	}
+
+	if controller, ok := p.GetController().(interface{ GetRuleset() *osu.OsuRuleSet }); ok {
+		tracker.ruleset = controller.GetRuleset()
+		tracker.ruleset.AddListener(tracker.hitReceived)
+	}
+
+	return tracker
+}
+
+func (tracker *highlightTracker) hitReceived(cursor *graphics.Cursor, time int64, number int64, _ vector.Vector2d, result osu.HitResult, comboResult osu.ComboResult, ppResults pp220930.PPv2Results, _ int64) {
+	if result == osu.PositionalMiss {
+		return
+	}
+
+	bMap := tracker.player.GetBeatMap()
+
+	switch comboResult {
+	case osu.Reset:
+		tracker.combos[cursor] = 0
+	case osu.Increase:
+		tracker.combos[cursor]++
+	}
+
+	combo := tracker.combos[cursor]
+
+	if point := bMap.Timings.GetPointAt(float64(time)); point.Kiai && combo > 0 {
+		score := 1 + 2*float64(combo)/(float64(combo)+kiaiComboScale)
+		title := fmt.Sprintf("%s: %dx combo in kiai", cursor.Name, combo)
+
+		if index, ok := tracker.kiaiHighlights[point.Time]; ok {
+			if tracker.highlights[index].Score < score {
+				tracker.highlights[index] = tracker.newHighlight(time, score, title)
+			}
+		} else {
+			tracker.kiaiHighlights[point.Time] = tracker.add(time, score, title)
+		}
+	}
+
+	if lastPP, ok := tracker.pp[cursor]; ok && ppResults.Total-lastPP >= minPPJump {
+		jump := ppResults.Total - lastPP
+
+		tracker.add(time, math.Min(jump/ppJumpScale, maxPPJumpScore), fmt.Sprintf("%s: +%.0fpp", cursor.Name, jump))
+	}
+
+	tracker.pp[cursor] = ppResults.Total
+
+	if number == int64(len(bMap.HitObjects)-1) && tracker.ruleset != nil {
+		if score := tracker.ruleset.GetScore(cursor); score.PerfectCombo && score.CountMiss == 0 {
+			highlight := tracker.newHighlight(time, fcScore, fmt.Sprintf("%s: full combo", cursor.Name))
+
+			if index, ok := tracker.fcHighlights[cursor]; ok { // Slider ends come after its head
+				tracker.highlights[index] = highlight
+			} else {
+				tracker.fcHighlights[cursor] = len(tracker.highlights)
+				tracker.highlights = append(tracker.highlights, highlight)
+			}
+		}
+	}
+}
+
+func (tracker *highlightTracker) newHighlight(time int64, score float64, title string) ffmpeg.Highlight {
+	return ffmpeg.Highlight{
+		Time:  tracker.player.GetRecordingTime(float64(time)),
+		Score: score,
+		Title: title,
+	}
+}
+
+func (tracker *highlightTracker) add(time int64, score float64, title string) int {
+	tracker.highlights = append(tracker.highlights, tracker.newHighlight(time, score, title))
+
+	return len(tracker.highlights) - 1
+}
+
+// finish adds knockout eliminations and passes collected moments to the encoder
+func (tracker *highlightTracker) finish() {
+	if knockout, ok := tracker.player.GetOverlay().(overlays.Knockout); ok {
+		for _, e := range knockout.GetEliminations() {
+			tracker.add(e.Time, eliminationScore, e.Name+" eliminated")
+		}
+	}
+
+	ffmpeg.SetHighlights(tracker.highlights)
+}
@@ -8,14 +8,39 @@ var Focused = true
 
 type KeyListener glfw.KeyCallback
 
-var listeners []KeyListener
+type registeredListener struct {
+	id       int
+	listener KeyListener
+}
+
+var listeners []registeredListener
+
+var lastListenerID int
+
+// RegisterListener adds a key listener, returned id can be passed to UnregisterListener
+func RegisterListener(listener KeyListener) int {
+	lastListenerID++
+
+	listeners = append(listeners, registeredListener{id: lastListenerID, listener: listener})
+
+	return lastListenerID
+}
+
+// UnregisterListener removes listener added by RegisterListener. A new slice is created, so listeners can be removed while they are called.
+func UnregisterListener(id int) {
+	newListeners := make([]registeredListener, 0, len(listeners))
+
+	for _, l := range listeners {
+		if l.id != id {
+			newListeners = append(newListeners, l)
+		}
+	}
 
-func RegisterListener(listener KeyListener) {
-	listeners = append(listeners, listener)
+	listeners = newListeners
 }
 
 func CallListeners(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
 	for _, l := range listeners {
-		l(w, key, scancode, action, mods)
+		l.listener(w, key, scancode, action, mods)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/dance"
+	"github.com/wieku/danser-go/app/ffmpeg"
+	"github.com/wieku/danser-go/app/states"
+	"github.com/wieku/danser-go/app/states/components/overlays"
+	"strings"
+)
+
+// setRecordingMetadata passes beatmap and play info with chapters of the recorded map to the encoder
+func setRecordingMetadata(p *states.Player) {
+	bMap := p.GetBeatMap()
+
+	tags := map[string]string{
+		"title":      fmt.Sprintf("%s - %s [%s]", bMap.Artist, bMap.Name, bMap.Difficulty),
+		"artist":     bMap.Artist,
+		"song":       bMap.Name,
+		"difficulty": bMap.Difficulty,
+		"mapper":     bMap.Creator,
+		"mods":       bMap.Diff.Mods.String(),
+		"comment":    "Recorded with danser",
+	}
+
+	if controller, ok := p.GetController().(*dance.ReplayController); ok {
+		replays := controller.GetReplays()
+
+		names := make([]string, 0, len(replays))
+		for _, r := range replays {
+			names = append(names, r.Name)
+		}
+
+		tags["player"] = strings.Join(names, ", ")
+
+		if len(replays) == 1 {
+			score := controller.GetRuleset().GetScore(controller.GetCursors()[0])
+
+			tags["mods"] = replays[0].Mods
+			tags["score"] = fmt.Sprint(score.Score)
+			tags["accuracy"] = fmt.Sprintf("%.2f", score.Accuracy)
+		}
+	} else {
+		tags["player"] = "danser"
+	}
+
+	ffmpeg.SetMetadata(tags, getRecordingChapters(p))
+}
+
+func getRecordingChapters(p *states.Player) []ffmpeg.Chapter {
+	bMap := p.GetBeatMap()
+
+	chapters := []ffmpeg.Chapter{{Time: 0, Title: "Intro"}}
+
+	addChapter := func(time float64, title string) {
+		chapters = append(chapters, ffmpeg.Chapter{Time: p.GetRecordingTime(time), Title: title})
+	}
+
+	if len(bMap.HitObjects) > 0 {
+		addChapter(bMap.HitObjects[0].GetStartTime(), "Map start")
+	}
+
+	for _, pause := range bMap.Pauses {
+		addChapter(pause.GetStartTime(), "Break")
+		addChapter(pause.GetEndTime(), "Break end")
+	}
+
+	kiai := false
+
+	for _, point := range bMap.Timings.GetPoints() {
+		if point.Kiai == kiai {
+			continue
+		}
+
+		kiai = point.Kiai
+
+		if kiai {
+			addChapter(point.Time, "Kiai")
+		} else {
+			addChapter(point.Time, "Kiai end")
+		}
+	}
+
+	if knockout, ok := p.GetOverlay().(overlays.Knockout); ok {
+		for _, e := range knockout.GetEliminations() {
+			addChapter(float64(e.Time), e.Name+" eliminated")
+		}
+	}
+
+	return chapters
+}
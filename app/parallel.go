@@ -0,0 +1,202 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/ffmpeg"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states"
+	"github.com/wieku/danser-go/framework/goroutines"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Flags that are replaced or dropped in worker processes, value tells if the flag takes an argument
+var coordinatorFlags = map[string]bool{
+	"workers":       true,
+	"out":           true,
+	"record":        false,
+	"resume":        false,
+	"nodbcheck":     false,
+	"noupdatecheck": false,
+}
+
+// Map time in ms played before and after worker's part, so objects, cursors and effects depending on the past look like in the whole recording
+const partLeadIn = 5000.0
+
+// Map time range recorded by this worker
+var partStart, partEnd = math.Inf(-1), math.Inf(1)
+
+// runWorkers splits recording between given number of danser processes running in parallel and joins their videos
+func runWorkers(workers int, out string) {
+	if strings.TrimSpace(out) == "" {
+		out = "danser_" + time.Now().Format("2006-01-02_15-04-05")
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+
+	args := filterCoordinatorArgs(os.Args[1:])
+
+	log.Println(fmt.Sprintf("Recording with %d parallel workers...", workers))
+
+	parts := make([]string, workers)
+	errs := make([]error, workers)
+
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < workers; i++ {
+		parts[i] = fmt.Sprintf("%s_part%d", out, i)
+
+		wArgs := append(append([]string{}, args...), "-nodbcheck", "-noupdatecheck", "-out="+parts[i], fmt.Sprintf("-part=%d/%d", i, workers))
+
+		cmd := exec.Command(executable, wArgs...)
+
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			panic(err)
+		}
+
+		cmd.Stderr = cmd.Stdout
+
+		if err = cmd.Start(); err != nil {
+			panic(fmt.Sprintf("Failed to start worker %d: %s", i+1, err))
+		}
+
+		wg.Add(1)
+
+		index := i
+
+		goroutines.Run(func() {
+			defer wg.Done()
+
+			forwardOutput(stdout, fmt.Sprintf("[%d/%d] ", index+1, workers))
+
+			errs[index] = cmd.Wait()
+		})
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			panic(fmt.Sprintf("Worker %d failed: %s. Intermediate files are kept in \"%s_temp\"", i+1, err, parts[i]))
+		}
+	}
+
+	log.Println("All workers finished, joining parts...")
+
+	ffmpeg.CombineParts(out, parts)
+}
+
+func forwardOutput(reader io.Reader, prefix string) {
+	sc := bufio.NewScanner(reader)
+
+	for sc.Scan() {
+		fmt.Println(prefix + sc.Text())
+	}
+}
+
+func filterCoordinatorArgs(args []string) []string {
+	filtered := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		name := strings.TrimLeft(args[i], "-")
+
+		hasValue := strings.Contains(name, "=")
+		if hasValue {
+			name = name[:strings.Index(name, "=")]
+		}
+
+		takesArg, ok := coordinatorFlags[name]
+		if !ok || !strings.HasPrefix(args[i], "-") {
+			filtered = append(filtered, args[i])
+			continue
+		}
+
+		if takesArg && !hasValue {
+			i++ // skip flag's value
+		}
+	}
+
+	return filtered
+}
+
+// limitToPart splits the map evenly between workers by time of its objects and limits the map to this worker's part with lead-in.
+// First part records audio of the whole map, so it plays the map from the start. It has to be called before creating the Player.
+func limitToPart(beatMap *beatmap.BeatMap) {
+	objects := beatMap.HitObjects
+
+	first := math.Max(objects[0].GetStartTime(), settings.START*1000)
+	last := math.Min(objects[len(objects)-1].GetEndTime(), settings.END*1000)
+
+	boundary := func(index int) float64 {
+		return first + (last-first)*float64(index)/float64(partCount)
+	}
+
+	if partIndex < partCount-1 {
+		partEnd = boundary(partIndex + 1)
+	}
+
+	if partIndex == 0 {
+		return
+	}
+
+	partStart = boundary(partIndex)
+
+	// Objects still visible when the lead-in starts are kept
+	start := partStart - partLeadIn
+
+	for i := len(objects) - 1; i >= 0; i-- {
+		if objects[i].GetStartTime() <= start && objects[i].GetEndTime() >= start {
+			start = objects[i].GetStartTime() - 1
+		}
+	}
+
+	if start > first {
+		settings.START = start / 1000
+	}
+
+	if math.IsInf(partEnd, 1) {
+		return
+	}
+
+	// Objects already visible when the part ends are kept
+	end := partEnd + partLeadIn
+
+	for _, o := range objects {
+		if o.GetStartTime() <= end && o.GetEndTime() >= end {
+			end = o.GetEndTime() + 1
+		}
+	}
+
+	settings.END = math.Min(settings.END, end/1000)
+}
+
+// setupPart aligns frames of the player with frames of other workers and makes ffmpeg record only frames of this worker's part
+func setupPart(p *states.Player) {
+	frameTime := 1000 / float64(settings.Recording.FPS)
+
+	zeroFrame := p.AlignFrames(frameTime)
+
+	toFrame := func(time float64) int64 {
+		if math.IsInf(time, -1) {
+			return 0
+		} else if math.IsInf(time, 1) {
+			return math.MaxInt64
+		}
+
+		return zeroFrame + int64(math.Ceil(time/(frameTime*settings.SPEED)))
+	}
+
+	ffmpeg.SetPart(partIndex, partCount, toFrame(partStart), toFrame(partEnd))
+}
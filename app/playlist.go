@@ -0,0 +1,269 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/faiface/mainthread"
+	"github.com/wieku/danser-go/app/dance"
+	"github.com/wieku/danser-go/app/ffmpeg"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Transitions shown between playlist's maps
+const (
+	transitionCard = "card"
+	transitionFade = "fade"
+	transitionNone = "none"
+)
+
+type playlistConfig struct {
+	// Name of the video, defaults to playlist file's name
+	Output string `json:"output"`
+
+	// "card" shows next map's metadata with standings, "fade" fades through black, "none" cuts straight to the next map
+	Transition string `json:"transition"`
+
+	// Length of the transition in seconds
+	TransitionDuration *float64 `json:"transitionDuration"`
+
+	// Whether standings after the last map should be shown at the end of the video
+	FinalStandings bool `json:"finalStandings"`
+
+	// Maps in the order they are recorded, in the same format as -batch jobs. Output is ignored, recording settings are taken from the first map.
+	Maps []*batchJob `json:"maps"`
+}
+
+// playlist is set if -playlist flag is used
+var playlist *playlistConfig
+
+// Set after the first playlist map is loaded. All maps are encoded by one ffmpeg session, so its recording settings replace the ones loaded for next maps.
+var restoreRecording func()
+
+func loadPlaylist(path string) *playlistConfig {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to read playlist file: %s", err))
+	}
+
+	config := &playlistConfig{
+		Transition: transitionCard,
+	}
+
+	if err = json.Unmarshal(data, config); err != nil {
+		panic(fmt.Sprintf("Failed to parse playlist file: %s", err))
+	}
+
+	if len(config.Maps) == 0 {
+		panic("Playlist doesn't contain any maps")
+	}
+
+	config.Transition = strings.ToLower(strings.TrimSpace(config.Transition))
+
+	switch config.Transition {
+	case transitionCard, transitionFade, transitionNone:
+	default:
+		panic(fmt.Sprintf("Unknown playlist transition: \"%s\"", config.Transition))
+	}
+
+	if config.TransitionDuration == nil {
+		duration := 4.0
+		if config.Transition == transitionFade {
+			duration = 1
+		}
+
+		config.TransitionDuration = &duration
+	}
+
+	if strings.TrimSpace(config.Output) == "" {
+		config.Output = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	return config
+}
+
+// runPlaylist records all maps of the playlist into one video. Maps that fail to load are skipped.
+func runPlaylist() {
+	output = playlist.Output
+
+	var rec *frameRecorder
+
+	standings := make(map[string]*states.PlaylistStanding)
+	chapters := make([]ffmpeg.Chapter, 0, len(playlist.Maps))
+
+	for i, job := range playlist.Maps {
+		log.Println(fmt.Sprintf("Starting playlist map %d/%d", i+1, len(playlist.Maps)))
+
+		p, err := setupPlaylistMap(job)
+		if err != nil {
+			log.Println(fmt.Sprintf("Playlist map %d/%d failed: %s", i+1, len(playlist.Maps), err))
+			continue
+		}
+
+		// Recording settings are known after settings of the first map are loaded
+		if rec == nil {
+			recording := *settings.Recording
+
+			restoreRecording = func() {
+				*settings.Recording = recording
+			}
+
+			rec = newFrameRecorder()
+			rec.start()
+		}
+
+		bMap := p.GetBeatMap()
+
+		title := fmt.Sprintf("%s - %s [%s]", bMap.Artist, bMap.Name, bMap.Difficulty)
+
+		chapters = append(chapters, ffmpeg.Chapter{Time: rec.getTime(), Title: title})
+
+		if playlist.Transition != transitionNone {
+			playTitleCard(rec, func() *states.TitleCard {
+				return states.NewTitleCard(bMap, fmt.Sprintf("Map %d of %d", i+1, len(playlist.Maps)), strings.Join(getPlayerNames(p), ", "), sortStandings(standings), playlist.Transition == transitionCard, *playlist.TransitionDuration*1000)
+			})
+		}
+
+		player = p
+
+		rec.record(p.Update, newProgressLogger(rec, p, fmt.Sprintf("Map %d/%d: ", i+1, len(playlist.Maps))).frameDone)
+
+		addStandings(standings, p)
+
+		mainthread.Call(p.Dispose)
+
+		player = nil
+	}
+
+	if rec == nil {
+		log.Println("No playlist maps were loaded, closing...")
+		return
+	}
+
+	if playlist.FinalStandings && len(standings) > 0 {
+		chapters = append(chapters, ffmpeg.Chapter{Time: rec.getTime(), Title: "Standings"})
+
+		playTitleCard(rec, func() *states.TitleCard {
+			return states.NewTitleCard(nil, "Final standings", "", sortStandings(standings), true, *playlist.TransitionDuration*1000*2)
+		})
+	}
+
+	ffmpeg.SetMetadata(map[string]string{
+		"title":   playlist.Output,
+		"comment": "Recorded with danser",
+	}, chapters)
+
+	rec.stop()
+
+	log.Println(fmt.Sprintf("Playlist finished: %s", settings.Recording.GetOutputPath(output)))
+}
+
+// setupPlaylistMap creates a Player for the map, returning an error instead of closing danser
+func setupPlaylistMap(job *batchJob) (p *states.Player, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if rErr, ok := r.(error); ok {
+				err = rErr
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+
+			player = nil
+		}
+	}()
+
+	mainCall(func() {
+		setupBatchJob(job)
+	})
+
+	p = player.(*states.Player)
+
+	// The map is shown after the transition
+	player = nil
+
+	return
+}
+
+func playTitleCard(rec *frameRecorder, create func() *states.TitleCard) {
+	var card *states.TitleCard
+
+	mainthread.Call(func() {
+		card = create()
+	})
+
+	player = card
+
+	rec.record(card.Update, nil)
+
+	player = nil
+
+	mainthread.Call(card.Dispose)
+}
+
+func getPlayerNames(p *states.Player) (names []string) {
+	controller, ok := p.GetController().(*dance.ReplayController)
+	if !ok {
+		return nil
+	}
+
+	for _, r := range controller.GetReplays() {
+		names = append(names, cleanReplayName(r.Name))
+	}
+
+	return
+}
+
+// addStandings adds scores from the finished map to playlist standings
+func addStandings(standings map[string]*states.PlaylistStanding, p *states.Player) {
+	controller, ok := p.GetController().(*dance.ReplayController)
+	if !ok {
+		return
+	}
+
+	for i, r := range controller.GetReplays() {
+		name := cleanReplayName(r.Name)
+
+		score := controller.GetRuleset().GetScore(controller.GetCursors()[i])
+
+		standing := standings[name]
+		if standing == nil {
+			standing = &states.PlaylistStanding{Name: name}
+			standings[name] = standing
+		}
+
+		standing.Score += score.Score
+		standing.Accuracy = (standing.Accuracy*float64(standing.Maps) + score.Accuracy) / float64(standing.Maps+1)
+		standing.Maps++
+	}
+}
+
+func sortStandings(standings map[string]*states.PlaylistStanding) []states.PlaylistStanding {
+	sorted := make([]states.PlaylistStanding, 0, len(standings))
+
+	for _, s := range standings {
+		sorted = append(sorted, *s)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Score == sorted[j].Score {
+			return sorted[i].Name < sorted[j].Name
+		}
+
+		return sorted[i].Score > sorted[j].Score
+	})
+
+	return sorted
+}
+
+// cleanReplayName removes the suffix dance.ReplayController adds to make names of knockout players unique
+func cleanReplayName(name string) string {
+	return strings.TrimRightFunc(name, func(r rune) bool {
+		return r > unicode.MaxRune-0xFFFF
+	})
+}
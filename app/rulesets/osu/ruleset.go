@@ -93,6 +93,7 @@ type Score struct {
 	CountMiss    uint
 	CountSB      uint
 	PP           pp220930.PPv2Results
+	PPIfFC       pp220930.PPv2Results
 }
 
 type subSet struct {
@@ -107,7 +108,8 @@ type subSet struct {
 
 	numObjects uint
 
-	ppv2 *pp220930.PPv2
+	ppv2     *pp220930.PPv2
+	ppv2IfFC *pp220930.PPv2
 
 	recoveries int
 	failed     bool
@@ -131,7 +133,7 @@ type OsuRuleSet struct {
 
 	queue        []HitObject
 	processed    []HitObject
-	hitListener  hitListener
+	hitListeners []hitListener
 	endListener  endListener
 	failListener failListener
 
@@ -232,6 +234,7 @@ func NewOsuRuleset(beatMap *beatmap.BeatMap, cursors []*graphics.Cursor, mods []
 				Accuracy: 100,
 			},
 			ppv2:           &pp220930.PPv2{},
+			ppv2IfFC:       &pp220930.PPv2{},
 			hp:             hp,
 			recoveries:     recoveries,
 			scoreProcessor: sc,
@@ -429,8 +432,10 @@ func (set *OsuRuleSet) SendResult(time int64, cursor *graphics.Cursor, src HitOb
 	subSet := set.cursors[cursor]
 
 	if result == Ignore || result == PositionalMiss {
-		if result == PositionalMiss && set.hitListener != nil && !subSet.player.diff.Mods.Active(difficulty.Relax) {
-			set.hitListener(cursor, time, number, vector.NewVec2f(x, y).Copy64(), result, comboResult, subSet.ppv2.Results, subSet.scoreProcessor.GetScore())
+		if result == PositionalMiss && !subSet.player.diff.Mods.Active(difficulty.Relax) {
+			for _, listener := range set.hitListeners {
+				listener(cursor, time, number, vector.NewVec2f(x, y).Copy64(), result, comboResult, subSet.ppv2.Results, subSet.scoreProcessor.GetScore())
+			}
 		}
 
 		return
@@ -518,6 +523,8 @@ func (set *OsuRuleSet) SendResult(time int64, cursor *graphics.Cursor, src HitOb
 
 	subSet.score.PP = subSet.ppv2.Results
 
+	set.updateLivePP(subSet)
+
 	switch result {
 	case Hit100:
 		subSet.currentKatu++
@@ -568,8 +575,8 @@ func (set *OsuRuleSet) SendResult(time int64, cursor *graphics.Cursor, src HitOb
 		subSet.hp.AddResult(result)
 	}
 
-	if set.hitListener != nil {
-		set.hitListener(cursor, time, number, vector.NewVec2f(x, y).Copy64(), result, comboResult, subSet.ppv2.Results, subSet.scoreProcessor.GetScore())
+	for _, listener := range set.hitListeners {
+		listener(cursor, time, number, vector.NewVec2f(x, y).Copy64(), result, comboResult, subSet.ppv2.Results, subSet.scoreProcessor.GetScore())
 	}
 
 	if len(set.cursors) == 1 && !settings.RECORD {
@@ -593,6 +600,30 @@ func (set *OsuRuleSet) SendResult(time int64, cursor *graphics.Cursor, src HitOb
 	}
 }
 
+// updateLivePP calculates pp if the rest of the map is FC'd with current accuracy
+func (set *OsuRuleSet) updateLivePP(subSet *subSet) {
+	steps := set.oppDiffs[difficulty.GetDiffMaskedMods(subSet.player.diff.Mods)]
+
+	score := subSet.score
+
+	// Misses become 300s, 100s and 50s are extrapolated to the full map with their current ratio
+	fullAttribs := steps[len(steps)-1]
+
+	n100, n50 := 0, 0
+
+	if subSet.numObjects > 0 {
+		ratio := float64(fullAttribs.ObjectCount) / float64(subSet.numObjects)
+
+		// Counts are rounded separately, so their sum has to be kept within the object count
+		n50 = mutils.Min(int(math.Round(float64(score.Count50)*ratio)), fullAttribs.ObjectCount)
+		n100 = mutils.Min(int(math.Round(float64(score.Count100)*ratio)), fullAttribs.ObjectCount-n50)
+	}
+
+	subSet.ppv2IfFC.PPv2x(fullAttribs, -1, fullAttribs.ObjectCount-n100-n50, n100, n50, 0, subSet.player.diff)
+
+	score.PPIfFC = subSet.ppv2IfFC.Results
+}
+
 func (set *OsuRuleSet) CanBeHit(time int64, object HitObject, player *difficultyPlayer) ClickAction {
 	if !player.cursor.IsAutoplay && !player.cursor.IsPlayer {
 		if _, ok := object.(*Circle); ok {
@@ -690,7 +721,12 @@ func (set *OsuRuleSet) PlayerStopped(cursor *graphics.Cursor, time int64) {
 }
 
 func (set *OsuRuleSet) SetListener(listener hitListener) {
-	set.hitListener = listener
+	set.hitListeners = []hitListener{listener}
+}
+
+// AddListener adds a hit listener called after the ones already set
+func (set *OsuRuleSet) AddListener(listener hitListener) {
+	set.hitListeners = append(set.hitListeners, listener)
 }
 
 func (set *OsuRuleSet) SetEndListener(listener endListener) {
@@ -0,0 +1,75 @@
+package statistics
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+type Report struct {
+	Beatmap string  `json:"beatmap"`
+	MD5     string  `json:"md5"`
+	Player  string  `json:"player"`
+	Mods    string  `json:"mods"`
+	Speed   float64 `json:"speed"`
+
+	UnstableRate float64 `json:"unstableRate"`
+	Mean         float64 `json:"mean"`
+
+	Types    map[string]Breakdown `json:"types"`
+	Patterns map[string]Breakdown `json:"patterns"`
+
+	Sections  []Section  `json:"sections"`
+	Bias      []float64  `json:"bias"`
+	Histogram Histogram  `json:"histogram"`
+	Hits      []HitError `json:"hits"`
+}
+
+// CreateReport gathers all statistics into a single structure
+func (stats *HitStatistics) CreateReport(player string, histogramBinSize float64, biasWindow int) *Report {
+	total := stats.GetBreakdown(nil)
+
+	report := &Report{
+		Beatmap:      stats.beatMap.Artist + " - " + stats.beatMap.Name + " [" + stats.beatMap.Difficulty + "]",
+		MD5:          stats.beatMap.MD5,
+		Player:       player,
+		Mods:         stats.beatMap.Diff.GetModString(),
+		Speed:        stats.beatMap.Diff.Speed,
+		UnstableRate: total.UnstableRate,
+		Mean:         total.Mean,
+		Types:        make(map[string]Breakdown),
+		Patterns:     make(map[string]Breakdown),
+		Sections:     stats.GetSections(),
+		Bias:         stats.GetBias(biasWindow),
+		Histogram:    stats.GetHistogram(histogramBinSize),
+		Hits:         stats.errors,
+	}
+
+	for _, t := range []string{"circle", "slider"} {
+		report.Types[t] = stats.GetBreakdown(func(e HitError) bool {
+			return e.Type == t
+		})
+	}
+
+	for _, p := range []Pattern{Single, Stream, Jump} {
+		report.Patterns[p.String()] = stats.GetBreakdown(func(e HitError) bool {
+			return e.Pattern == p
+		})
+	}
+
+	return report
+}
+
+// ExportJSON writes the report to given path, creating missing directories
+func (report *Report) ExportJSON(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,316 @@
+package statistics
+
+import (
+	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/beatmap/objects"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"math"
+	"sort"
+)
+
+// Tolerance in ms used when comparing object spacing to beat divisors
+const snapTolerance = 2.0
+
+type Pattern int
+
+const (
+	Single = Pattern(iota)
+	Stream
+	Jump
+)
+
+func (p Pattern) String() string {
+	switch p {
+	case Stream:
+		return "stream"
+	case Jump:
+		return "jump"
+	}
+
+	return "single"
+}
+
+func (p Pattern) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// HitError describes a single timed hit
+type HitError struct {
+	Number   int64   `json:"number"`
+	Type     string  `json:"type"`
+	Time     float64 `json:"time"`
+	Progress float64 `json:"progress"`
+	X        float32 `json:"x"`
+	Y        float32 `json:"y"`
+	Error    float64 `json:"error"`
+	BPM      float64 `json:"bpm"`
+	Pattern  Pattern `json:"pattern"`
+	Section  int     `json:"section"`
+}
+
+// Section contains statistics of hits in a fixed-length part of the map
+type Section struct {
+	Index        int     `json:"index"`
+	StartTime    float64 `json:"startTime"`
+	EndTime      float64 `json:"endTime"`
+	Hits         int     `json:"hits"`
+	Mean         float64 `json:"mean"`
+	UnstableRate float64 `json:"unstableRate"`
+}
+
+// Histogram contains hit error counts in BinSize wide bins, starting at Min
+type Histogram struct {
+	BinSize float64 `json:"binSize"`
+	Min     float64 `json:"min"`
+	Bins    []int   `json:"bins"`
+}
+
+// Breakdown contains statistics of hits sharing the same object type or pattern
+type Breakdown struct {
+	Hits         int     `json:"hits"`
+	Mean         float64 `json:"mean"`
+	UnstableRate float64 `json:"unstableRate"`
+}
+
+type HitStatistics struct {
+	beatMap *beatmap.BeatMap
+
+	patterns []Pattern
+
+	startTime     float64
+	endTime       float64
+	sectionLength float64
+
+	errors []HitError
+}
+
+// NewHitStatistics creates statistics for given beatmap, sectionLength is in milliseconds of map time
+func NewHitStatistics(beatMap *beatmap.BeatMap, sectionLength float64) *HitStatistics {
+	stats := &HitStatistics{
+		beatMap:       beatMap,
+		sectionLength: math.Max(sectionLength, 1000),
+	}
+
+	if len(beatMap.HitObjects) > 0 {
+		stats.startTime = beatMap.HitObjects[0].GetStartTime()
+		stats.endTime = beatMap.HitObjects[len(beatMap.HitObjects)-1].GetEndTime()
+	}
+
+	stats.classifyObjects()
+
+	return stats
+}
+
+func (stats *HitStatistics) classifyObjects() {
+	hitObjects := stats.beatMap.HitObjects
+
+	stats.patterns = make([]Pattern, len(hitObjects))
+
+	// gaps[i] is the spacing between object i-1 and i
+	gaps := make([]float64, len(hitObjects))
+	jumps := make([]bool, len(hitObjects))
+
+	mods := stats.beatMap.Diff.Mods
+	radius := stats.beatMap.Diff.CircleRadius
+
+	for i := 1; i < len(hitObjects); i++ {
+		prev, cur := hitObjects[i-1], hitObjects[i]
+
+		gaps[i] = math.Inf(1)
+
+		if prev.GetType() == objects.SPINNER || cur.GetType() == objects.SPINNER {
+			continue
+		}
+
+		gaps[i] = cur.GetStartTime() - prev.GetEndTime()
+
+		distance := cur.GetStackedStartPositionMod(mods).Dst(prev.GetStackedEndPositionMod(mods))
+
+		jumps[i] = float64(distance) > radius*3
+	}
+
+	for i, o := range hitObjects {
+		if o.GetType() == objects.SPINNER {
+			continue
+		}
+
+		beatLength := stats.beatMap.Timings.GetPointAt(o.GetStartTime()).GetBaseBeatLength()
+
+		inStream := func(j int) bool {
+			return j > 0 && j < len(gaps) && gaps[j] <= beatLength/4+snapTolerance
+		}
+
+		switch {
+		case inStream(i) || inStream(i+1):
+			stats.patterns[i] = Stream
+		case i > 0 && jumps[i] && gaps[i] <= beatLength/2+snapTolerance:
+			stats.patterns[i] = Jump
+		}
+	}
+}
+
+// Add records hit error of given object, error is in ms of map time
+func (stats *HitStatistics) Add(number int64, error float64) {
+	if number < 0 || int(number) >= len(stats.beatMap.HitObjects) {
+		return
+	}
+
+	o := stats.beatMap.HitObjects[number]
+
+	time := o.GetStartTime()
+	position := o.GetStackedStartPositionMod(stats.beatMap.Diff.Mods)
+
+	progress := 0.0
+	if stats.endTime > stats.startTime {
+		progress = mutils.ClampF((time-stats.startTime)/(stats.endTime-stats.startTime), 0, 1)
+	}
+
+	objType := "circle"
+	if o.GetType() == objects.SLIDER {
+		objType = "slider"
+	}
+
+	stats.errors = append(stats.errors, HitError{
+		Number:   number,
+		Type:     objType,
+		Time:     time,
+		Progress: progress,
+		X:        position.X,
+		Y:        position.Y,
+		Error:    error,
+		BPM:      60000 / stats.beatMap.Timings.GetPointAt(time).GetBaseBeatLength() * stats.beatMap.Diff.Speed,
+		Pattern:  stats.patterns[number],
+		Section:  stats.getSectionIndex(time),
+	})
+}
+
+func (stats *HitStatistics) getSectionIndex(time float64) int {
+	return mutils.Max(0, int((time-stats.startTime)/stats.sectionLength))
+}
+
+func (stats *HitStatistics) GetErrors() []HitError {
+	return stats.errors
+}
+
+func (stats *HitStatistics) GetSpeed() float64 {
+	return stats.beatMap.Diff.Speed
+}
+
+func (stats *HitStatistics) GetUnstableRate() float64 {
+	return stats.GetBreakdown(nil).UnstableRate
+}
+
+// GetBreakdown returns statistics of hits accepted by filter, nil filter accepts all hits
+func (stats *HitStatistics) GetBreakdown(filter func(e HitError) bool) Breakdown {
+	var errors []float64
+
+	for _, e := range stats.errors {
+		if filter == nil || filter(e) {
+			errors = append(errors, e.Error)
+		}
+	}
+
+	mean, ur := calculateUnstableRate(errors)
+
+	return Breakdown{
+		Hits:         len(errors),
+		Mean:         mean,
+		UnstableRate: ur,
+	}
+}
+
+// GetSections returns statistics for every section of the map, including the ones without hits
+func (stats *HitStatistics) GetSections() []Section {
+	sections := make([]Section, stats.getSectionIndex(stats.endTime)+1)
+	errors := make([][]float64, len(sections))
+
+	for i := range sections {
+		sections[i].Index = i
+		sections[i].StartTime = stats.startTime + float64(i)*stats.sectionLength
+		sections[i].EndTime = math.Min(sections[i].StartTime+stats.sectionLength, stats.endTime)
+	}
+
+	for _, e := range stats.errors {
+		errors[e.Section] = append(errors[e.Section], e.Error)
+	}
+
+	for i := range sections {
+		sections[i].Hits = len(errors[i])
+		sections[i].Mean, sections[i].UnstableRate = calculateUnstableRate(errors[i])
+	}
+
+	return sections
+}
+
+// GetBias returns moving average of hit errors over window hits, showing early/late tendency over time
+func (stats *HitStatistics) GetBias(window int) []float64 {
+	window = mutils.Max(window, 1)
+
+	bias := make([]float64, len(stats.errors))
+
+	sum := 0.0
+
+	for i, e := range stats.errors {
+		sum += e.Error
+
+		if i >= window {
+			sum -= stats.errors[i-window].Error
+		}
+
+		bias[i] = sum / float64(mutils.Min(i+1, window))
+	}
+
+	return bias
+}
+
+// GetHistogram returns histogram of hit errors with bins aligned to 0
+func (stats *HitStatistics) GetHistogram(binSize float64) Histogram {
+	binSize = math.Max(binSize, 0.1)
+
+	if len(stats.errors) == 0 {
+		return Histogram{BinSize: binSize}
+	}
+
+	errors := make([]float64, len(stats.errors))
+	for i, e := range stats.errors {
+		errors[i] = e.Error
+	}
+
+	sort.Float64s(errors)
+
+	minBin := math.Floor(errors[0] / binSize)
+	maxBin := math.Floor(errors[len(errors)-1] / binSize)
+
+	histogram := Histogram{
+		BinSize: binSize,
+		Min:     minBin * binSize,
+		Bins:    make([]int, int(maxBin-minBin)+1),
+	}
+
+	for _, e := range errors {
+		histogram.Bins[int(math.Floor(e/binSize)-minBin)]++
+	}
+
+	return histogram
+}
+
+func calculateUnstableRate(errors []float64) (mean float64, ur float64) {
+	if len(errors) == 0 {
+		return 0, 0
+	}
+
+	for _, e := range errors {
+		mean += e
+	}
+
+	mean /= float64(len(errors))
+
+	variance := 0.0
+	for _, e := range errors {
+		variance += math.Pow(e-mean, 2)
+	}
+
+	variance /= float64(len(errors))
+
+	return mean, math.Sqrt(variance) * 10
+}
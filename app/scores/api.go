@@ -0,0 +1,69 @@
+package scores
+
+import (
+	"errors"
+	"fmt"
+	"github.com/thehowl/go-osuapi"
+	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/env"
+	"path/filepath"
+	"strings"
+)
+
+// apiSource loads the online leaderboard from osu!api v1
+type apiSource struct{}
+
+func (source *apiSource) Name() string {
+	return "osu!api"
+}
+
+func (source *apiSource) GetScores(beatMap *beatmap.BeatMap, modsOnly bool) ([]Score, error) {
+	key := strings.TrimSpace(settings.Credentails.ApiV1Key)
+	if key == "" {
+		return nil, fmt.Errorf("please put your osu!api v1 key into '%s' file", filepath.Join(env.ConfigDir(), "credentials.json"))
+	}
+
+	client := osuapi.NewClient(key)
+
+	if err := client.Test(); err != nil {
+		return nil, fmt.Errorf("can't connect to osu!api: %w", err)
+	}
+
+	beatMaps, err := client.GetBeatmaps(osuapi.GetBeatmapsOpts{BeatmapHash: beatMap.MD5})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(beatMaps) == 0 {
+		return nil, errors.New("online beatmap not found")
+	}
+
+	opts := osuapi.GetScoresOpts{BeatmapID: beatMaps[0].BeatmapID, Limit: 51}
+
+	if modsOnly {
+		mods1 := osuapi.Mods(beatMap.Diff.Mods)
+		opts.Mods = &mods1
+	}
+
+	oScores, err := client.GetScores(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make([]Score, 0, len(oScores))
+
+	for _, s := range oScores {
+		scores = append(scores, Score{
+			ID:     s.ScoreID,
+			UserID: s.UserID,
+			Name:   s.Username,
+			Score:  s.Score.Score,
+			Combo:  int64(s.MaxCombo),
+			Mods:   difficulty.Modifier(s.Mods),
+		})
+	}
+
+	return scores, nil
+}
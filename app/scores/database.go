@@ -0,0 +1,34 @@
+package scores
+
+import (
+	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/database"
+)
+
+// databaseSource loads scores saved by danser after -play sessions
+type databaseSource struct{}
+
+func (source *databaseSource) Name() string {
+	return "local scores"
+}
+
+func (source *databaseSource) GetScores(beatMap *beatmap.BeatMap, _ bool) ([]Score, error) {
+	dbScores, err := database.GetScores(beatMap.MD5)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make([]Score, 0, len(dbScores))
+
+	for _, s := range dbScores {
+		scores = append(scores, Score{
+			Name:  s.Name,
+			Score: s.Score,
+			Combo: s.Combo,
+			Mods:  difficulty.Modifier(s.Mods),
+		})
+	}
+
+	return scores, nil
+}
@@ -0,0 +1,73 @@
+package scores
+
+import (
+	"encoding/json"
+	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/framework/env"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type jsonScore struct {
+	Name   string `json:"name"`
+	UserID int    `json:"userId"`
+	Score  int64  `json:"score"`
+	Combo  int64  `json:"combo"`
+	Mods   string `json:"mods"`
+}
+
+// jsonSource loads scores from a JSON file mapping map MD5s to lists of scores, e.g.:
+//
+//	{"<md5>": [{"name": "Player", "score": 1000000, "combo": 500, "mods": "HDDT"}]}
+type jsonSource struct {
+	path string
+}
+
+func newJSONSource(path string) *jsonSource {
+	path = strings.TrimSpace(path)
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(env.DataDir(), path)
+	}
+
+	return &jsonSource{path: path}
+}
+
+func (source *jsonSource) Name() string {
+	return filepath.Base(source.path)
+}
+
+func (source *jsonSource) GetScores(beatMap *beatmap.BeatMap, _ bool) ([]Score, error) {
+	data, err := os.ReadFile(source.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var maps map[string][]jsonScore
+
+	if err = json.Unmarshal(data, &maps); err != nil {
+		return nil, err
+	}
+
+	var scores []Score
+
+	for md5, mScores := range maps {
+		if !strings.EqualFold(md5, beatMap.MD5) {
+			continue
+		}
+
+		for _, s := range mScores {
+			scores = append(scores, Score{
+				UserID: s.UserID,
+				Name:   s.Name,
+				Score:  s.Score,
+				Combo:  s.Combo,
+				Mods:   difficulty.ParseMods(s.Mods),
+			})
+		}
+	}
+
+	return scores, nil
+}
@@ -0,0 +1,185 @@
+package scores
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/itchio/lzma"
+	"github.com/wieku/danser-go/framework/env"
+	"github.com/wieku/rplpa"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// osuVersion is written to saved replays, osu!stable refuses to open replays from too old versions
+const osuVersion = 20221111
+
+// SaveReplay writes the replay to "replays/{md5}", so it's picked up by local replays source. Returns the path of saved file.
+func SaveReplay(replay *rplpa.Replay) (string, error) {
+	data, err := EncodeReplay(replay)
+	if err != nil {
+		return "", err
+	}
+
+	replayDir := filepath.Join(env.DataDir(), "replays", strings.ToLower(replay.BeatmapMD5))
+
+	if err = os.MkdirAll(replayDir, 0755); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s - %s.osr", replay.Username, replay.Timestamp.Local().Format("2006-01-02_15-04-05"))
+	path := filepath.Join(replayDir, strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+
+		return r
+	}, name))
+
+	return path, os.WriteFile(path, data, 0644)
+}
+
+// EncodeReplay encodes the replay in .osr format
+func EncodeReplay(replay *rplpa.Replay) ([]byte, error) {
+	compressed, err := compressFrames(replay.ReplayData)
+	if err != nil {
+		return nil, err
+	}
+
+	replayMD5 := replay.ReplayMD5
+	if replayMD5 == "" {
+		hash := md5.Sum(compressed)
+		replayMD5 = hex.EncodeToString(hash[:])
+	}
+
+	version := replay.OsuVersion
+	if version == 0 {
+		version = osuVersion
+	}
+
+	b := new(bytes.Buffer)
+
+	write := func(value interface{}) {
+		_ = binary.Write(b, binary.LittleEndian, value)
+	}
+
+	write(replay.PlayMode)
+	write(version)
+	writeString(b, replay.BeatmapMD5)
+	writeString(b, replay.Username)
+	writeString(b, replayMD5)
+	write(replay.Count300)
+	write(replay.Count100)
+	write(replay.Count50)
+	write(replay.CountGeki)
+	write(replay.CountKatu)
+	write(replay.CountMiss)
+	write(replay.Score)
+	write(replay.MaxCombo)
+	write(replay.Fullcombo)
+	write(replay.Mods)
+
+	var lifebar strings.Builder
+
+	for _, l := range replay.LifebarGraph {
+		lifebar.WriteString(fmt.Sprintf("%d|%s,", l.Time, strconv.FormatFloat(float64(l.HP), 'f', -1, 32)))
+	}
+
+	writeString(b, lifebar.String())
+
+	write(toTicks(replay.Timestamp))
+	write(int32(len(compressed)))
+	b.Write(compressed)
+	write(replay.ScoreID)
+
+	return b.Bytes(), nil
+}
+
+func compressFrames(frames []*rplpa.ReplayData) ([]byte, error) {
+	var raw strings.Builder
+
+	for _, frame := range frames {
+		keys := 0
+
+		if frame.KeyPressed != nil {
+			if frame.KeyPressed.LeftClick {
+				keys |= rplpa.LEFTCLICK
+			}
+
+			if frame.KeyPressed.RightClick {
+				keys |= rplpa.RIGHTCLICK
+			}
+
+			if frame.KeyPressed.Key1 {
+				keys |= rplpa.KEY1
+			}
+
+			if frame.KeyPressed.Key2 {
+				keys |= rplpa.KEY2
+			}
+
+			if frame.KeyPressed.Smoke {
+				keys |= rplpa.SMOKE
+			}
+		}
+
+		raw.WriteString(fmt.Sprintf("%d|%s|%s|%d,", frame.Time, strconv.FormatFloat(float64(frame.MouseX), 'f', -1, 32), strconv.FormatFloat(float64(frame.MouseY), 'f', -1, 32), keys))
+	}
+
+	data := []byte(raw.String())
+
+	b := new(bytes.Buffer)
+
+	w := lzma.NewWriterSize(b, int64(len(data)))
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// writeString writes osu!'s string: 0x0b, ULEB128 length and the string, or 0x00 if it's empty
+func writeString(b *bytes.Buffer, s string) {
+	if s == "" {
+		b.WriteByte(0)
+		return
+	}
+
+	b.WriteByte(0x0b)
+
+	length := uint(len(s))
+
+	for {
+		c := byte(length & 0x7f)
+		length >>= 7
+
+		if length != 0 {
+			c |= 0x80
+		}
+
+		b.WriteByte(c)
+
+		if length == 0 {
+			break
+		}
+	}
+
+	b.WriteString(s)
+}
+
+// toTicks converts time to .NET ticks, 100ns intervals since 0001-01-01
+func toTicks(t time.Time) int64 {
+	base := time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+	return (t.Unix()-base)*10000000 + int64(t.Nanosecond()/100)
+}
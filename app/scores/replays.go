@@ -0,0 +1,70 @@
+package scores
+
+import (
+	"github.com/karrick/godirwalk"
+	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/framework/env"
+	"github.com/wieku/rplpa"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// replaySource loads scores from replays in "replays/{md5}", the same directories used by knockout
+type replaySource struct{}
+
+func (source *replaySource) Name() string {
+	return "local replays"
+}
+
+func (source *replaySource) GetScores(beatMap *beatmap.BeatMap, _ bool) (scores []Score, err error) {
+	replayDir := filepath.Join(env.DataDir(), "replays", strings.ToLower(beatMap.MD5))
+
+	if _, err = os.Stat(replayDir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	err = godirwalk.Walk(replayDir, &godirwalk.Options{
+		Callback: func(osPathname string, de *godirwalk.Dirent) error {
+			if de.IsDir() && osPathname != replayDir {
+				return godirwalk.SkipThis
+			}
+
+			if !strings.HasSuffix(strings.ToLower(de.Name()), ".osr") {
+				return nil
+			}
+
+			data, rErr := os.ReadFile(osPathname)
+			if rErr != nil {
+				log.Println("Failed to read replay:", rErr)
+				return nil
+			}
+
+			replay, rErr := rplpa.ParseReplay(data)
+			if rErr != nil {
+				log.Println("Failed to parse replay:", osPathname, rErr)
+				return nil
+			}
+
+			if !strings.EqualFold(replay.BeatmapMD5, beatMap.MD5) || replay.PlayMode != 0 {
+				return nil
+			}
+
+			scores = append(scores, Score{
+				ID:    replay.ScoreID,
+				Name:  replay.Username,
+				Score: int64(replay.Score),
+				Combo: int64(replay.MaxCombo),
+				Mods:  difficulty.Modifier(replay.Mods),
+			})
+
+			return nil
+		},
+		Unsorted:            true,
+		FollowSymbolicLinks: true,
+	})
+
+	return
+}
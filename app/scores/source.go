@@ -0,0 +1,110 @@
+package scores
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/settings"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Score is a single entry of the scoreboard
+type Score struct {
+	// osu! score ID, 0 if unknown
+	ID int64
+
+	// osu! user ID used to load the avatar, 0 if unknown
+	UserID int
+
+	Name  string
+	Score int64
+	Combo int64
+	Mods  difficulty.Modifier
+}
+
+// Source provides scores of a beatmap
+type Source interface {
+	Name() string
+
+	// GetScores returns scores of the beatMap. If modsOnly is true only scores with mods matching the beatMap are needed, sources may return others as well.
+	GetScores(beatMap *beatmap.BeatMap, modsOnly bool) ([]Score, error)
+}
+
+// GetSources returns sources enabled in settings.Gameplay.ScoreBoard
+func GetSources() (sources []Source) {
+	conf := settings.Gameplay.ScoreBoard
+
+	if conf.OnlineScores {
+		sources = append(sources, new(apiSource))
+	}
+
+	if conf.ReplayScores {
+		sources = append(sources, new(replaySource))
+	}
+
+	if conf.LocalScores {
+		sources = append(sources, new(databaseSource))
+	}
+
+	if strings.TrimSpace(conf.ScoresFile) != "" {
+		sources = append(sources, newJSONSource(conf.ScoresFile))
+	}
+
+	return
+}
+
+// Load gathers up to limit best scores of the beatMap from all sources. Score with omitID and duplicates found in several sources are removed.
+func Load(sources []Source, beatMap *beatmap.BeatMap, omitID int64, limit int) []Score {
+	modsOnly := settings.Gameplay.ScoreBoard.ModsOnly
+
+	seen := make(map[string]bool)
+
+	var scores []Score
+
+	for _, source := range sources {
+		sScores, err := source.GetScores(beatMap, modsOnly)
+		if err != nil {
+			log.Println(fmt.Sprintf("Failed to load scores from %s: %s", source.Name(), err))
+			continue
+		}
+
+		added := 0
+
+		for _, s := range sScores {
+			if (omitID != 0 && s.ID == omitID) || (modsOnly && !sameMods(s.Mods, beatMap.Diff.Mods)) {
+				continue
+			}
+
+			key := fmt.Sprintf("%s|%d|%d", strings.ToLower(s.Name), s.Score, s.Combo)
+			if seen[key] {
+				continue
+			}
+
+			seen[key] = true
+
+			scores = append(scores, s)
+			added++
+		}
+
+		log.Println(fmt.Sprintf("Loaded %d scores from %s", added, source.Name()))
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
+	})
+
+	if len(scores) > limit {
+		scores = scores[:limit]
+	}
+
+	return scores
+}
+
+// sameMods compares mods ignoring the ones that don't change the leaderboard
+func sameMods(a, b difficulty.Modifier) bool {
+	ignored := difficulty.Nightcore | difficulty.Perfect | difficulty.SuddenDeath
+
+	return a&^ignored == b&^ignored
+}
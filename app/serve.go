@@ -0,0 +1,478 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/wieku/danser-go/app/database"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/env"
+	"github.com/wieku/danser-go/framework/goroutines"
+	"github.com/wieku/rplpa"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Maximum size of submitted job, including the replay
+const maxJobSize = 32 << 20
+
+// Settings sections with server's directories and ffmpeg options, jobs submitted over HTTP can't override them
+var lockedSections = []string{"General", "Recording"}
+
+// ID of the job being recorded, 0 if the server is idle
+var currentJobID atomic.Int64
+
+// runServer serves the render API on given address and records submitted jobs one after another
+func runServer(address string) {
+	if err := database.RequeueRenderJobs(); err != nil {
+		panic(fmt.Sprintf("Failed to restore job queue: %s", err))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs", handleJobs)
+	mux.HandleFunc("/jobs/", handleJob)
+
+	goroutines.Run(func() {
+		log.Println("Render server listening on", address)
+
+		if err := http.ListenAndServe(address, mux); err != nil {
+			panic(fmt.Sprintf("Render server failed: %s", err))
+		}
+	})
+
+	preciseProgress = true
+
+	for {
+		job, err := database.GetNextRenderJob()
+		if err != nil {
+			log.Println("Failed to get next job:", err)
+		}
+
+		if job == nil {
+			time.Sleep(time.Second)
+			continue
+		}
+
+		renderServerJob(job)
+	}
+}
+
+func renderServerJob(job *database.RenderJob) {
+	log.Println(fmt.Sprintf("Starting job %d: %s", job.ID, job.Output))
+
+	bJob := new(batchJob)
+
+	if err := json.Unmarshal(job.Request, bJob); err != nil {
+		job.Status = database.JobFailed
+		job.Error = err.Error()
+
+		updateServerJob(job)
+
+		return
+	}
+
+	bJob.Output = job.Output
+
+	// Reset before the job can be cancelled, so cancel request isn't lost
+	cancelRecording.Store(false)
+
+	// Published before the status changes, so clients never see a rendering job that can't be cancelled
+	currentJobID.Store(job.ID)
+
+	job.Status = database.JobRendering
+	updateServerJob(job)
+
+	progressListener = func(progress int, speed float64, eta int) {
+		job.Progress = progress
+		job.Speed = speed
+		job.ETA = eta
+
+		updateServerJob(job)
+	}
+
+	err := runBatchJob(bJob)
+
+	progressListener = nil
+
+	currentJobID.Store(0)
+
+	job.Speed = 0
+	job.ETA = 0
+
+	switch {
+	case errors.Is(err, errRecordingCancelled):
+		job.Status = database.JobCancelled
+	case err != nil:
+		job.Status = database.JobFailed
+		job.Error = err.Error()
+	default:
+		job.Status = database.JobDone
+		job.Progress = 100
+
+		// Job's settings are still loaded, path won't change when they are replaced by the next job
+		if path, err := filepath.Abs(settings.Recording.GetOutputPath(job.Output)); err == nil {
+			job.OutputPath = path
+		}
+	}
+
+	log.Println(fmt.Sprintf("Job %d finished with status: %s", job.ID, job.Status))
+
+	updateServerJob(job)
+}
+
+func updateServerJob(job *database.RenderJob) {
+	if err := database.UpdateRenderJob(job); err != nil {
+		log.Println(fmt.Sprintf("Failed to update job %d: %s", job.ID, err))
+	}
+}
+
+// handleJobs lists jobs on GET and adds a new one on POST. Job is either a JSON body or a multipart form with "job" field and optional "replay" file.
+func handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		jobs, err := database.GetRenderJobs()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, jobs)
+	case http.MethodPost:
+		request, err := readJobRequest(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		job, err := database.AddRenderJob(request, "job")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		log.Println(fmt.Sprintf("Job %d added to the queue", job.ID))
+
+		writeJSON(w, http.StatusCreated, job)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// handleJob serves /jobs/{id}, /jobs/{id}/cancel and /jobs/{id}/download
+func handleJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/"), "/")
+
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || len(parts) > 2 {
+		writeError(w, http.StatusNotFound, database.ErrJobNotFound)
+		return
+	}
+
+	job, err := database.GetRenderJob(id)
+	if errors.Is(err, database.ErrJobNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	action := ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, job)
+	case action == "cancel" && r.Method == http.MethodPost:
+		cancelServerJob(w, job)
+	case action == "download" && r.Method == http.MethodGet:
+		if job.Status != database.JobDone {
+			writeError(w, http.StatusConflict, fmt.Errorf("job is %s", job.Status))
+			return
+		}
+
+		stat, err := os.Stat(job.OutputPath)
+		if job.OutputPath == "" || err != nil {
+			writeError(w, http.StatusGone, errors.New("recording is not available"))
+			return
+		}
+
+		if stat.IsDir() {
+			writeError(w, http.StatusConflict, errors.New("image sequences can't be downloaded"))
+			return
+		}
+
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(job.OutputPath)))
+
+		http.ServeFile(w, r, job.OutputPath)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("unknown action"))
+	}
+}
+
+func cancelServerJob(w http.ResponseWriter, job *database.RenderJob) {
+	if currentJobID.Load() == job.ID {
+		cancelRecording.Store(true)
+
+		log.Println(fmt.Sprintf("Cancelling job %d...", job.ID))
+
+		writeJSON(w, http.StatusAccepted, job)
+
+		return
+	}
+
+	cancelled, err := database.CancelRenderJob(job.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !cancelled {
+		writeError(w, http.StatusConflict, fmt.Errorf("job is %s", job.Status))
+		return
+	}
+
+	job.Status = database.JobCancelled
+
+	log.Println(fmt.Sprintf("Job %d cancelled", job.ID))
+
+	writeJSON(w, http.StatusOK, job)
+}
+
+// readJobRequest validates submitted job and stores uploaded replay
+func readJobRequest(r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(nil, r.Body, maxJobSize)
+
+	var data []byte
+
+	job := new(batchJob)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxJobSize); err != nil {
+			return nil, err
+		}
+
+		data = []byte(r.FormValue("job"))
+		if len(data) == 0 {
+			data = []byte("{}")
+		}
+
+		if err := json.Unmarshal(data, job); err != nil {
+			return nil, err
+		}
+
+		if err := validateJobRequest(job); err != nil {
+			return nil, err
+		}
+
+		if file, _, err := r.FormFile("replay"); err == nil {
+			path, err := saveReplay(file)
+
+			_ = file.Close()
+
+			if err != nil {
+				return nil, err
+			}
+
+			job.Replay = path
+		}
+	} else {
+		var err error
+
+		if data, err = io.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+
+		if err = json.Unmarshal(data, job); err != nil {
+			return nil, err
+		}
+
+		if err = validateJobRequest(job); err != nil {
+			return nil, err
+		}
+	}
+
+	if job.Replay == "" && job.ID == nil && job.MD5 == "" && job.Title == "" && job.Artist == "" && job.Difficulty == "" && job.Creator == "" {
+		return nil, errors.New("no beatmap or replay specified")
+	}
+
+	return json.Marshal(job)
+}
+
+// validateJobRequest rejects parameters that would give clients access to files on the server or change where and how videos are encoded
+func validateJobRequest(job *batchJob) error {
+	if job.Replay != "" || len(job.Replays) > 0 {
+		return errors.New("replays can only be uploaded")
+	}
+
+	if !isPlainName(job.Settings) || !isPlainName(job.Skin) {
+		return errors.New("settings and skin have to be names, not paths")
+	}
+
+	if len(job.Overrides) == 0 {
+		return nil
+	}
+
+	var sections map[string]json.RawMessage
+
+	if err := json.Unmarshal(job.Overrides, &sections); err != nil {
+		return fmt.Errorf("invalid overrides: %s", err)
+	}
+
+	for name := range sections {
+		for _, locked := range lockedSections {
+			// Section names are matched the same way json.Unmarshal does it
+			if strings.EqualFold(name, locked) {
+				return fmt.Errorf("%s settings can't be overridden", locked)
+			}
+		}
+	}
+
+	return validateOverrides(job.Overrides, reflect.TypeOf(settings.Config{}), "")
+}
+
+// validateOverrides walks overrides along the settings type and rejects values of fields holding local paths.
+// Fields are recognized by the same tags the settings editor uses to open file and directory pickers.
+func validateOverrides(data json.RawMessage, typ reflect.Type, path string) error {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		var values map[string]json.RawMessage
+
+		// Anything other than an object can't reach nested fields
+		if json.Unmarshal(data, &values) != nil {
+			return nil
+		}
+
+		for key, value := range values {
+			field, ok := findJSONField(typ, key)
+			if !ok {
+				continue
+			}
+
+			fieldPath := field.Name
+			if path != "" {
+				fieldPath = path + "." + field.Name
+			}
+
+			_, isFile := field.Tag.Lookup("file")
+			_, isDir := field.Tag.Lookup("path")
+
+			if isFile || isDir {
+				return fmt.Errorf("%s can't be overridden, it points to a local path", fieldPath)
+			}
+
+			if field.Tag.Get("comboSrc") == "SkinOptions" {
+				var name string
+
+				if err := json.Unmarshal(value, &name); err == nil && !isPlainName(name) {
+					return fmt.Errorf("%s has to be a name, not a path", fieldPath)
+				}
+			}
+
+			if err := validateOverrides(value, field.Type, fieldPath); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		var values []json.RawMessage
+
+		if json.Unmarshal(data, &values) != nil {
+			return nil
+		}
+
+		for i, value := range values {
+			if err := validateOverrides(value, typ.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		var values map[string]json.RawMessage
+
+		if json.Unmarshal(data, &values) != nil {
+			return nil
+		}
+
+		for key, value := range values {
+			if err := validateOverrides(value, typ.Elem(), path+"."+key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// findJSONField finds exported struct field that json.Unmarshal would decode the key into
+func findJSONField(typ reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+
+		if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// isPlainName returns true if name can't point outside of its directory
+func isPlainName(name string) bool {
+	return name == "" || (name == filepath.Base(name) && name != "." && name != ".." && !strings.ContainsAny(name, `/\:`))
+}
+
+func saveReplay(reader io.Reader) (string, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = rplpa.ParseReplay(data); err != nil {
+		return "", fmt.Errorf("invalid replay: %s", err)
+	}
+
+	dir := filepath.Join(env.DataDir(), "uploads")
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%d.osr", time.Now().UnixNano()))
+
+	return path, os.WriteFile(path, data, 0644)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -23,6 +23,35 @@ func initGameplay() *gameplay {
 			StaticUnstableRate:   false,
 			ScaleWithSpeed:       false,
 		},
+		HitErrorTimeline: &hitErrorTimeline{
+			hudElementOffset: &hudElementOffset{
+				hudElement: &hudElement{
+					Show:    false,
+					Scale:   1.0,
+					Opacity: 1.0,
+				},
+				XOffset: 0,
+				YOffset: -20,
+			},
+			Height: 40,
+		},
+		HitStatistics: &hitStatistics{
+			ShowInResults:    true,
+			SectionLength:    20,
+			HistogramBinSize: 2,
+			BiasWindow:       20,
+			ExportJSON:       false,
+		},
+		Practice: &practice{
+			LeadIn:         1,
+			RestartOnFail:  true,
+			RateStep:       0,
+			TargetAccuracy: 95,
+			MinRate:        0.5,
+			MaxRate:        2,
+			ShowAttempts:   true,
+			AttemptsShown:  5,
+		},
 		AimErrorMeter: &aimError{
 			hudElementPosition: &hudElementPosition{
 				hudElement: &hudElement{
@@ -98,6 +127,7 @@ func initGameplay() *gameplay {
 			Align:            "CentreLeft",
 			ShowInResults:    true,
 			ShowPPComponents: false,
+			ShowPPIfFC:       false,
 			Static:           false,
 		},
 		HitCounter: &hitCounter{
@@ -192,6 +222,22 @@ func initGameplay() *gameplay {
 			HideOthers:     false,
 			ShowAvatars:    false,
 			ExplosionScale: 1.0,
+			OnlineScores:   true,
+			ReplayScores:   true,
+			LocalScores:    true,
+			ScoresFile:     "",
+		},
+		PersonalBest: &personalBest{
+			hudElementPosition: &hudElementPosition{
+				hudElement: &hudElement{
+					Show:    true,
+					Scale:   1.0,
+					Opacity: 1.0,
+				},
+				XPosition: 1361,
+				YPosition: 200,
+			},
+			Align: "TopRight",
 		},
 		Mods: &mods{
 			hudElementOffset: &hudElementOffset{
@@ -228,14 +274,18 @@ func initGameplay() *gameplay {
 			Path:       "",
 			AboveHpBar: false,
 		},
+		TextWidgets:             []*textWidget{},
 		HUDFont:                 "",
+		HUDLayout:               "",
 		ShowResultsScreen:       true,
 		ResultsScreenTime:       5,
+		ResultsScreenStyle:      StableResults,
 		ResultsUseLocalTimeZone: false,
 		ShowWarningArrows:       true,
 		ShowHitLighting:         false,
 		FlashlightDim:           1,
 		PlayUsername:            "Guest",
+		SaveReplays:             true,
 		IgnoreFailsInReplays:    false,
 		UseLazerPP:              false,
 	}
@@ -243,6 +293,9 @@ func initGameplay() *gameplay {
 
 type gameplay struct {
 	HitErrorMeter           *hitError
+	HitErrorTimeline        *hitErrorTimeline `label:"Hit error timeline" tooltip:"Shows hit errors of the whole map at the bottom of the screen. Clicking it while watching a replay seeks to the clicked time"`
+	HitStatistics           *hitStatistics
+	Practice                *practice
 	AimErrorMeter           *aimError
 	Score                   *score
 	HpBar                   *hudElementOffset
@@ -252,17 +305,22 @@ type gameplay struct {
 	StrainGraph             *strainGraph
 	KeyOverlay              *hudElementOffset
 	ScoreBoard              *scoreBoard
+	PersonalBest            *personalBest `label:"Personal best" tooltip:"Shows the difference to the best -play score on the map with the same mods"`
 	Mods                    *mods
 	Boundaries              *boundaries
 	Underlay                *underlay
-	HUDFont                 string  `label:"Overlay (HUD) font" file:"Select HUD font" filter:"TrueType/OpenType Font (*.ttf, *.otf)|ttf,otf" tooltip:"Sets the font that will be used for PP/UR/hit counts" liveedit:"false"`
-	ShowResultsScreen       bool    `liveedit:"false"`
-	ResultsScreenTime       float64 `label:"Results screen duration" min:"1" max:"20" format:"%.1fs" liveedit:"false"`
-	ResultsUseLocalTimeZone bool    `label:"Show PC's time zone instead of UTC"`
+	TextWidgets             []*textWidget `new:"InitTextWidget" tooltip:"Texts showing live values, e.g. {acc:.2f}%. Available variables are listed in the log when the map starts"`
+	HUDFont                 string        `label:"Overlay (HUD) font" file:"Select HUD font" filter:"TrueType/OpenType Font (*.ttf, *.otf)|ttf,otf" tooltip:"Sets the font that will be used for PP/UR/hit counts" liveedit:"false"`
+	HUDLayout               string        `label:"Overlay (HUD) layout" file:"Select HUD layout" filter:"HUD layout (*.json)|json" tooltip:"JSON file placing HUD elements on the screen. Elements missing in the layout use positions from settings. Layouts can be edited in-game with HUD editor key" liveedit:"false"`
+	ShowResultsScreen       bool          `liveedit:"false"`
+	ResultsScreenTime       float64       `label:"Results screen duration" min:"1" max:"20" format:"%.1fs" liveedit:"false"`
+	ResultsScreenStyle      ResultsStyle  `label:"Results screen style" combo:"0|osu!stable,1|Statistics" tooltip:"Statistics screen shows hit error histogram, accuracy graph, misses and 100s over the strain graph, key presses and PP breakdown. In knockout it shows a podium of top 3 players" liveedit:"false"`
+	ResultsUseLocalTimeZone bool          `label:"Show PC's time zone instead of UTC"`
 	ShowWarningArrows       bool
 	ShowHitLighting         bool
 	FlashlightDim           float64
 	PlayUsername            string `liveedit:"false"`
+	SaveReplays             bool   `label:"Save -play replays" tooltip:"Saves replays of -play sessions as .osr files in replays directory" liveedit:"false"`
 	IgnoreFailsInReplays    bool
 	UseLazerPP              bool `liveedit:"false" skip:"true"`
 }
@@ -312,6 +370,30 @@ type hitError struct {
 	ScaleWithSpeed       bool
 }
 
+type hitErrorTimeline struct {
+	*hudElementOffset
+	Height float64 `min:"10" max:"200" format:"%.0f o!px"`
+}
+
+type hitStatistics struct {
+	ShowInResults    bool    `label:"Show hit error statistics in results"`
+	SectionLength    float64 `label:"Section length" min:"1" max:"300" format:"%.0fs" tooltip:"Length of map sections used for per-section unstable rate"`
+	HistogramBinSize float64 `label:"Histogram bin size" min:"0.5" max:"20" format:"%.1fms"`
+	BiasWindow       int     `label:"Early/late bias window" string:"true" min:"1" max:"1000" tooltip:"Number of hits averaged when calculating early/late bias over time"`
+	ExportJSON       bool    `label:"Export statistics to JSON" tooltip:"Statistics are saved in danser's statistics directory when the map ends"`
+}
+
+type practice struct {
+	LeadIn         float64 `label:"Lead-in time" max:"5" format:"%.1fs" tooltip:"Time before the first object of the section at which every attempt starts"`
+	RestartOnFail  bool    `label:"Restart on fail" tooltip:"Start the next attempt right away when HP drops to 0"`
+	RateStep       float64 `label:"Rate change between attempts" max:"0.5" format:"%.2fx" tooltip:"Rate is increased after attempts reaching target accuracy and decreased after the others, 0 keeps the rate constant"`
+	TargetAccuracy float64 `label:"Target accuracy" max:"100" format:"%.1f%%"`
+	MinRate        float64 `label:"Minimum rate" min:"0.5" max:"2" format:"%.2fx"`
+	MaxRate        float64 `label:"Maximum rate" min:"0.5" max:"2" format:"%.2fx"`
+	ShowAttempts   bool    `label:"Show attempts table"`
+	AttemptsShown  int     `label:"Attempts shown" string:"true" min:"1" max:"20" showif:"ShowAttempts=true"`
+}
+
 type aimError struct {
 	*hudElementPosition
 	PointFadeOutTime     float64 `max:"10" format:"%.1fs"`
@@ -345,6 +427,7 @@ type ppCounter struct {
 	Align            string `combo:"TopLeft,Top,TopRight,Left,Centre,Right,BottomLeft,Bottom,BottomRight"`
 	ShowInResults    bool
 	ShowPPComponents bool `label:"Show PP breakdown"`
+	ShowPPIfFC       bool `label:"Show PP if FC" tooltip:"PP if the rest of the map is FC'd with current accuracy"`
 	Static           bool
 }
 
@@ -372,6 +455,15 @@ type scoreBoard struct {
 	HideOthers     bool
 	ShowAvatars    bool
 	ExplosionScale float64 `min:"0.1" max:"2" scale:"100" format:"%.0f%%"`
+	OnlineScores   bool    `label:"Show osu! leaderboard" showif:"HideOthers=false" tooltip:"Scores from osu!api v1, requires the key in credentials.json"`
+	ReplayScores   bool    `label:"Show scores from local replays" showif:"HideOthers=false" tooltip:"Replays in danser's replays directory, grouped in directories named by map's MD5"`
+	LocalScores    bool    `label:"Show scores from -play sessions" showif:"HideOthers=false"`
+	ScoresFile     string  `label:"Scores file" file:"Select scores file" filter:"JSON file (*.json)|json" showif:"HideOthers=false" tooltip:"JSON file mapping map MD5s to lists of scores with name, score, combo and mods" liveedit:"false"`
+}
+
+type personalBest struct {
+	*hudElementPosition
+	Align string `combo:"TopLeft,Top,TopRight,Left,Centre,Right,BottomLeft,Bottom,BottomRight"`
 }
 
 type mods struct {
@@ -408,7 +500,61 @@ type outline struct {
 	InnerOpacity  float64 `scale:"100.0" format:"%.0f%%" tooltip:"Opacity of filled shape, only applicable when DrawOutline is enabled"`
 }
 
+type textWidget struct {
+	*hudElementPosition
+	Template     string  `long:"true" tooltip:"Text with variables in braces, e.g. {artist} - {title} [{version}] {mods} | {acc:.2f}% | {combo}x | {ur} UR | {pp}pp. Text after a colon sets number format"`
+	Font         string  `file:"Select widget font" filter:"TrueType/OpenType Font (*.ttf, *.otf)|ttf,otf" tooltip:"Empty uses Overlay (HUD) font" liveedit:"false"`
+	Size         float64 `min:"4" max:"200"`
+	Color        *HSV    `short:"true"`
+	Shadow       bool
+	ShadowColor  *HSV    `short:"true" showif:"Shadow=true"`
+	ShadowOffset float64 `min:"0" max:"10" showif:"Shadow=true"`
+	Anchor       string  `combo:"TopLeft,Top,TopRight,Left,Centre,Right,BottomLeft,Bottom,BottomRight" tooltip:"Point of the screen the position is relative to"`
+	Align        string  `combo:"TopLeft,Top,TopRight,Left,Centre,Right,BottomLeft,Bottom,BottomRight"`
+}
+
+func (d *defaultsFactory) InitTextWidget() *textWidget {
+	return &textWidget{
+		hudElementPosition: &hudElementPosition{
+			hudElement: &hudElement{
+				Show:    true,
+				Scale:   1.0,
+				Opacity: 1.0,
+			},
+			XPosition: 5,
+			YPosition: 5,
+		},
+		Template: "{artist} - {title} [{version}]",
+		Font:     "",
+		Size:     20,
+		Color: &HSV{
+			Hue:        0,
+			Saturation: 0,
+			Value:      1,
+		},
+		Shadow: true,
+		ShadowColor: &HSV{
+			Hue:        0,
+			Saturation: 0,
+			Value:      0,
+		},
+		ShadowOffset: 1,
+		Anchor:       "TopLeft",
+		Align:        "TopLeft",
+	}
+}
+
 type underlay struct {
 	Path       string `file:"Select underlay image" filter:"PNG file (*.png)|png" tooltip:"PNG file that will be used as HUD background (similar to custom HP bar backgrounds). It's scaled automatically to fit the screen vertically" liveedit:"false"`
 	AboveHpBar bool   `label:"Show underlay above HP bar" tooltip:"Use this if HP bar background is large"`
 }
+
+type ResultsStyle int
+
+const (
+	// StableResults shows osu!stable's ranking screen
+	StableResults = ResultsStyle(iota)
+
+	// StatisticsResults shows detailed statistics of the play, or a podium in knockout
+	StatisticsResults
+)
@@ -4,16 +4,20 @@ import "math"
 
 var DEBUG = false
 var PLAY = false
+var PRACTICE = false
 var SKIP = false
 var START = 0.0
 var END = math.Inf(1)
 var KNOCKOUT = false
 var KNOCKOUTREPLAYS []string = nil
+var KNOCKOUTTEAMS map[string]string = nil // replay path -> team name
+var SIMILARITY = false
 var PLAYERS = 1
 var DIVIDES = 1
 var SPEED = 1.0
 var PITCH = 1.0
 var TAG = 1
 var RECORD = false
+var TRANSPARENT = false
 var REPLAY = ""
 var LOCALOFFSET = 0
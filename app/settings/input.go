@@ -8,7 +8,10 @@ func initInput() *input {
 		RightKey:             "X",
 		RestartKey:           "`",
 		SmokeKey:             "C",
+		LoopStartKey:         "[",
+		LoopEndKey:           "]",
 		ScreenshotKey:        "F2",
+		HUDEditorKey:         "F6",
 		MouseButtonsDisabled: true,
 		MouseHighPrecision:   false,
 		MouseSensitivity:     1,
@@ -20,7 +23,10 @@ type input struct {
 	RightKey             string  `key:"true"`
 	RestartKey           string  `key:"true"`
 	SmokeKey             string  `key:"true"`
+	LoopStartKey         string  `key:"true" label:"Practice loop start key"`
+	LoopEndKey           string  `key:"true" label:"Practice loop end key"`
 	ScreenshotKey        string  `key:"true"`
+	HUDEditorKey         string  `key:"true" label:"HUD editor key" tooltip:"Toggles HUD layout editor in play mode or with -debug"`
 	MouseButtonsDisabled bool    `label:"Disable mouse buttons"`
 	MouseHighPrecision   bool    `label:"Mouse raw input"`
 	MouseSensitivity     float64 `label:"Raw input sensitivity" min:"0.4" max:"6"`
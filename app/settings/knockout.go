@@ -1,5 +1,10 @@
 package settings
 
+import (
+	"path/filepath"
+	"strings"
+)
+
 var Knockout = initKnockout()
 
 func initKnockout() *knockout {
@@ -18,12 +23,48 @@ func initKnockout() *knockout {
 		MaxCursorSize:       7.0,
 		AddDanser:           false,
 		DanserName:          "danser",
+		Rules: []*eliminationRule{
+			{
+				Condition: AccuracyBelowCondition,
+				Threshold: 90,
+				Interval:  30,
+				StartTime: 0,
+			},
+		},
+		ReviveAfter:    0,
+		ReviveInBreaks: false,
+		MaxRevives:     0,
+		Teams: &knockoutTeams{
+			Enabled:    false,
+			WinRule:    SumScore,
+			BestN:      2,
+			ComboBonus: 0.5,
+			ShowHeader: true,
+			List: []*knockoutTeam{
+				{
+					Name: "Red",
+					Color: &HSV{
+						Hue:        0,
+						Saturation: 0.75,
+						Value:      1,
+					},
+				},
+				{
+					Name: "Blue",
+					Color: &HSV{
+						Hue:        210,
+						Saturation: 0.75,
+						Value:      1,
+					},
+				},
+			},
+		},
 	}
 }
 
 type knockout struct {
 	// Knockout mode. More info below
-	Mode KnockoutMode `combo:"0|Combo Break,1|Max Combo,2|Replay Showcase,3|Vs Mode,4|SS or Quit" liveedit:"false"`
+	Mode KnockoutMode `combo:"0|Combo Break,1|Max Combo,2|Replay Showcase,3|Vs Mode,4|SS or Quit,5|Custom Rules" liveedit:"false"`
 
 	// In Mode = ComboBreak it won't knock out the player if they break combo before GraceEndTime (in seconds)
 	GraceEndTime float64 `string:"true" min:"-10" max:"1000000" showif:"Mode=0"`
@@ -41,16 +82,28 @@ type knockout struct {
 	MaxPlayers int `skip:"true" label:"Max players loaded (legacy)" string:"true" min:"0" max:"100" tooltip:"Applicable only to classic knockout"`
 
 	// Min players shown on a map.
-	MinPlayers int `label:"Minimum alive players" string:"true" min:"0" max:"100" showif:"Mode=0,1,4"`
+	MinPlayers int `label:"Minimum alive players" string:"true" min:"0" max:"100" showif:"Mode=0,1,4,5"`
 
 	// Whether knocked out players should appear on map end
-	RevivePlayersAtEnd bool `showif:"Mode=0,1,4"`
+	RevivePlayersAtEnd bool `showif:"Mode=0,1,4,5"`
+
+	// In Mode = CustomRules player is knocked out when any of the rules is met
+	Rules []*eliminationRule `new:"InitEliminationRule" label:"Elimination rules" showif:"Mode=5" liveedit:"false"`
+
+	// In Mode = CustomRules knocked out players come back after ReviveAfter seconds, 0 disables it
+	ReviveAfter float64 `label:"Revive players after" min:"0" max:"600" format:"%.0fs" showif:"Mode=5" liveedit:"false"`
+
+	// In Mode = CustomRules knocked out players come back when a break starts
+	ReviveInBreaks bool `label:"Revive players in breaks" showif:"Mode=5" liveedit:"false"`
+
+	// In Mode = CustomRules how many times a player can be revived, 0 means no limit
+	MaxRevives int `label:"Max revives per player" string:"true" min:"0" max:"100" showif:"Mode=5" liveedit:"false"`
 
 	// Whether scores should be sorted in real time
 	LiveSort bool
 
-	// Whether players should be sorted by Score or PP
-	SortBy string `combo:"Score,PP,Accuracy"`
+	// Whether players should be sorted by Score, PP, PP if FC, PP at current position or Accuracy
+	SortBy string `combo:"Score,PP,PP if FC,Accuracy"`
 
 	// Whether knockout overlay (player list with stats) should be hidden in breaks
 	HideOverlayOnBreaks bool
@@ -64,6 +117,103 @@ type knockout struct {
 	// Self explanatory
 	AddDanser  bool   `liveedit:"false"`
 	DanserName string `label:"Danser's name" tooltip:"It's also used in danser replay mode" liveedit:"false"`
+
+	Teams *knockoutTeams
+}
+
+type eliminationRule struct {
+	Condition EliminationCondition `combo:"0|Combo break,1|Combo break near max combo,2|Anything other than 300,3|Accuracy below,4|Misses above,5|HP below,6|Lowest score every interval,7|Last place at each break"`
+
+	// Accuracy in %, miss count or HP in %
+	Threshold float64 `string:"true" min:"0" max:"100000" showif:"Condition=3,4,5" tooltip:"Accuracy in %, miss count or HP in %"`
+
+	// Time between eliminations in seconds
+	Interval float64 `min:"1" max:"600" format:"%.0fs" showif:"Condition=6"`
+
+	// Grace period, rule is active after StartTime (in seconds) and AfterObject
+	StartTime   float64 `label:"Active after time (s)" string:"true" min:"-10" max:"1000000" tooltip:"Grace period in seconds, the rule is ignored before that time"`
+	AfterObject int     `label:"Active after object" string:"true" min:"0" max:"1000000" tooltip:"The rule is ignored before N-th hit object"`
+}
+
+func (d *defaultsFactory) InitEliminationRule() *eliminationRule {
+	return &eliminationRule{
+		Condition: ComboBreakCondition,
+		Threshold: 90,
+		Interval:  30,
+	}
+}
+
+type knockoutTeams struct {
+	// Whether players should be grouped into teams
+	Enabled bool `liveedit:"false"`
+
+	// How team score is calculated from scores of its members
+	WinRule TeamWinRule `combo:"0|Sum of scores,1|Best N scores,2|Combo bonus" showif:"Enabled=true"`
+
+	// In WinRule = BestN only N highest scores in the team count
+	BestN int `label:"N" string:"true" min:"1" max:"100" showif:"WinRule=1"`
+
+	// In WinRule = ComboBonus member's score is multiplied by 1 + ComboBonus * (member's max combo / highest max combo in the match)
+	ComboBonus float64 `scale:"100.0" format:"%.0f%%" min:"0" max:"2" showif:"WinRule=2"`
+
+	// Whether team scores should be shown in a bar at the top of the screen
+	ShowHeader bool `label:"Show team score header" showif:"Enabled=true"`
+
+	// Teams with members given by username or replay file name. Teams given in knockout2 replay list are added automatically
+	List []*knockoutTeam `new:"InitKnockoutTeam" label:"Teams" showif:"Enabled=true"`
+}
+
+type knockoutTeam struct {
+	Name    string
+	Color   *HSV   `short:"true"`
+	Members string `long:"true" tooltip:"Comma-separated usernames or replay file names"`
+}
+
+func (d *defaultsFactory) InitKnockoutTeam() *knockoutTeam {
+	return &knockoutTeam{
+		Name: "Team",
+		Color: &HSV{
+			Hue:        120,
+			Saturation: 0.75,
+			Value:      1,
+		},
+	}
+}
+
+// FindTeam returns team's name for the replay, team given in knockout2 replay list has priority over team members set in settings
+func (teams *knockoutTeams) FindTeam(path, username string) string {
+	if team, ok := KNOCKOUTTEAMS[path]; ok {
+		return team
+	}
+
+	fileName := filepath.Base(path)
+
+	for _, team := range teams.List {
+		for _, member := range strings.Split(team.Members, ",") {
+			member = strings.TrimSpace(member)
+
+			if member == "" {
+				continue
+			}
+
+			if strings.EqualFold(member, username) || (path != "" && (member == fileName || member == strings.TrimSuffix(fileName, filepath.Ext(fileName)))) {
+				return team.Name
+			}
+		}
+	}
+
+	return ""
+}
+
+// GetTeam returns team's settings or nil if team is not defined in settings
+func (teams *knockoutTeams) GetTeam(name string) *knockoutTeam {
+	for _, team := range teams.List {
+		if team.Name == name {
+			return team
+		}
+	}
+
+	return nil
 }
 
 type KnockoutMode int
@@ -83,4 +233,48 @@ const (
 
 	// Forced Perfect mod
 	SSOrQuit
+
+	// Players get knocked out by rules set in Knockout.Rules
+	CustomRules
+)
+
+type EliminationCondition int
+
+const (
+	// Player is knocked out on combo break
+	ComboBreakCondition = EliminationCondition(iota)
+
+	// ComboBreakCondition but only when player reached their max combo on the map
+	MaxComboBreakCondition
+
+	// Player is knocked out on anything other than 300
+	NonPerfectCondition
+
+	// Player is knocked out when their accuracy drops below Threshold
+	AccuracyBelowCondition
+
+	// Player is knocked out when their miss count exceeds Threshold
+	MissesAboveCondition
+
+	// Player is knocked out when their HP drops below Threshold
+	HPBelowCondition
+
+	// Player with the lowest score is knocked out every Interval
+	LowestScoreInterval
+
+	// Player with the lowest score is knocked out when a break starts
+	LastPlaceAtBreak
+)
+
+type TeamWinRule int
+
+const (
+	// Team score is a sum of its members' scores
+	SumScore = TeamWinRule(iota)
+
+	// Team score is a sum of N highest scores in the team
+	BestN
+
+	// SumScore but scores are boosted by members' max combo
+	ComboBonus
 )
@@ -1,6 +1,7 @@
 package settings
 
 import (
+	"encoding/json"
 	"github.com/fsnotify/fsnotify"
 	"github.com/karrick/godirwalk"
 	"github.com/wieku/danser-go/framework/env"
@@ -132,6 +133,17 @@ func CloseWatcher() {
 	}
 }
 
+// ApplyOverrides changes settings present in given JSON object without saving them
+func ApplyOverrides(data []byte) error {
+	if err := json.Unmarshal(data, currentConfig); err != nil {
+		return err
+	}
+
+	currentConfig.attachToGlobals()
+
+	return nil
+}
+
 func Save() {
 	currentConfig.Save(filePath, false)
 }
@@ -14,6 +14,8 @@ func initRecording() *recording {
 		FrameHeight:    1080,
 		FPS:            60,
 		EncodingFPSCap: 0,
+		OutputType:     "video",
+		Transparent:    false,
 		Encoder:        "libx264",
 		X264Settings: &x264Settings{
 			RateControl:       "crf",
@@ -90,7 +92,10 @@ func initRecording() *recording {
 		AudioFilters:   "",
 		OutputDir:      "videos",
 		Container:      "mp4",
+		SaveWAV:        false,
+		AudioStems:     "none",
 		ShowFFmpegLogs: true,
+		SegmentLength:  0,
 		MotionBlur: &motionblur{
 			Enabled:              false,
 			OversampleMultiplier: 16,
@@ -98,6 +103,12 @@ func initRecording() *recording {
 			BlendFunctionID:      27,
 			GaussWeightsMult:     1.5,
 		},
+		Highlights: &highlights{
+			Enabled:    false,
+			Thumbnails: 5,
+			Clips:      3,
+			ClipLength: 10,
+		},
 	}
 }
 
@@ -107,7 +118,9 @@ type recording struct {
 	FrameHeight         int                `min:"1" max:"17280"`
 	FPS                 int                `label:"FPS (PLEASE READ TOOLTIP)" string:"true" min:"1" max:"10727" tooltip:"IMPORTANT: If you plan to have a \"high fps\" video, use Motion Blur below instead of setting FPS to absurd numbers. Setting the value too high will result in a broken video!"`
 	EncodingFPSCap      int                `string:"true" min:"0" max:"10727" label:"Max Encoding FPS (Speed)" tooltip:"Limits the speed at which danser renders the video. If FPS is set to 60 and this option to 30, then it means 2 minute map will take at least 4 minutes to render"`
-	Encoder             string             `combo:"libx264|Software x264 (AVC),libx265|Software x265 (HEVC),h264_nvenc|NVIDIA NVENC H.264 (AVC),hevc_nvenc|NVIDIA NVENC H.265 (HEVC),h264_qsv|Intel QuickSync H.264 (AVC),hevc_qsv|Intel QuickSync H.265 (HEVC)" tooltip:"Even if AMD cards have their own hardware encoder, you will still get better results with software encoders"`
+	OutputType          string             `combo:"video|Encoded video,ffv1|FFV1 (lossless),prores|ProRes 4444 (intermediate),png|PNG sequence,tiff|TIFF sequence,exr|OpenEXR sequence" tooltip:"Lossless videos and image sequences are meant for further editing and take a lot of space. Image sequences are saved in a directory with the name of the recording and audio is saved next to it as a WAV file"`
+	Transparent         bool               `showif:"OutputType=!video" tooltip:"Background, storyboard and dim are not drawn, so the playfield can be composited over other footage"`
+	Encoder             string             `showif:"OutputType=video" combo:"libx264|Software x264 (AVC),libx265|Software x265 (HEVC),h264_nvenc|NVIDIA NVENC H.264 (AVC),hevc_nvenc|NVIDIA NVENC H.265 (HEVC),h264_qsv|Intel QuickSync H.264 (AVC),hevc_qsv|Intel QuickSync H.265 (HEVC)" tooltip:"Even if AMD cards have their own hardware encoder, you will still get better results with software encoders"`
 	X264Settings        *x264Settings      `json:"libx264" label:"Software x264 (AVC) Settings" showif:"Encoder=libx264"`
 	X265Settings        *x265Settings      `json:"libx265" label:"Software x265 (HEVC) Settings" showif:"Encoder=libx265"`
 	H264NvencSettings   *h264NvencSettings `json:"h264_nvenc" label:"NVIDIA NVENC H.264 (AVC) Settings" showif:"Encoder=h264_nvenc"`
@@ -126,9 +139,13 @@ type recording struct {
 	//AudioOptions        string             `label:"Audio Encoder Options"`
 	AudioFilters   string `label:"FFmpeg Audio Filters"`
 	OutputDir      string `path:"Select video output directory"`
-	Container      string `combo:"mp4,mkv"`
+	Container      string `combo:"mp4,mkv" showif:"OutputType=video"`
+	SaveWAV        bool   `label:"Save WAV audio" tooltip:"Additionally save uncompressed audio as a WAV file next to the video"`
+	AudioStems     string `combo:"none|Disabled,tracks|Separate audio tracks,wav|Separate WAV files" label:"Audio stems" tooltip:"Record music, hitsounds and storyboard samples separately, so they can be rebalanced later. Tracks are added to the video after the main mix, image sequences always use WAV files"`
 	ShowFFmpegLogs bool
+	SegmentLength  int `string:"true" min:"0" max:"3600" label:"Segment length (seconds)" tooltip:"Video is recorded in segments of this length, so an interrupted recording can be continued with -resume flag. 0 records everything in one piece"`
 	MotionBlur     *motionblur
+	Highlights     *highlights
 
 	outDir *string
 }
@@ -167,6 +184,49 @@ func (g *recording) GetAudioOptions() EncoderOptions {
 	}
 }
 
+// IsImageSequence returns true if frames are saved as separate images instead of a video
+func (g *recording) IsImageSequence() bool {
+	switch strings.ToLower(g.OutputType) {
+	case "png", "tiff", "exr":
+		return true
+	}
+
+	return false
+}
+
+// UsesAlpha returns true if recorded frames have transparent background
+func (g *recording) UsesAlpha() bool {
+	return g.Transparent && strings.ToLower(g.OutputType) != "video"
+}
+
+// UsesStems returns true if music, hitsounds and storyboard samples are recorded separately
+func (g *recording) UsesStems() bool {
+	return g.AudioStems != "" && g.AudioStems != "none"
+}
+
+// GetContainer returns the extension of recorded video or images
+func (g *recording) GetContainer() string {
+	switch t := strings.ToLower(g.OutputType); t {
+	case "ffv1":
+		return "mkv"
+	case "prores":
+		return "mov"
+	case "png", "tiff", "exr":
+		return t
+	}
+
+	return g.Container
+}
+
+// GetOutputPath returns the path of recording with given name, image sequences are saved in a directory
+func (g *recording) GetOutputPath(name string) string {
+	if g.IsImageSequence() {
+		return filepath.Join(g.GetOutputDir(), name)
+	}
+
+	return filepath.Join(g.GetOutputDir(), name+"."+g.GetContainer())
+}
+
 func (g *recording) GetOutputDir() string {
 	if g.outDir == nil {
 		dir := filepath.Join(env.DataDir(), g.OutputDir)
@@ -190,6 +250,13 @@ type motionblur struct {
 	BlendWeights         *blendWeights `json:",omitempty"` // Deprecated
 }
 
+type highlights struct {
+	Enabled    bool    `tooltip:"Moments like kiai with high combo, big pp gains, knockout eliminations and full combos are scored during recording. The best ones are saved as thumbnails and clips in a directory next to the video"`
+	Thumbnails int     `showif:"Enabled=true" string:"true" min:"0" max:"50" tooltip:"How many PNG thumbnails should be saved"`
+	Clips      int     `showif:"Enabled=true" string:"true" min:"0" max:"50" tooltip:"How many highlight clips should be cut from the video. Image sequences have only their time ranges saved"`
+	ClipLength float64 `showif:"Enabled=true" string:"true" min:"1" max:"120" label:"Clip length (seconds)"`
+}
+
 type blendWeights struct {
 	UseManualWeights bool
 	ManualWeights    string  `showif:"UseManualWeights=true"`
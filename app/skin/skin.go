@@ -37,6 +37,10 @@ var textureLock = &sync.Mutex{}
 
 var atlas *texture.TextureAtlas
 
+// Textures of current and fallback skins are kept apart from the default skin, so they can be disposed by Reload
+var skinAtlas *texture.TextureAtlas
+var skinTextures []*texture.TextureSingle
+
 var animationCache = make(map[string][]*texture.TextureRegion)
 
 var skinCache = make(map[string]*texture.TextureRegion)
@@ -57,6 +61,9 @@ var FallbackSkin = defaultName
 
 var info *SkinInfo
 
+// Skin.CurrentSkin and Skin.FallbackSkin settings used to load current skin
+var loadedSkin, loadedFallback string
+
 func loadDefault() {
 	CurrentSkin = defaultName
 	FallbackSkin = defaultName
@@ -75,6 +82,8 @@ func checkInit() {
 		return
 	}
 
+	loadedSkin, loadedFallback = settings.Skin.CurrentSkin, settings.Skin.FallbackSkin
+
 	tryLoadSkin(settings.Skin.CurrentSkin, settings.Skin.FallbackSkin)
 
 	log.Println(fmt.Sprintf("SkinManager: Skin \"%s\" loaded.", CurrentSkin))
@@ -122,6 +131,58 @@ func tryLoadSkin(name, fallbackName string) {
 	}
 }
 
+// Reload unloads current skin if Skin.CurrentSkin or Skin.FallbackSkin settings have changed, new one is loaded on next use.
+// Textures of the default skin are kept. It has to be called on the main thread.
+func Reload() {
+	if info == nil || (settings.Skin.CurrentSkin == loadedSkin && settings.Skin.FallbackSkin == loadedFallback) {
+		return
+	}
+
+	log.Println("SkinManager: Unloading skin:", CurrentSkin)
+
+	textureLock.Lock()
+	fontLock.Lock()
+	soundLock.Lock()
+
+	info = nil
+
+	skinPathCache = nil
+	fallbackPathCache = nil
+
+	if skinAtlas != nil {
+		skinAtlas.Dispose()
+		skinAtlas = nil
+	}
+
+	for _, tx := range skinTextures {
+		tx.Dispose()
+	}
+
+	skinTextures = nil
+
+	for _, sample := range sampleCache {
+		if sample != nil {
+			sample.Free()
+		}
+	}
+
+	animationCache = make(map[string][]*texture.TextureRegion)
+	skinCache = make(map[string]*texture.TextureRegion)
+	fallbackCache = make(map[string]*texture.TextureRegion)
+	fontCache = make(map[string]*font.Font)
+	sampleCache = make(map[string]*bass.Sample)
+
+	for region, source := range sourceCache {
+		if source != LOCAL {
+			delete(sourceCache, region)
+		}
+	}
+
+	soundLock.Unlock()
+	fontLock.Unlock()
+	textureLock.Unlock()
+}
+
 func GetInfo() *SkinInfo {
 	checkInit()
 	return info
@@ -315,16 +376,27 @@ func GetSourceFromTexture(rg *texture.TextureRegion) Source {
 	return sourceCache[rg]
 }
 
-func checkAtlas() {
-	if atlas == nil {
-		mipmaps := 0
-		if settings.RECORD {
-			mipmaps = 4
+// getAtlas returns the atlas for textures from given source, creating it if needed
+func getAtlas(source Source) *texture.TextureAtlas {
+	mipmaps := 0
+	if settings.RECORD {
+		mipmaps = 4
+	}
+
+	if source != LOCAL {
+		if skinAtlas == nil {
+			skinAtlas = texture.NewTextureAtlas(2048, mipmaps)
 		}
 
+		return skinAtlas
+	}
+
+	if atlas == nil {
 		atlas = texture.NewTextureAtlas(2048, mipmaps)
 		atlas.Bind(27)
 	}
+
+	return atlas
 }
 
 func getPixmap(name string, source Source) (*texture.Pixmap, error) {
@@ -372,12 +444,10 @@ func loadTexture(name string, source Source) *texture.TextureRegion {
 	if region != nil {
 		// Upload this texture in GL thread
 		mainthread.CallNonBlock(func() {
-			checkAtlas()
-
 			var rg *texture.TextureRegion
 
 			if image.Width <= 1000 && image.Height <= 1000 {
-				rg = atlas.AddTexture(name, image.Width, image.Height, image.Data)
+				rg = getAtlas(source).AddTexture(name, image.Width, image.Height, image.Data)
 			}
 
 			// If texture is too big load it separately
@@ -390,6 +460,10 @@ func loadTexture(name string, source Source) *texture.TextureRegion {
 				tx := texture.NewTextureSingle(image.Width, image.Height, mipmaps)
 				tx.SetData(0, 0, image.Width, image.Height, image.Data)
 
+				if source != LOCAL {
+					skinTextures = append(skinTextures, tx)
+				}
+
 				reg := tx.GetRegion()
 				rg = &reg
 
@@ -492,6 +566,12 @@ func AddBeatmapColor(data []string) {
 	})
 }
 
+// ClearBeatmapColors removes combo colors of previously loaded beatmap
+func ClearBeatmapColors() {
+	beatmapColorsI = nil
+	beatmapColors = nil
+}
+
 func FinishBeatmapColors() {
 	if len(beatmapColorsI) > 0 {
 		sort.SliceStable(beatmapColorsI, func(i, j int) bool {
@@ -0,0 +1,137 @@
+package elimination
+
+import (
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/math/mutils"
+)
+
+// Engine decides which knockout players are eliminated or revived. A player is knocked out when any of the rules is met.
+type Engine struct {
+	rules []*Rule
+
+	minAlive int
+
+	reviveAfter    int64
+	reviveInBreaks bool
+	maxRevives     int
+
+	lastObject int64
+}
+
+// NewEngine creates rules from settings.Knockout. Classic knockout modes are translated to equivalent rules.
+func NewEngine() *Engine {
+	engine := &Engine{
+		minAlive: settings.Knockout.MinPlayers,
+	}
+
+	switch settings.Knockout.Mode {
+	case settings.ComboBreak:
+		engine.rules = append(engine.rules, &Rule{
+			Condition: settings.ComboBreakCondition,
+			StartTime: int64(settings.Knockout.GraceEndTime*1000) + 1,
+		})
+	case settings.MaxCombo:
+		engine.rules = append(engine.rules, &Rule{Condition: settings.MaxComboBreakCondition})
+	case settings.SSOrQuit:
+		engine.rules = append(engine.rules, &Rule{Condition: settings.NonPerfectCondition})
+	case settings.CustomRules:
+		for _, r := range settings.Knockout.Rules {
+			engine.rules = append(engine.rules, &Rule{
+				Condition:   r.Condition,
+				Threshold:   r.Threshold,
+				Interval:    mutils.Max(r.Interval, 1) * 1000,
+				StartTime:   int64(r.StartTime * 1000),
+				AfterObject: int64(r.AfterObject),
+			})
+		}
+
+		engine.reviveAfter = int64(settings.Knockout.ReviveAfter * 1000)
+		engine.reviveInBreaks = settings.Knockout.ReviveInBreaks
+		engine.maxRevives = settings.Knockout.MaxRevives
+	}
+
+	return engine
+}
+
+// HasRules returns false if players can't be knocked out, e.g. in Replay Showcase mode
+func (engine *Engine) HasRules() bool {
+	return len(engine.rules) > 0
+}
+
+// Hit checks player's judgement and returns true if the player should be knocked out
+func (engine *Engine) Hit(player Player, hit Hit, alive int) bool {
+	engine.lastObject = mutils.Max(engine.lastObject, hit.Number)
+
+	if player.Eliminated || alive <= engine.minAlive {
+		return false
+	}
+
+	for _, rule := range engine.rules {
+		if rule.checkHit(player, hit) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Update checks timed rules and returns indices of players to knock out
+func (engine *Engine) Update(time int64, players []Player) []int {
+	return engine.checkAlive(players, func(rule *Rule, alive []Player) int {
+		return rule.checkTime(time, engine.lastObject, alive)
+	})
+}
+
+// BreakStarted checks rules applied at breaks and returns indices of players to knock out
+func (engine *Engine) BreakStarted(time int64, players []Player) []int {
+	return engine.checkAlive(players, func(rule *Rule, alive []Player) int {
+		return rule.checkBreak(time, engine.lastObject, alive)
+	})
+}
+
+// Revive returns indices of knocked out players that should come back. breakStarted should be true only at the beginning of a break.
+func (engine *Engine) Revive(time int64, breakStarted bool, players []Player) (revived []int) {
+	for _, p := range players {
+		if !p.Eliminated || (engine.maxRevives > 0 && p.Revives >= engine.maxRevives) {
+			continue
+		}
+
+		if (engine.reviveAfter > 0 && time-p.EliminatedAt >= engine.reviveAfter) || (engine.reviveInBreaks && breakStarted) {
+			revived = append(revived, p.Index)
+		}
+	}
+
+	return
+}
+
+func (engine *Engine) checkAlive(players []Player, check func(rule *Rule, alive []Player) int) (eliminated []int) {
+	alive := make([]Player, 0, len(players))
+
+	for _, p := range players {
+		if !p.Eliminated {
+			alive = append(alive, p)
+		}
+	}
+
+	for _, rule := range engine.rules {
+		if len(alive) <= engine.minAlive {
+			break
+		}
+
+		index := check(rule, alive)
+		if index < 0 {
+			continue
+		}
+
+		eliminated = append(eliminated, index)
+
+		for i, p := range alive {
+			if p.Index == index {
+				alive = append(alive[:i], alive[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return
+}
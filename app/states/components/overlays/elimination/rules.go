@@ -0,0 +1,122 @@
+package elimination
+
+import (
+	"github.com/wieku/danser-go/app/settings"
+	"math"
+)
+
+// Player is a snapshot of knockout player's state used to check the rules
+type Player struct {
+	// Index of the player in dance.ReplayController
+	Index int
+
+	Score    int64
+	Accuracy float64
+	Combo    int64
+	MaxCombo int64 // Max combo from replay file
+	Misses   int64
+	HP       float64 // 0..1
+
+	Eliminated   bool
+	EliminatedAt int64
+	Revives      int
+}
+
+// Hit describes the judgement the player just received
+type Hit struct {
+	Time   int64
+	Number int64
+
+	// Whether the judgement was 100, 50 or a miss
+	NonPerfect bool
+	ComboBreak bool
+}
+
+// Rule knocks out players when its condition is met. Rule is active after StartTime and AfterObject.
+type Rule struct {
+	Condition settings.EliminationCondition
+
+	// Accuracy in %, miss count or HP in %, depending on Condition
+	Threshold float64
+
+	// Interval in ms for settings.LowestScoreInterval
+	Interval float64
+
+	StartTime   int64
+	AfterObject int64
+
+	nextCheck float64
+}
+
+func (rule *Rule) isActive(time, number int64) bool {
+	return time >= rule.StartTime && number >= rule.AfterObject
+}
+
+// checkHit returns true if the player should be knocked out after the judgement
+func (rule *Rule) checkHit(player Player, hit Hit) bool {
+	if !rule.isActive(hit.Time, hit.Number) {
+		return false
+	}
+
+	switch rule.Condition {
+	case settings.ComboBreakCondition:
+		return hit.ComboBreak && hit.Number != 0
+	case settings.MaxComboBreakCondition:
+		return hit.ComboBreak && hit.Number != 0 && math.Abs(float64(player.Combo-player.MaxCombo)) < 5
+	case settings.NonPerfectCondition:
+		return hit.ComboBreak || hit.NonPerfect
+	case settings.AccuracyBelowCondition:
+		return player.Accuracy < rule.Threshold
+	case settings.MissesAboveCondition:
+		return float64(player.Misses) > rule.Threshold
+	case settings.HPBelowCondition:
+		return player.HP*100 < rule.Threshold
+	}
+
+	return false
+}
+
+// checkTime returns the player to knock out if rule's interval has passed, -1 otherwise
+func (rule *Rule) checkTime(time, lastObject int64, alive []Player) int {
+	if rule.Condition != settings.LowestScoreInterval || !rule.isActive(time, lastObject) {
+		return -1
+	}
+
+	if rule.nextCheck == 0 {
+		rule.nextCheck = float64(time) + rule.Interval
+		return -1
+	}
+
+	if float64(time) < rule.nextCheck {
+		return -1
+	}
+
+	rule.nextCheck += rule.Interval
+
+	return lowestScore(alive)
+}
+
+// checkBreak returns the player to knock out when a break starts, -1 otherwise
+func (rule *Rule) checkBreak(time, lastObject int64, alive []Player) int {
+	if rule.Condition != settings.LastPlaceAtBreak || !rule.isActive(time, lastObject) {
+		return -1
+	}
+
+	return lowestScore(alive)
+}
+
+func lowestScore(alive []Player) int {
+	if len(alive) == 0 {
+		return -1
+	}
+
+	lowest := alive[0]
+
+	for _, p := range alive[1:] {
+		if p.Score < lowest.Score {
+			lowest = p
+		}
+	}
+
+	return lowest.Index
+}
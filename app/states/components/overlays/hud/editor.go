@@ -0,0 +1,270 @@
+package hud
+
+import (
+	"fmt"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/wieku/danser-go/app/input"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/graphics/batch"
+	"github.com/wieku/danser-go/framework/graphics/font"
+	"github.com/wieku/danser-go/framework/graphics/shape"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"github.com/wieku/danser-go/framework/platform"
+	"log"
+	"math"
+	"strings"
+)
+
+const (
+	scaleStep    = 0.05
+	rotationStep = 5.0
+)
+
+type trackedElement struct {
+	name      string
+	bounds    Bounds
+	transform mgl32.Mat4
+}
+
+// Editor allows moving HUD elements with the mouse while playing, the layout is saved with Ctrl+S
+type Editor struct {
+	layout *Layout
+
+	width  float64
+	height float64
+
+	active bool
+
+	// Elements drawn in the last frame, in drawing order
+	elements []trackedElement
+
+	selected   string
+	dragging   bool
+	grabOffset vector.Vector2d
+
+	shapeRenderer *shape.Renderer
+	font          *font.Font
+}
+
+func NewEditor(layout *Layout, width, height float64) *Editor {
+	return &Editor{
+		layout:        layout,
+		width:         width,
+		height:        height,
+		shapeRenderer: shape.NewRenderer(),
+		font:          font.GetFont("Quicksand Bold"),
+	}
+}
+
+func (editor *Editor) IsActive() bool {
+	return editor.active
+}
+
+func (editor *Editor) KeyEvent(_ *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if action == glfw.Release {
+		return
+	}
+
+	if kName, ok := platform.GetKeyName(key, scancode); ok && action == glfw.Press && strings.EqualFold(kName, settings.Input.HUDEditorKey) {
+		editor.active = !editor.active
+		editor.dragging = false
+
+		if editor.active {
+			log.Println("HUD editor enabled")
+		} else {
+			log.Println("HUD editor disabled")
+		}
+
+		return
+	}
+
+	if !editor.active {
+		return
+	}
+
+	if key == glfw.KeyS && mods&glfw.ModControl > 0 {
+		editor.save()
+		return
+	}
+
+	element := editor.getSelected()
+	if element == nil {
+		return
+	}
+
+	switch key {
+	case glfw.KeyEqual, glfw.KeyKPAdd:
+		element.Scale += scaleStep
+	case glfw.KeyMinus, glfw.KeyKPSubtract:
+		element.Scale = math.Max(scaleStep, element.Scale-scaleStep)
+	case glfw.KeyComma:
+		element.Rotation -= rotationStep
+	case glfw.KeyPeriod:
+		element.Rotation += rotationStep
+	case glfw.KeyPageUp:
+		element.Z++
+	case glfw.KeyPageDown:
+		element.Z--
+	case glfw.KeyDelete:
+		editor.layout.Reset(editor.selected)
+		editor.selected = ""
+	}
+}
+
+// Track registers the element drawn in the current frame so it can be picked with the mouse
+func (editor *Editor) Track(name string, bounds Bounds, transform mgl32.Mat4) {
+	if !editor.active {
+		return
+	}
+
+	editor.elements = append(editor.elements, trackedElement{
+		name:      name,
+		bounds:    bounds,
+		transform: transform,
+	})
+}
+
+// Update handles mouse dragging, it has to be called on the main thread
+func (editor *Editor) Update() {
+	if !editor.active || input.Win == nil {
+		return
+	}
+
+	mouse := editor.getMousePosition()
+
+	if input.Win.GetMouseButton(glfw.MouseButtonLeft) != glfw.Press {
+		editor.dragging = false
+		return
+	}
+
+	if !editor.dragging {
+		tracked := editor.pick(mouse)
+		if tracked == nil {
+			editor.selected = ""
+			return
+		}
+
+		element := editor.layout.Place(tracked.name, tracked.bounds, editor.width, editor.height)
+
+		editor.selected = tracked.name
+		editor.dragging = true
+		editor.grabOffset = vector.NewVec2d(element.X, element.Y).Sub(mouse)
+	}
+
+	if element := editor.layout.Get(editor.selected); element != nil {
+		position := mouse.Add(editor.grabOffset)
+
+		element.X = math.Round(position.X)
+		element.Y = math.Round(position.Y)
+	} else {
+		editor.dragging = false
+	}
+}
+
+// Draw outlines tracked elements and shows the controls
+func (editor *Editor) Draw(batch *batch.QuadBatch, alpha float64) {
+	if !editor.active {
+		return
+	}
+
+	batch.Flush()
+
+	editor.shapeRenderer.SetCamera(batch.Projection)
+	editor.shapeRenderer.Begin()
+
+	for _, tracked := range editor.elements {
+		if tracked.name == editor.selected {
+			editor.shapeRenderer.SetColor(1, 0.8, 0.2, alpha)
+		} else {
+			editor.shapeRenderer.SetColor(1, 1, 1, 0.6*alpha)
+		}
+
+		corners := tracked.corners()
+
+		for i := range corners {
+			p1, p2 := corners[i], corners[(i+1)%len(corners)]
+			editor.shapeRenderer.DrawLine(p1.X32(), p1.Y32(), p2.X32(), p2.Y32(), 1.5)
+		}
+	}
+
+	editor.shapeRenderer.End()
+
+	batch.ResetTransform()
+
+	for _, tracked := range editor.elements {
+		topLeft := tracked.corners()[0]
+
+		batch.SetColor(1, 1, 1, alpha)
+		editor.font.DrawOrigin(batch, topLeft.X, topLeft.Y-2, vector.BottomLeft, 14, false, tracked.name)
+	}
+
+	help := "HUD editor: drag to move, +/- scale, ,/. rotate, PgUp/PgDn z-order, Del reset, Ctrl+S save"
+	if element := editor.layout.Get(editor.selected); element != nil {
+		help = fmt.Sprintf("%s: x %.0f, y %.0f, scale %.2f, rotation %.0f°, z %d | %s", editor.selected, element.X, element.Y, element.Scale, element.Rotation, element.Z, help)
+	}
+
+	batch.SetColor(0, 0, 0, alpha*0.8)
+	editor.font.DrawOrigin(batch, editor.width/2+1, editor.height-7, vector.BottomCentre, 16, false, help)
+
+	batch.SetColor(1, 1, 1, alpha)
+	editor.font.DrawOrigin(batch, editor.width/2, editor.height-8, vector.BottomCentre, 16, false, help)
+
+	editor.elements = editor.elements[:0]
+}
+
+func (editor *Editor) save() {
+	if err := editor.layout.Save(); err != nil {
+		log.Println("Failed to save HUD layout:", err)
+		return
+	}
+
+	log.Println("HUD layout saved to:", editor.layout.GetPath())
+}
+
+func (editor *Editor) getSelected() *Element {
+	if editor.selected == "" {
+		return nil
+	}
+
+	return editor.layout.Get(editor.selected)
+}
+
+// pick returns the top-most element under the given point
+func (editor *Editor) pick(point vector.Vector2d) *trackedElement {
+	for i := len(editor.elements) - 1; i >= 0; i-- {
+		tracked := &editor.elements[i]
+
+		local := tracked.transform.Inv().Mul4x1(mgl32.Vec4{point.X32(), point.Y32(), 0, 1})
+
+		if tracked.bounds.Contains(vector.NewVec2d(float64(local.X()), float64(local.Y()))) {
+			return tracked
+		}
+	}
+
+	return nil
+}
+
+func (editor *Editor) getMousePosition() vector.Vector2d {
+	wWidth, wHeight := input.Win.GetSize()
+	x, y := input.Win.GetCursorPos()
+
+	return vector.NewVec2d(x*editor.width/float64(mutils.Max(wWidth, 1)), y*editor.height/float64(mutils.Max(wHeight, 1)))
+}
+
+func (tracked *trackedElement) corners() []vector.Vector2d {
+	points := []vector.Vector2d{
+		tracked.bounds.Min,
+		vector.NewVec2d(tracked.bounds.Max.X, tracked.bounds.Min.Y),
+		tracked.bounds.Max,
+		vector.NewVec2d(tracked.bounds.Min.X, tracked.bounds.Max.Y),
+	}
+
+	for i, p := range points {
+		t := tracked.transform.Mul4x1(mgl32.Vec4{p.X32(), p.Y32(), 0, 1})
+		points[i] = vector.NewVec2d(float64(t.X()), float64(t.Y()))
+	}
+
+	return points
+}
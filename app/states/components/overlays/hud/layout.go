@@ -0,0 +1,238 @@
+package hud
+
+import (
+	"encoding/json"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Conditions in which an element can be hidden
+const (
+	HideInBreaks   = "breaks"
+	HideOnFail     = "fail"
+	HideInKnockout = "knockout"
+)
+
+// Bounds is a rectangle occupied by an element at its default placement, in HUD coordinates
+type Bounds struct {
+	Min vector.Vector2d
+	Max vector.Vector2d
+}
+
+func NewBounds(min, max vector.Vector2d) Bounds {
+	return Bounds{Min: min, Max: max}
+}
+
+func (bounds Bounds) Size() vector.Vector2d {
+	return bounds.Max.Sub(bounds.Min)
+}
+
+// Point returns a point inside bounds, origin is the same as in vector.ParseOrigin
+func (bounds Bounds) Point(origin vector.Vector2d) vector.Vector2d {
+	return bounds.Min.Add(origin.AddS(1, 1).Scl(0.5).Mult(bounds.Size()))
+}
+
+func (bounds Bounds) Contains(point vector.Vector2d) bool {
+	return point.X >= bounds.Min.X && point.X <= bounds.Max.X && point.Y >= bounds.Min.Y && point.Y <= bounds.Max.Y
+}
+
+// Element describes placement of a single HUD element
+type Element struct {
+	// Point of the screen the element is attached to, e.g. "TopRight"
+	Anchor string `json:"anchor"`
+
+	// Point of the element placed at Anchor + (X, Y)
+	Origin string `json:"origin"`
+
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+
+	// Multiplies the scale from settings
+	Scale float64 `json:"scale"`
+
+	// Rotation around Origin in degrees
+	Rotation float64 `json:"rotation"`
+
+	// Multiplies the opacity from settings
+	Opacity float64 `json:"opacity"`
+
+	// Conditions in which the element is hidden: "breaks", "fail" and/or "knockout"
+	Hide []string `json:"hide,omitempty"`
+
+	// Elements with higher Z are drawn on top
+	Z int `json:"z"`
+}
+
+func (element *Element) UnmarshalJSON(data []byte) error {
+	type plain Element
+
+	el := plain{
+		Anchor:  "TopLeft",
+		Origin:  "TopLeft",
+		Scale:   1,
+		Opacity: 1,
+	}
+
+	if err := json.Unmarshal(data, &el); err != nil {
+		return err
+	}
+
+	*element = Element(el)
+
+	return nil
+}
+
+// Transform returns the matrix moving the element from its default bounds to its place in the layout
+func (element *Element) Transform(bounds Bounds, width, height float64) mgl32.Mat4 {
+	anchor := vector.ParseOrigin(element.Anchor).AddS(1, 1).Scl(0.5).Mult(vector.NewVec2d(width, height))
+	target := anchor.AddS(element.X, element.Y)
+
+	origin := bounds.Point(vector.ParseOrigin(element.Origin))
+
+	return mgl32.Translate3D(target.X32(), target.Y32(), 0).
+		Mul4(mgl32.HomogRotate3DZ(float32(element.Rotation * math.Pi / 180))).
+		Mul4(mgl32.Scale3D(float32(element.Scale), float32(element.Scale), 1)).
+		Mul4(mgl32.Translate3D(-origin.X32(), -origin.Y32(), 0))
+}
+
+// IsHidden checks whether any of element's hide conditions is met
+func (element *Element) IsHidden(inBreak, failed, knockout bool) bool {
+	for _, condition := range element.Hide {
+		switch condition {
+		case HideInBreaks:
+			if inBreak {
+				return true
+			}
+		case HideOnFail:
+			if failed {
+				return true
+			}
+		case HideInKnockout:
+			if knockout {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Layout maps element names (the same as in Gameplay settings, e.g. "HpBar") to their placement.
+// Elements missing in the layout keep their default placement.
+type Layout struct {
+	Elements map[string]*Element `json:"elements"`
+
+	path string
+}
+
+func NewLayout(path string) *Layout {
+	return &Layout{
+		Elements: make(map[string]*Element),
+		path:     path,
+	}
+}
+
+func LoadLayout(path string) (*Layout, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := NewLayout(path)
+
+	if err = json.Unmarshal(data, layout); err != nil {
+		return nil, err
+	}
+
+	if layout.Elements == nil {
+		layout.Elements = make(map[string]*Element)
+	}
+
+	return layout, nil
+}
+
+// Get returns element's placement or nil if the element is not a part of the layout
+func (layout *Layout) Get(name string) *Element {
+	return layout.Elements[name]
+}
+
+// Place adds the element to the layout without moving it. Anchor and origin are chosen from the part of the screen the element is in.
+func (layout *Layout) Place(name string, bounds Bounds, width, height float64) *Element {
+	if element := layout.Elements[name]; element != nil {
+		return element
+	}
+
+	centre := bounds.Point(vector.Centre)
+
+	origin := vector.NewVec2d(alignPoint(centre.X, width), alignPoint(centre.Y, height))
+	originName := originName(origin)
+
+	anchor := origin.AddS(1, 1).Scl(0.5).Mult(vector.NewVec2d(width, height))
+	offset := bounds.Point(origin).Sub(anchor)
+
+	element := &Element{
+		Anchor:  originName,
+		Origin:  originName,
+		X:       offset.X,
+		Y:       offset.Y,
+		Scale:   1,
+		Opacity: 1,
+	}
+
+	layout.Elements[name] = element
+
+	return element
+}
+
+// Reset removes the element from the layout so it uses its default placement again
+func (layout *Layout) Reset(name string) {
+	delete(layout.Elements, name)
+}
+
+func (layout *Layout) GetPath() string {
+	return layout.path
+}
+
+func (layout *Layout) Save() error {
+	if err := os.MkdirAll(filepath.Dir(layout.path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(layout, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(layout.path, data, 0644)
+}
+
+// alignPoint returns -1, 0 or 1 depending on which third of the screen the value is in
+func alignPoint(value, size float64) float64 {
+	switch {
+	case value < size/3:
+		return -1
+	case value > size*2/3:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func originName(origin vector.Vector2d) string {
+	names := map[vector.Vector2d]string{
+		vector.TopLeft:      "TopLeft",
+		vector.TopCentre:    "TopCentre",
+		vector.TopRight:     "TopRight",
+		vector.CentreLeft:   "CentreLeft",
+		vector.Centre:       "Centre",
+		vector.CentreRight:  "CentreRight",
+		vector.BottomLeft:   "BottomLeft",
+		vector.BottomCentre: "BottomCentre",
+		vector.BottomRight:  "BottomRight",
+	}
+
+	return names[origin]
+}
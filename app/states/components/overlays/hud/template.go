@@ -0,0 +1,107 @@
+package hud
+
+import (
+	"fmt"
+	"strings"
+)
+
+type segment struct {
+	text string
+
+	// Name of the variable, empty for plain text
+	variable string
+
+	// printf verb without %, e.g. ".2f"
+	format string
+}
+
+// Template is a text with variables in braces, e.g. "{title} [{version}] {acc:.2f}%".
+// Text after a colon is a printf verb without the percent sign. Braces are escaped by doubling them.
+type Template struct {
+	segments []segment
+}
+
+func ParseTemplate(text string) *Template {
+	template := new(Template)
+
+	var builder strings.Builder
+
+	flushText := func() {
+		if builder.Len() > 0 {
+			template.segments = append(template.segments, segment{text: builder.String()})
+			builder.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		if (c == '{' || c == '}') && i+1 < len(text) && text[i+1] == c {
+			builder.WriteByte(c)
+			i++
+
+			continue
+		}
+
+		if c == '{' {
+			if end := strings.IndexByte(text[i+1:], '}'); end >= 0 {
+				flushText()
+
+				name, format, _ := strings.Cut(text[i+1:i+1+end], ":")
+
+				template.segments = append(template.segments, segment{
+					text:     text[i : i+end+2],
+					variable: strings.TrimSpace(name),
+					format:   strings.TrimSpace(format),
+				})
+
+				i += end + 1
+
+				continue
+			}
+		}
+
+		builder.WriteByte(c)
+	}
+
+	flushText()
+
+	return template
+}
+
+// Execute fills the template with current values, unknown variables are left as they are
+func (template *Template) Execute(variables *Variables) string {
+	var builder strings.Builder
+
+	for _, s := range template.segments {
+		if s.variable == "" {
+			builder.WriteString(s.text)
+			continue
+		}
+
+		value, ok := variables.Get(s.variable)
+		if !ok {
+			builder.WriteString(s.text)
+			continue
+		}
+
+		builder.WriteString(formatValue(value, s.format))
+	}
+
+	return builder.String()
+}
+
+func formatValue(value interface{}, format string) string {
+	if format != "" {
+		return fmt.Sprintf("%"+format, value)
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return fmt.Sprintf("%.2f", v)
+	case float32:
+		return fmt.Sprintf("%.2f", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,49 @@
+package hud
+
+import (
+	"sort"
+	"sync"
+)
+
+// Variables is a registry of live values that can be used in text templates.
+// Values are set from the update thread and read while drawing, so access is synchronized.
+type Variables struct {
+	mutex  sync.RWMutex
+	values map[string]interface{}
+}
+
+func NewVariables() *Variables {
+	return &Variables{
+		values: make(map[string]interface{}),
+	}
+}
+
+func (variables *Variables) Set(name string, value interface{}) {
+	variables.mutex.Lock()
+	variables.values[name] = value
+	variables.mutex.Unlock()
+}
+
+func (variables *Variables) Get(name string) (interface{}, bool) {
+	variables.mutex.RLock()
+	defer variables.mutex.RUnlock()
+
+	value, ok := variables.values[name]
+
+	return value, ok
+}
+
+// Names returns sorted names of all registered variables
+func (variables *Variables) Names() []string {
+	variables.mutex.RLock()
+	defer variables.mutex.RUnlock()
+
+	names := make([]string, 0, len(variables.values))
+	for name := range variables.values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
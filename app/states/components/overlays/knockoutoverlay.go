@@ -22,6 +22,7 @@ import (
 	"github.com/wieku/danser-go/app/settings"
 	"github.com/wieku/danser-go/app/skin"
 	"github.com/wieku/danser-go/app/states/components/common"
+	"github.com/wieku/danser-go/app/states/components/overlays/elimination"
 	"github.com/wieku/danser-go/app/utils"
 	"github.com/wieku/danser-go/framework/assets"
 	"github.com/wieku/danser-go/framework/bass"
@@ -33,6 +34,7 @@ import (
 	color2 "github.com/wieku/danser-go/framework/math/color"
 	"github.com/wieku/danser-go/framework/math/mutils"
 	"github.com/wieku/danser-go/framework/math/vector"
+	"github.com/wieku/danser-go/framework/util"
 )
 
 type stats struct {
@@ -73,6 +75,11 @@ type knockoutPlayer struct {
 
 	// Custom
 	replayIndex int
+
+	team     *knockoutTeam
+	accuracy float64
+	topCombo int64
+	revives  int
 }
 
 type bubble struct {
@@ -88,8 +95,8 @@ type bubble struct {
 }
 
 func newBubble(position vector.Vector2d, time float64, name string, combo int64, lastHit osu.HitResult, lastCombo osu.ComboResult) *bubble {
-	deathShiftX := (rand.Float64() - 0.5) * 10
-	deathShiftY := (rand.Float64() - 0.5) * 10
+	deathShiftX := (util.RandomFloat64() - 0.5) * 10
+	deathShiftY := (util.RandomFloat64() - 0.5) * 10
 	baseY := position.Y + deathShiftY
 
 	bub := new(bubble)
@@ -142,8 +149,14 @@ type KnockoutOverlay struct {
 
 	music bass.ITrack
 
-	breakMode bool
-	fade      *animation.Glider
+	breakMode    bool
+	breakStarted bool
+	fade         *animation.Glider
+
+	rules *elimination.Engine
+
+	// Every knockout in order, players are kept here after they are revived
+	eliminations []Elimination
 
 	alivePlayers  int
 	playersIndex  int
@@ -152,6 +165,12 @@ type KnockoutOverlay struct {
 
 	playerTimelines      map[int64]int // used by resolved.json
 	playerTimelinesIndex int
+
+	teams []*knockoutTeam
+
+	beatmapEnd float64
+	podium     []*knockoutPlayer
+	podiumFade *animation.Glider
 }
 
 func NewKnockoutOverlay(replayController *dance.ReplayController) *KnockoutOverlay {
@@ -179,6 +198,11 @@ func NewKnockoutOverlay(replayController *dance.ReplayController) *KnockoutOverl
 
 	overlay.fade = animation.NewGlider(1)
 
+	overlay.beatmapEnd = math.Inf(1)
+	overlay.podiumFade = animation.NewGlider(0)
+
+	overlay.rules = elimination.NewEngine()
+
 	// player tag index
 	tag_file, _ := os.Open("/run/media/junko/2nd/Projects/Contributing/Tag/Ononoki/replays/tree/resolved.json")
 	tag_data, _ := io.ReadAll(tag_file)
@@ -222,7 +246,7 @@ func NewKnockoutOverlay(replayController *dance.ReplayController) *KnockoutOverl
 			overlay.alphas[cursor] = animation.NewGlider(0.0)
 		}
 
-		overlay.players[r.Name] = &knockoutPlayer{animation.NewGlider(1), animation.NewGlider(0), animation.NewGlider(overlay.ScaledHeight * 0.9 * 1.04 / (51)), animation.NewGlider(float64(i)), animation.NewTargetGlider(0, 0), animation.NewTargetGlider(0, 2), animation.NewTargetGlider(100, 2), 0, 0, r.MaxCombo, false, 0, 0.0, 0, make([]stats, len(replayController.GetBeatMap().HitObjects)), 0.0, osu.Hit300, animation.NewGlider(0), animation.NewGlider(0), r.Name, i, i, -1}
+		overlay.players[r.Name] = &knockoutPlayer{animation.NewGlider(1), animation.NewGlider(0), animation.NewGlider(overlay.ScaledHeight * 0.9 * 1.04 / (51)), animation.NewGlider(float64(i)), animation.NewTargetGlider(0, 0), animation.NewTargetGlider(0, 2), animation.NewTargetGlider(100, 2), 0, 0, r.MaxCombo, false, 0, 0.0, 0, make([]stats, len(replayController.GetBeatMap().HitObjects)), 0.0, osu.Hit300, animation.NewGlider(0), animation.NewGlider(0), r.Name, i, i, -1, nil, 100, 0, 0}
 		overlay.players[r.Name].index.SetEasing(easing.InOutQuad)
 		overlay.players[r.Name].replayIndex = i - 1
 		overlay.playersArray = append(overlay.playersArray, overlay.players[r.Name])
@@ -280,7 +304,7 @@ func NewKnockoutOverlay(replayController *dance.ReplayController) *KnockoutOverl
 			sort.SliceStable(overlay.playersArray, func(i, j int) bool {
 				mainCond := true
 				switch cond {
-				case "pp":
+				case "pp", "pp if fc":
 					mainCond = overlay.playersArray[i].perObjectStats[number].pp > overlay.playersArray[j].perObjectStats[number].pp
 				case "acc", "accuracy":
 					mainCond = overlay.playersArray[i].perObjectStats[number].accuracy > overlay.playersArray[j].perObjectStats[number].accuracy
@@ -516,6 +540,8 @@ func NewKnockoutOverlay(replayController *dance.ReplayController) *KnockoutOverl
 	overlay.Button = skin.GetTexture("knockout-button")
 	overlay.ButtonClicked = skin.GetTexture("knockout-button-active")
 
+	overlay.initTeams()
+
 	return overlay
 }
 
@@ -530,22 +556,30 @@ func (overlay *KnockoutOverlay) hitReceived(cursor *graphics.Cursor, time int64,
 		position.Y = 384 - position.Y
 	}
 
+	sc := overlay.controller.GetRuleset().GetScore(cursor)
+
 	player.score = score
-	player.pp = ppResults.Total
+
+	switch strings.ToLower(settings.Knockout.SortBy) {
+	case "pp if fc":
+		player.pp = sc.PPIfFC.Total
+	default:
+		player.pp = ppResults.Total
+	}
 
 	player.scoreDisp.SetValue(float64(score), false)
 	player.ppDisp.SetValue(player.pp, false)
 
-	sc := overlay.controller.GetRuleset().GetScore(cursor)
-
 	player.perObjectStats[number].score = score
-	player.perObjectStats[number].pp = ppResults.Total
+	player.perObjectStats[number].pp = player.pp
 	player.perObjectStats[number].accuracy = sc.Accuracy
 
 	player.accDisp.SetValue(sc.Accuracy, false)
+	player.accuracy = sc.Accuracy
 
 	if comboResult == osu.Increase {
 		player.sCombo++
+		player.topCombo = mutils.Max(player.topCombo, player.sCombo)
 	}
 
 	resultClean := result & osu.BaseHitsM
@@ -563,33 +597,13 @@ func (overlay *KnockoutOverlay) hitReceived(cursor *graphics.Cursor, time int64,
 	}
 
 	comboBreak := comboResult == osu.Reset
-	if (settings.Knockout.Mode == settings.SSOrQuit && (acceptableHits || comboBreak)) || (comboBreak && number != 0) {
-		if !player.hasBroken {
-			if settings.Knockout.Mode == settings.XReplays {
-				if player.sCombo >= int64(settings.Knockout.BubbleMinimumCombo) {
-					overlay.deathBubbles = append(overlay.deathBubbles, newBubble(position, overlay.normalTime, overlay.names[cursor], player.sCombo, resultClean, comboResult))
-					log.Println(overlay.names[cursor], "has broken! Combo:", player.sCombo)
-				}
-			} else if (settings.Knockout.Mode == settings.SSOrQuit ||
-				(settings.Knockout.Mode == settings.ComboBreak && time > int64(settings.Knockout.GraceEndTime*1000)) ||
-				(settings.Knockout.Mode == settings.MaxCombo && math.Abs(float64(player.sCombo-player.maxCombo)) < 5)) &&
-				overlay.alivePlayers > settings.Knockout.MinPlayers {
-				//Fade out player name
-				player.hasBroken = true
-				player.breakTime = time
-
-				overlay.alivePlayers--
-
-				player.fade.AddEvent(overlay.normalTime, overlay.normalTime+3000, 0)
-
-				player.height.SetEasing(easing.OutQuad)
-				player.height.AddEvent(overlay.normalTime+2500, overlay.normalTime+3000, 0)
-
-				overlay.deathBubbles = append(overlay.deathBubbles, newBubble(position, overlay.normalTime, overlay.names[cursor], player.sCombo, resultClean, comboResult))
-
-				log.Println(overlay.names[cursor], "has broken! Max combo:", player.sCombo)
-			}
+	if settings.Knockout.Mode == settings.XReplays {
+		if comboBreak && number != 0 && player.sCombo >= int64(settings.Knockout.BubbleMinimumCombo) {
+			overlay.deathBubbles = append(overlay.deathBubbles, newBubble(position, overlay.normalTime, overlay.names[cursor], player.sCombo, resultClean, comboResult))
+			log.Println(overlay.names[cursor], "has broken! Combo:", player.sCombo)
 		}
+	} else if overlay.rules.Hit(overlay.getPlayerState(player), elimination.Hit{Time: time, Number: number, NonPerfect: acceptableHits, ComboBreak: comboBreak}, overlay.alivePlayers) {
+		overlay.knockOut(player, position, time, resultClean, comboResult)
 	}
 
 	if comboBreak {
@@ -616,6 +630,8 @@ func (overlay *KnockoutOverlay) Update(time float64) {
 	overlay.updateBreaks(overlay.normalTime)
 	overlay.fade.Update(overlay.normalTime)
 
+	overlay.updateRules(int64(time))
+
 	for _, r := range overlay.controller.GetReplays() {
 		if r.Name == "AUTO_IGNORE" {
 			continue
@@ -645,6 +661,9 @@ func (overlay *KnockoutOverlay) Update(time float64) {
 		alpha.Update(overlay.normalTime)
 		cursor.AlphaHack = alpha.GetValue()
 	}
+
+	overlay.updateTeams()
+	overlay.updatePodium()
 }
 
 func (overlay *KnockoutOverlay) SetMusic(music bass.ITrack) {
@@ -927,6 +946,20 @@ func (overlay *KnockoutOverlay) DrawHUD(batch *batch.QuadBatch, colors []color2.
 			overlay.font.DrawOrigin(batch, 3.2*scl+width+nWidth+xSlideLeft, rowBaseY+ascScl, vector.BottomLeft, scl*0.8, false, "+"+r.Mods)
 		}
 	}
+
+	overlay.drawTeamHeader(batch, alpha)
+	overlay.drawPodium(batch, colors)
+}
+
+// Elimination is a player knocked out at given map time
+type Elimination struct {
+	Name string
+	Time int64
+}
+
+// GetEliminations returns knockouts so far, ordered by time. Revived players keep their earlier eliminations.
+func (overlay *KnockoutOverlay) GetEliminations() []Elimination {
+	return append([]Elimination(nil), overlay.eliminations...)
 }
 
 func (overlay *KnockoutOverlay) IsBroken(cursor *graphics.Cursor) bool {
@@ -952,6 +985,8 @@ func (overlay *KnockoutOverlay) updateBreaks(time float64) {
 		}
 	}
 
+	overlay.breakStarted = !overlay.breakMode && inBreak
+
 	if !overlay.breakMode && inBreak {
 		if settings.Knockout.HideOverlayOnBreaks {
 			overlay.fade.AddEventEase(time, time+500, 0, easing.OutQuad)
@@ -963,6 +998,95 @@ func (overlay *KnockoutOverlay) updateBreaks(time float64) {
 	overlay.breakMode = inBreak
 }
 
+// updateRules applies timed and break elimination rules and revives players
+func (overlay *KnockoutOverlay) updateRules(time int64) {
+	if !overlay.rules.HasRules() {
+		return
+	}
+
+	states := make([]elimination.Player, 0, len(overlay.playersArray))
+	for _, player := range overlay.playersArray {
+		states = append(states, overlay.getPlayerState(player))
+	}
+
+	replays := overlay.controller.GetReplays()
+
+	for _, index := range overlay.rules.Revive(time, overlay.breakStarted, states) {
+		overlay.revive(overlay.players[replays[index].Name])
+	}
+
+	eliminated := overlay.rules.Update(time, states)
+
+	if overlay.breakStarted {
+		eliminated = append(eliminated, overlay.rules.BreakStarted(time, states)...)
+	}
+
+	for _, index := range eliminated {
+		player := overlay.players[replays[index].Name]
+		if player.hasBroken {
+			continue
+		}
+
+		position := overlay.controller.GetCursors()[index].Position.Copy64()
+
+		overlay.knockOut(player, position, time, osu.Miss, osu.Reset)
+	}
+}
+
+func (overlay *KnockoutOverlay) getPlayerState(player *knockoutPlayer) elimination.Player {
+	cursor := overlay.controller.GetCursors()[player.oldIndex]
+	score := overlay.controller.GetRuleset().GetScore(cursor)
+
+	return elimination.Player{
+		Index:        player.oldIndex,
+		Score:        player.score,
+		Accuracy:     score.Accuracy,
+		Combo:        player.sCombo,
+		MaxCombo:     player.maxCombo,
+		Misses:       int64(score.CountMiss),
+		HP:           overlay.controller.GetRuleset().GetHP(cursor),
+		Eliminated:   player.hasBroken,
+		EliminatedAt: player.breakTime,
+		Revives:      player.revives,
+	}
+}
+
+func (overlay *KnockoutOverlay) knockOut(player *knockoutPlayer, position vector.Vector2d, time int64, lastHit osu.HitResult, lastCombo osu.ComboResult) {
+	//Fade out player name
+	player.hasBroken = true
+	player.breakTime = time
+
+	overlay.eliminations = append(overlay.eliminations, Elimination{Name: player.name, Time: time})
+
+	overlay.alivePlayers--
+
+	player.fade.AddEvent(overlay.normalTime, overlay.normalTime+3000, 0)
+
+	player.height.SetEasing(easing.OutQuad)
+	player.height.AddEvent(overlay.normalTime+2500, overlay.normalTime+3000, 0)
+
+	overlay.deathBubbles = append(overlay.deathBubbles, newBubble(position, overlay.normalTime, player.name, player.sCombo, lastHit, lastCombo))
+
+	log.Println(player.name, "has broken! Max combo:", player.sCombo)
+}
+
+func (overlay *KnockoutOverlay) revive(player *knockoutPlayer) {
+	player.hasBroken = false
+	player.breakTime = 0
+	player.revives++
+
+	overlay.alivePlayers++
+
+	player.fade.Reset()
+	player.fade.AddEvent(overlay.normalTime, overlay.normalTime+750, 1)
+
+	player.height.Reset()
+	player.height.SetEasing(easing.InQuad)
+	player.height.AddEvent(overlay.normalTime, overlay.normalTime+200, overlay.ScaledHeight*0.9*1.04/(51))
+
+	log.Println(player.name, "has been revived!")
+}
+
 func (overlay *KnockoutOverlay) DisableAudioSubmission(_ bool) {}
 
 func (overlay *KnockoutOverlay) ShouldDrawHUDBeforeCursor() bool {
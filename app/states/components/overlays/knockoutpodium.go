@@ -0,0 +1,131 @@
+package overlays
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/graphics"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/utils"
+	"github.com/wieku/danser-go/framework/graphics/batch"
+	color2 "github.com/wieku/danser-go/framework/math/color"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"sort"
+)
+
+// SetBeatmapEnd sets the time after which the podium is shown
+func (overlay *KnockoutOverlay) SetBeatmapEnd(end float64) {
+	overlay.beatmapEnd = end
+}
+
+// ShowsPodium returns true if the podium of top 3 players is shown after the map ends
+func (overlay *KnockoutOverlay) ShowsPodium() bool {
+	return settings.Gameplay.ShowResultsScreen && settings.Gameplay.ResultsScreenStyle == settings.StatisticsResults
+}
+
+// updatePodium picks top 3 players when the map ends. Players still alive are placed above the knocked out ones.
+func (overlay *KnockoutOverlay) updatePodium() {
+	overlay.podiumFade.Update(overlay.normalTime)
+
+	if overlay.podium != nil || !overlay.ShowsPodium() || overlay.audioTime < overlay.beatmapEnd {
+		return
+	}
+
+	ranking := make([]*knockoutPlayer, len(overlay.playersArray))
+	copy(ranking, overlay.playersArray)
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		if ranking[i].hasBroken != ranking[j].hasBroken {
+			return !ranking[i].hasBroken
+		}
+
+		return ranking[i].score > ranking[j].score
+	})
+
+	overlay.podium = ranking[:mutils.Min(3, len(ranking))]
+
+	s := overlay.normalTime
+
+	resultsTime := settings.Gameplay.ResultsScreenTime * 1000
+
+	overlay.podiumFade.AddEventS(s, s+500, 0, 1)
+
+	if !settings.PLAY {
+		overlay.podiumFade.AddEventS(s+resultsTime+500, s+resultsTime+1000, 1, 0)
+	}
+}
+
+// drawPodium draws top 3 players on podium steps, the winner in the middle
+func (overlay *KnockoutOverlay) drawPodium(batch *batch.QuadBatch, colors []color2.Color) {
+	alpha := overlay.podiumFade.GetValue()
+
+	if len(overlay.podium) == 0 || alpha < 0.001 {
+		return
+	}
+
+	batch.ResetTransform()
+
+	batch.SetColor(0, 0, 0, alpha*0.85)
+	batch.SetSubScale(overlay.ScaledWidth/2, overlay.ScaledHeight/2)
+	batch.SetTranslation(vector.NewVec2d(overlay.ScaledWidth/2, overlay.ScaledHeight/2))
+	batch.DrawUnit(graphics.Pixel.GetRegion())
+
+	scl := overlay.ScaledHeight / 30
+
+	bMap := overlay.controller.GetBeatMap()
+
+	batch.SetColor(1, 1, 1, alpha)
+	overlay.font.DrawOrigin(batch, overlay.ScaledWidth/2, scl*2, vector.TopCentre, scl*1.4, false, fmt.Sprintf("%s - %s [%s]", bMap.Artist, bMap.Name, bMap.Difficulty))
+
+	stepWidth := overlay.ScaledWidth * 0.18
+	bottom := overlay.ScaledHeight * 0.85
+
+	// Order of places from the left, with their step heights and colors
+	places := []int{1, 0, 2}
+	heights := []float64{0.3, 0.22, 0.16}
+	stepColors := []color2.Color{color2.NewIRGB(255, 214, 77), color2.NewIRGB(204, 204, 217), color2.NewIRGB(204, 128, 51)}
+
+	for i, place := range places {
+		if place >= len(overlay.podium) {
+			continue
+		}
+
+		player := overlay.podium[place]
+
+		height := overlay.ScaledHeight * heights[place]
+		centre := overlay.ScaledWidth/2 + float64(i-1)*(stepWidth+scl)
+
+		// Steps rise one after another, starting from the 3rd place
+		rise := mutils.ClampF(alpha*3-float64(2-place), 0, 1)
+		top := bottom - height*rise
+
+		sColor := stepColors[place]
+
+		batch.SetColor(float64(sColor.R), float64(sColor.G), float64(sColor.B), alpha*0.9)
+		batch.SetSubScale(stepWidth/2, height*rise/2)
+		batch.SetTranslation(vector.NewVec2d(centre, top+height*rise/2))
+		batch.DrawUnit(graphics.Pixel.GetRegion())
+
+		batch.SetColor(0, 0, 0, alpha*0.6)
+		overlay.font.DrawOrigin(batch, centre, top+scl*0.5, vector.TopCentre, scl*2.5, false, fmt.Sprintf("%d", place+1))
+
+		pColor := colors[player.oldIndex]
+
+		y := top - scl*4.6
+
+		batch.SetColor(float64(pColor.R), float64(pColor.G), float64(pColor.B), alpha)
+		overlay.font.DrawOrigin(batch, centre, y, vector.TopCentre, scl*1.2, false, player.name)
+
+		if player.team != nil {
+			batch.SetColor(float64(player.team.color.R), float64(player.team.color.G), float64(player.team.color.B), alpha)
+			overlay.font.DrawOrigin(batch, centre, y-scl*0.9, vector.TopCentre, scl*0.7, false, player.team.name)
+		}
+
+		batch.SetColor(1, 1, 1, alpha)
+		overlay.font.DrawOrigin(batch, centre, y+scl*1.5, vector.TopCentre, scl, true, utils.Humanize(player.score))
+
+		batch.SetColor(1, 1, 1, alpha*0.8)
+		overlay.font.DrawOrigin(batch, centre, y+scl*2.7, vector.TopCentre, scl*0.7, true, fmt.Sprintf("%.2f%% %dx %.0fpp", player.accuracy, player.topCombo, player.pp))
+	}
+
+	batch.ResetTransform()
+}
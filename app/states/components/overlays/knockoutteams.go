@@ -0,0 +1,266 @@
+package overlays
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/graphics"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/utils"
+	"github.com/wieku/danser-go/framework/graphics/batch"
+	"github.com/wieku/danser-go/framework/math/animation"
+	color2 "github.com/wieku/danser-go/framework/math/color"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+type knockoutTeam struct {
+	name  string
+	color color2.Color
+
+	players []*knockoutPlayer
+
+	score    int64
+	accuracy float64
+	pp       float64
+
+	scoreDisp *animation.TargetGlider
+	accDisp   *animation.TargetGlider
+	ppDisp    *animation.TargetGlider
+}
+
+// initTeams groups players by teams resolved by dance.ReplayController. Teams from settings come first, then the ones only given in knockout2 replay list.
+func (overlay *KnockoutOverlay) initTeams() {
+	if !settings.Knockout.Teams.Enabled {
+		return
+	}
+
+	teams := make(map[string]*knockoutTeam)
+
+	addTeam := func(name string) *knockoutTeam {
+		if team, ok := teams[name]; ok {
+			return team
+		}
+
+		team := &knockoutTeam{
+			name:      name,
+			scoreDisp: animation.NewTargetGlider(0, 0),
+			accDisp:   animation.NewTargetGlider(100, 2),
+			ppDisp:    animation.NewTargetGlider(0, 0),
+		}
+
+		if tSettings := settings.Knockout.Teams.GetTeam(name); tSettings != nil {
+			team.color = color2.NewHSV(float32(tSettings.Color.Hue), float32(tSettings.Color.Saturation), float32(tSettings.Color.Value))
+		} else {
+			team.color = color2.NewHSV(float32(len(teams))*137.5, 0.75, 1)
+		}
+
+		teams[name] = team
+		overlay.teams = append(overlay.teams, team)
+
+		return team
+	}
+
+	for _, t := range settings.Knockout.Teams.List {
+		addTeam(t.Name)
+	}
+
+	for _, r := range overlay.controller.GetReplays() {
+		if r.Name == "AUTO_IGNORE" || r.Team == "" {
+			continue
+		}
+
+		player := overlay.players[r.Name]
+		player.team = addTeam(r.Team)
+		player.team.players = append(player.team.players, player)
+	}
+
+	// Remove teams from settings that have no players on this map
+	for i := 0; i < len(overlay.teams); i++ {
+		if len(overlay.teams[i].players) == 0 {
+			overlay.teams = append(overlay.teams[:i], overlay.teams[i+1:]...)
+			i--
+		}
+	}
+
+	for _, team := range overlay.teams {
+		names := make([]string, 0, len(team.players))
+		for _, p := range team.players {
+			names = append(names, p.name)
+		}
+
+		log.Println(fmt.Sprintf("Team \"%s\":", team.name), strings.Join(names, ", "))
+	}
+}
+
+// updateTeams aggregates members' stats according to settings.Knockout.Teams.WinRule
+func (overlay *KnockoutOverlay) updateTeams() {
+	if len(overlay.teams) == 0 {
+		return
+	}
+
+	highestCombo := int64(1)
+
+	for _, player := range overlay.playersArray {
+		highestCombo = mutils.Max(highestCombo, player.topCombo)
+	}
+
+	for _, team := range overlay.teams {
+		scores := make([]float64, 0, len(team.players))
+
+		accuracy := 0.0
+		pp := 0.0
+
+		for _, player := range team.players {
+			score := float64(player.score)
+
+			if settings.Knockout.Teams.WinRule == settings.ComboBonus {
+				score *= 1 + settings.Knockout.Teams.ComboBonus*float64(player.topCombo)/float64(highestCombo)
+			}
+
+			scores = append(scores, score)
+
+			accuracy += player.accuracy
+			pp += player.pp
+		}
+
+		counted := len(scores)
+
+		if settings.Knockout.Teams.WinRule == settings.BestN {
+			sort.Sort(sort.Reverse(sort.Float64Slice(scores)))
+
+			counted = mutils.Min(counted, mutils.Max(settings.Knockout.Teams.BestN, 1))
+		}
+
+		sum := 0.0
+		for _, score := range scores[:counted] {
+			sum += score
+		}
+
+		team.score = int64(math.Round(sum))
+		team.accuracy = accuracy / float64(len(team.players))
+		team.pp = pp
+
+		team.scoreDisp.SetValue(float64(team.score), false)
+		team.accDisp.SetValue(team.accuracy, false)
+		team.ppDisp.SetValue(team.pp, false)
+
+		team.scoreDisp.Update(overlay.normalTime)
+		team.accDisp.Update(overlay.normalTime)
+		team.ppDisp.Update(overlay.normalTime)
+	}
+}
+
+// ApplyTeamColors replaces cursor colors of team members with their team's color
+func (overlay *KnockoutOverlay) ApplyTeamColors(colors []color2.Color) {
+	cursors := len(overlay.controller.GetCursors())
+
+	for _, player := range overlay.playersArray {
+		if player.team == nil {
+			continue
+		}
+
+		for i := player.oldIndex; i < len(colors); i += cursors {
+			colors[i] = color2.NewRGBA(player.team.color.R, player.team.color.G, player.team.color.B, colors[i].A)
+		}
+	}
+}
+
+// drawTeamHeader draws team scores at the top of the screen, similar to osu! tournament client
+func (overlay *KnockoutOverlay) drawTeamHeader(batch *batch.QuadBatch, alpha float64) {
+	if len(overlay.teams) == 0 || !settings.Knockout.Teams.ShowHeader {
+		return
+	}
+
+	scl := overlay.ScaledHeight / 30
+
+	width := overlay.ScaledWidth * 0.6
+	left := (overlay.ScaledWidth - width) / 2
+	height := scl * 2.2
+
+	slide := (overlay.fade.GetValue() - 1.0) * (height + scl*1.5)
+
+	boxWidth := width / float64(len(overlay.teams))
+
+	batch.ResetTransform()
+
+	leader := overlay.teams[0]
+
+	for i, team := range overlay.teams {
+		if team.score > leader.score {
+			leader = team
+		}
+
+		boxX := left + boxWidth*float64(i)
+
+		batch.SetColor(float64(team.color.R)*0.35, float64(team.color.G)*0.35, float64(team.color.B)*0.35, alpha*0.8)
+		batch.SetSubScale(boxWidth/2, height/2)
+		batch.SetTranslation(vector.NewVec2d(boxX+boxWidth/2, height/2+slide))
+		batch.DrawUnit(graphics.Pixel.GetRegion())
+
+		batch.SetColor(float64(team.color.R), float64(team.color.G), float64(team.color.B), alpha)
+		batch.SetSubScale(boxWidth/2, scl*0.08)
+		batch.SetTranslation(vector.NewVec2d(boxX+boxWidth/2, height-scl*0.08+slide))
+		batch.DrawUnit(graphics.Pixel.GetRegion())
+
+		// Teams on the left half are aligned to the left edge, the rest to the right
+		origin, textX := vector.TopLeft, boxX+scl*0.5
+		if float64(i) >= float64(len(overlay.teams))/2 {
+			origin, textX = vector.TopRight, boxX+boxWidth-scl*0.5
+		}
+
+		batch.SetColor(float64(team.color.R), float64(team.color.G), float64(team.color.B), alpha)
+		overlay.font.DrawOrigin(batch, textX, scl*0.15+slide, origin, scl*0.8, false, team.name)
+
+		batch.SetColor(1, 1, 1, alpha)
+		overlay.font.DrawOrigin(batch, textX, scl*0.95+slide, origin, scl, true, utils.Humanize(int64(team.scoreDisp.GetValue())))
+
+		stats := fmt.Sprintf("%.2f%% %.0fpp", team.accDisp.GetValue(), team.ppDisp.GetValue())
+		if origin == vector.TopLeft {
+			overlay.font.DrawOrigin(batch, boxX+boxWidth-scl*0.5, scl*0.15+slide, vector.TopRight, scl*0.6, true, stats)
+		} else {
+			overlay.font.DrawOrigin(batch, boxX+scl*0.5, scl*0.15+slide, vector.TopLeft, scl*0.6, true, stats)
+		}
+	}
+
+	if len(overlay.teams) == 2 {
+		overlay.drawTeamLead(batch, leader, width/2, height+scl*0.25+slide, scl, alpha)
+	}
+
+	batch.ResetTransform()
+}
+
+// drawTeamLead draws a bar growing from the middle towards the leading team like in osu! tournament client
+func (overlay *KnockoutOverlay) drawTeamLead(batch *batch.QuadBatch, leader *knockoutTeam, maxWidth, barY, scl, alpha float64) {
+	other := overlay.teams[0]
+	if other == leader {
+		other = overlay.teams[1]
+	}
+
+	lead := leader.scoreDisp.GetValue() - other.scoreDisp.GetValue()
+	if lead < 1 {
+		return
+	}
+
+	barWidth := maxWidth * math.Sqrt(math.Min(1, lead/math.Max(1, leader.scoreDisp.GetValue())))
+
+	direction := -1.0
+	if leader == overlay.teams[1] {
+		direction = 1.0
+	}
+
+	batch.SetColor(float64(leader.color.R), float64(leader.color.G), float64(leader.color.B), alpha)
+	batch.SetSubScale(barWidth/2, scl*0.15)
+	batch.SetTranslation(vector.NewVec2d(overlay.ScaledWidth/2+direction*barWidth/2, barY))
+	batch.DrawUnit(graphics.Pixel.GetRegion())
+
+	leadOrigin := vector.TopRight
+	if direction > 0 {
+		leadOrigin = vector.TopLeft
+	}
+
+	batch.SetColor(1, 1, 1, alpha)
+	overlay.font.DrawOrigin(batch, overlay.ScaledWidth/2+direction*(barWidth+scl*0.3), barY-scl*0.3, leadOrigin, scl*0.6, true, "+"+utils.Humanize(int64(lead)))
+}
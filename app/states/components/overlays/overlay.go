@@ -18,3 +18,12 @@ type Overlay interface {
 	DisableAudioSubmission(b bool)
 	ShouldDrawHUDBeforeCursor() bool
 }
+
+// Knockout is an overlay of multiple competing replays, implemented by KnockoutOverlay and overlays built on it
+type Knockout interface {
+	Overlay
+	SetBeatmapEnd(end float64)
+	ShowsPodium() bool
+	ApplyTeamColors(colors []color2.Color)
+	GetEliminations() []Elimination
+}
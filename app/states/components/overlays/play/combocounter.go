@@ -150,6 +150,17 @@ func (counter *ComboCounter) Update(time float64) {
 	counter.comboSlide.Update(time)
 }
 
+// GetBounds returns top-left and bottom-right corners of displayed combo, ignoring slide in breaks
+func (counter *ComboCounter) GetBounds() (vector.Vector2d, vector.Vector2d) {
+	scl := settings.Gameplay.ComboCounter.Scale * 1.28
+
+	size := vector.NewVec2d(counter.comboFont.GetWidth(counter.comboFont.GetSize(), fmt.Sprintf("%dx", counter.comboDisplay)), counter.comboFont.GetSize()).Scl(scl)
+
+	bottomLeft := vector.NewVec2d(settings.Gameplay.ComboCounter.XOffset+3.2, settings.Gameplay.ComboCounter.YOffset+counter.ScaledHeight-12.8)
+
+	return bottomLeft.SubS(0, size.Y), bottomLeft.AddS(size.X, 0)
+}
+
 func (counter *ComboCounter) SlideOut() {
 	counter.comboSlide.AddEventEase(counter.time, counter.time+1000, -130, easing.InQuad)
 	counter.mainCounter.AddTransform(animation.NewSingleTransform(animation.Fade, easing.Linear, counter.time, counter.time+1000, counter.mainCounter.GetAlpha(), 0.0))
@@ -132,6 +132,43 @@ func (sprite *HitDisplay) Draw(batch *batch.QuadBatch, alpha float64) {
 	batch.ResetTransform()
 }
 
+// GetBounds returns top-left and bottom-right corners of displayed counts
+func (sprite *HitDisplay) GetBounds() (vector.Vector2d, vector.Vector2d) {
+	hCS := settings.Gameplay.HitCounter
+
+	scale := hCS.Scale
+	hSpacing := hCS.Spacing * scale
+	vSpacing := 0.0
+
+	if hCS.Vertical {
+		vSpacing = hSpacing
+		hSpacing = 0
+	}
+
+	fontScale := scale * hCS.FontScale
+
+	align := vector.ParseOrigin(hCS.Align).AddS(1, 1).Scl(0.5)
+
+	bC := 3.0
+
+	if hCS.Show300 {
+		bC += 1.0
+	}
+
+	if hCS.ShowSliderBreaks {
+		bC += 1.0
+	}
+
+	valueAlign := vector.ParseOrigin(hCS.ValueAlign).AddS(1, 1).Scl(0.5)
+
+	first := vector.NewVec2d(hCS.XPosition-align.X*hSpacing*(bC-1), hCS.YPosition-align.Y*vSpacing*(bC-1))
+	last := first.AddS(hSpacing*(bC-1), vSpacing*(bC-1))
+
+	textSize := vector.NewVec2d(sprite.fnt.GetWidthMonospaced(20*fontScale, "0000"), 20*fontScale)
+
+	return first.Sub(valueAlign.Mult(textSize)), last.Add(vector.NewVec2d(1, 1).Sub(valueAlign).Mult(textSize))
+}
+
 func (sprite *HitDisplay) drawShadowed(batch *batch.QuadBatch, x, y float64, origin vector.Vector2d, size float64, color *settings.HSV, alpha float32, text string) {
 	rgba := color2.NewHSVA(float32(color.Hue), float32(color.Saturation), float32(color.Value), alpha)
 
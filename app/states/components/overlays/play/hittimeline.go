@@ -0,0 +1,160 @@
+package play
+
+import (
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/graphics"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/graphics/batch"
+	color2 "github.com/wieku/danser-go/framework/math/color"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"math"
+)
+
+type timelineHit struct {
+	time           float64
+	error          float64
+	positionalMiss bool
+}
+
+// HitTimeline plots every hit error over the whole map, below the playfield. Hits are placed by their time in map, errors grow downwards.
+type HitTimeline struct {
+	diff *difficulty.Difficulty
+
+	width  float64
+	height float64
+
+	startTime float64
+	endTime   float64
+
+	hits   []timelineHit
+	misses []float64
+
+	time float64
+}
+
+func NewHitTimeline(width, height float64, diff *difficulty.Difficulty, startTime, endTime float64) *HitTimeline {
+	return &HitTimeline{
+		diff:      diff,
+		width:     width,
+		height:    height,
+		startTime: startTime,
+		endTime:   math.Max(endTime, startTime+1),
+	}
+}
+
+func (timeline *HitTimeline) Add(time, error float64, positionalMiss bool) {
+	timeline.hits = append(timeline.hits, timelineHit{
+		time:           time,
+		error:          error,
+		positionalMiss: positionalMiss,
+	})
+}
+
+// AddMiss marks a miss of the object starting at given time
+func (timeline *HitTimeline) AddMiss(time float64) {
+	timeline.misses = append(timeline.misses, time)
+}
+
+// Update moves the playhead
+func (timeline *HitTimeline) Update(time float64) {
+	timeline.time = time
+}
+
+func (timeline *HitTimeline) Draw(batch *batch.QuadBatch, alpha float64) {
+	batch.ResetTransform()
+
+	timelineAlpha := settings.Gameplay.HitErrorTimeline.Opacity * alpha
+
+	if timelineAlpha < 0.001 || !settings.Gameplay.HitErrorTimeline.Show {
+		return
+	}
+
+	batch.SetColor(1, 1, 1, timelineAlpha)
+
+	topLeft, bottomRight := timeline.GetBounds()
+
+	width := bottomRight.X - topLeft.X
+	height := bottomRight.Y - topLeft.Y
+	centre := topLeft.Y + height/2
+
+	pixel := graphics.Pixel.GetRegion()
+
+	drawRect := func(x, y, w, h float64, color color2.Color) {
+		batch.DrawStObject(vector.NewVec2d(x, y), vector.TopLeft, vector.NewVec2d(w, h), false, false, 0, color, false, pixel)
+	}
+
+	drawRect(topLeft.X, topLeft.Y, width, height, color2.NewLA(0, 0.6))
+
+	// Hit windows from the widest one, so narrower ones are drawn over it
+	windows := []float64{float64(timeline.diff.Hit50), float64(timeline.diff.Hit100), float64(timeline.diff.Hit300)}
+
+	for i, w := range windows {
+		wHeight := w / windows[0] * height / 2
+
+		color := colors[2-i]
+		color.A = 0.15
+
+		drawRect(topLeft.X, centre-wHeight, width, wHeight*2, color)
+	}
+
+	drawRect(topLeft.X, centre-0.5, width, 1, color2.NewLA(1, 0.4))
+
+	scale := settings.Gameplay.HitErrorTimeline.Scale
+
+	missColor := colors[3]
+	missColor.A = 0.8
+
+	for _, m := range timeline.misses {
+		drawRect(timeline.timeToX(m, topLeft.X, width)-scale, topLeft.Y, 2*scale, height, missColor)
+	}
+
+	for _, h := range timeline.hits {
+		errorA := int64(math.Abs(h.error))
+
+		color := colors[3]
+
+		if !h.positionalMiss {
+			switch {
+			case errorA < timeline.diff.Hit300:
+				color = colors[0]
+			case errorA < timeline.diff.Hit100:
+				color = colors[1]
+			case errorA < timeline.diff.Hit50:
+				color = colors[2]
+			}
+		}
+
+		pY := centre + mutils.ClampF(h.error/windows[0], -1, 1)*height/2
+
+		batch.DrawStObject(vector.NewVec2d(timeline.timeToX(h.time, topLeft.X, width), pY), vector.Centre, vector.NewVec2d(3, 3).Scl(scale), false, false, 0, color, false, pixel)
+	}
+
+	drawRect(timeline.timeToX(timeline.time, topLeft.X, width)-scale, topLeft.Y, 2*scale, height, color2.NewL(1))
+
+	batch.ResetTransform()
+}
+
+// GetTimeAt returns time in map under the given point, false if the point is outside the timeline
+func (timeline *HitTimeline) GetTimeAt(point vector.Vector2d) (float64, bool) {
+	topLeft, bottomRight := timeline.GetBounds()
+
+	if point.X < topLeft.X || point.X > bottomRight.X || point.Y < topLeft.Y || point.Y > bottomRight.Y {
+		return 0, false
+	}
+
+	return timeline.startTime + (point.X-topLeft.X)/(bottomRight.X-topLeft.X)*(timeline.endTime-timeline.startTime), true
+}
+
+// GetBounds returns top-left and bottom-right corners of the timeline, it spans the whole width of the screen
+func (timeline *HitTimeline) GetBounds() (vector.Vector2d, vector.Vector2d) {
+	height := settings.Gameplay.HitErrorTimeline.Height * settings.Gameplay.HitErrorTimeline.Scale
+
+	bottomRight := vector.NewVec2d(timeline.width, timeline.height).AddS(settings.Gameplay.HitErrorTimeline.XOffset, settings.Gameplay.HitErrorTimeline.YOffset)
+
+	return vector.NewVec2d(bottomRight.X-timeline.width, bottomRight.Y-height), bottomRight
+}
+
+func (timeline *HitTimeline) timeToX(time, left, width float64) float64 {
+	return left + width*mutils.ClampF((time-timeline.startTime)/(timeline.endTime-timeline.startTime), 0, 1)
+}
@@ -151,6 +151,19 @@ func (hpBar *HpBar) Draw(batch *batch.QuadBatch, alpha float64) {
 	hpBar.explodes.Draw(hpBar.lastTime, batch)
 }
 
+// GetBounds returns top-left and bottom-right corners of the bar's background, ignoring slide in breaks
+func (hpBar *HpBar) GetBounds() (vector.Vector2d, vector.Vector2d) {
+	pos := vector.NewVec2d(settings.Gameplay.HpBar.XOffset, settings.Gameplay.HpBar.YOffset)
+
+	if hpBar.healthBackground.Texture == nil {
+		return pos, pos
+	}
+
+	size := vector.NewVec2d(float64(hpBar.healthBackground.Texture.Width), float64(hpBar.healthBackground.Texture.Height))
+
+	return pos, pos.Add(size.Scl(settings.Gameplay.HpBar.Scale))
+}
+
 func (hpBar *HpBar) SlideOut() {
 	if settings.Gameplay.HpBar.YOffset < 0.01 {
 		hpBar.hpSlide.AddEvent(hpBar.lastTime, hpBar.lastTime+500, -20)
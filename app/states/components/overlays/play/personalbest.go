@@ -0,0 +1,115 @@
+package play
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/utils"
+	"github.com/wieku/danser-go/framework/graphics/batch"
+	"github.com/wieku/danser-go/framework/graphics/font"
+	color2 "github.com/wieku/danser-go/framework/math/color"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"math"
+)
+
+const pbLineSize = 30.0
+
+// PersonalBestDisplay shows how current play compares to the best local score on the map
+type PersonalBestDisplay struct {
+	font *font.Font
+
+	bestScore    int64
+	bestAccuracy float64
+
+	bestText  string
+	scoreText string
+	accText   string
+
+	scoreDelta int64
+	accDelta   float64
+}
+
+func NewPersonalBestDisplay(bestScore int64, bestAccuracy float64) *PersonalBestDisplay {
+	display := &PersonalBestDisplay{
+		font:         font.GetFont("HUDFont"),
+		bestScore:    bestScore,
+		bestAccuracy: bestAccuracy,
+		bestText:     fmt.Sprintf("PB: %s (%.2f%%)", utils.Humanize(bestScore), bestAccuracy),
+	}
+
+	display.Add(0, 100)
+
+	return display
+}
+
+// Add updates the difference to personal best. Score difference shows how many points are left to beat it.
+func (display *PersonalBestDisplay) Add(score int64, accuracy float64) {
+	display.scoreDelta = score - display.bestScore
+	display.accDelta = accuracy - display.bestAccuracy
+
+	sign := "+"
+	if display.scoreDelta < 0 {
+		sign = "-"
+	}
+
+	display.scoreText = sign + utils.Humanize(int64(math.Abs(float64(display.scoreDelta))))
+	display.accText = fmt.Sprintf("%+.2f%%", display.accDelta)
+}
+
+func (display *PersonalBestDisplay) Draw(batch *batch.QuadBatch, alpha float64) {
+	batch.ResetTransform()
+
+	pbAlpha := settings.Gameplay.PersonalBest.Opacity * alpha
+
+	if pbAlpha < 0.001 || !settings.Gameplay.PersonalBest.Show {
+		return
+	}
+
+	scale := settings.Gameplay.PersonalBest.Scale
+	size := pbLineSize * scale
+
+	topLeft, bottomRight := display.GetBounds()
+
+	// Lines are aligned horizontally the same way as the whole block
+	origin := vector.ParseOrigin(settings.Gameplay.PersonalBest.Align)
+	x := topLeft.X + (bottomRight.X-topLeft.X)*(origin.X+1)/2
+	lineOrigin := vector.NewVec2d(origin.X, -1)
+
+	display.drawLine(batch, x, topLeft.Y, lineOrigin, size, color2.NewLA(1, float32(pbAlpha*0.8)), display.bestText)
+
+	deltaText := display.scoreText + " / " + display.accText
+
+	color := color2.NewRGBA(0.4, 1, 0.4, float32(pbAlpha))
+	if display.scoreDelta < 0 {
+		color = color2.NewRGBA(1, 0.4, 0.4, float32(pbAlpha))
+	}
+
+	display.drawLine(batch, x, topLeft.Y+size, lineOrigin, size, color, deltaText)
+
+	batch.ResetTransform()
+}
+
+func (display *PersonalBestDisplay) drawLine(batch *batch.QuadBatch, x, y float64, origin vector.Vector2d, size float64, color color2.Color, text string) {
+	scale := settings.Gameplay.PersonalBest.Scale
+
+	batch.SetColor(0, 0, 0, float64(color.A)*0.8)
+	display.font.DrawOrigin(batch, x+scale, y+scale, origin, size, true, text)
+
+	batch.SetColorM(color)
+	display.font.DrawOrigin(batch, x, y, origin, size, true, text)
+}
+
+// GetBounds returns top-left and bottom-right corners of displayed values
+func (display *PersonalBestDisplay) GetBounds() (vector.Vector2d, vector.Vector2d) {
+	scale := settings.Gameplay.PersonalBest.Scale
+
+	position := vector.NewVec2d(settings.Gameplay.PersonalBest.XPosition, settings.Gameplay.PersonalBest.YPosition)
+	origin := vector.ParseOrigin(settings.Gameplay.PersonalBest.Align).AddS(1, 1).Scl(0.5)
+
+	width := math.Max(display.font.GetWidthMonospaced(pbLineSize*scale, display.bestText), display.font.GetWidthMonospaced(pbLineSize*scale, display.scoreText+" / "+display.accText))
+
+	size := vector.NewVec2d(width, 2*pbLineSize*scale)
+
+	topLeft := position.Sub(origin.Mult(size))
+
+	return topLeft, topLeft.Add(size)
+}
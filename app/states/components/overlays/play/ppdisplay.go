@@ -31,6 +31,9 @@ type PPDisplay struct {
 	ppGlider *animation.TargetGlider
 	ppText   string
 
+	ifFCGlider *animation.TargetGlider
+	ifFCText   string
+
 	mText string
 
 	decimals int
@@ -48,10 +51,12 @@ func NewPPDisplay(mods difficulty.Modifier, experimentalPP bool) *PPDisplay {
 		accGlider:        animation.NewTargetGlider(0, 0),
 		flashlightGlider: animation.NewTargetGlider(0, 0),
 		ppGlider:         animation.NewTargetGlider(0, 0),
+		ifFCGlider:       animation.NewTargetGlider(0, 0),
 		aimText:          "0pp",
 		tapText:          "0pp",
 		accText:          "0pp",
 		ppText:           "0pp",
+		ifFCText:         "0pp",
 		mText:            "0pp",
 		decimals:         0,
 		format:           "%.0fpp",
@@ -60,7 +65,8 @@ func NewPPDisplay(mods difficulty.Modifier, experimentalPP bool) *PPDisplay {
 	}
 }
 
-func (ppDisplay *PPDisplay) Add(results pp220930.PPv2Results) {
+// Add updates displayed values. ifFC is pp if the rest of the map is FC'd with current accuracy.
+func (ppDisplay *PPDisplay) Add(results pp220930.PPv2Results, ifFC float64) {
 	static := settings.Gameplay.PPCounter.Static
 
 	ppDisplay.aimGlider.SetValue(results.Aim, static)
@@ -68,6 +74,7 @@ func (ppDisplay *PPDisplay) Add(results pp220930.PPv2Results) {
 	ppDisplay.accGlider.SetValue(results.Acc, static)
 	ppDisplay.flashlightGlider.SetValue(results.Flashlight, static)
 	ppDisplay.ppGlider.SetValue(results.Total, static)
+	ppDisplay.ifFCGlider.SetValue(ifFC, static)
 }
 
 func (ppDisplay *PPDisplay) Update(time float64) {
@@ -87,6 +94,10 @@ func (ppDisplay *PPDisplay) Update(time float64) {
 		ppDisplay.updatePP(ppDisplay.flashlightGlider, &ppDisplay.flashlightText, time, &mText)
 	}
 
+	if settings.Gameplay.PPCounter.ShowPPIfFC {
+		ppDisplay.updatePP(ppDisplay.ifFCGlider, &ppDisplay.ifFCText, time, &mText)
+	}
+
 	ppDisplay.mText = mText
 }
 
@@ -118,25 +129,51 @@ func (ppDisplay *PPDisplay) Draw(batch *batch.QuadBatch, alpha float64) {
 	cS := settings.Gameplay.PPCounter.Color
 	color := color2.NewHSVA(float32(cS.Hue), float32(cS.Saturation), float32(cS.Value), float32(ppAlpha))
 
-	if settings.Gameplay.PPCounter.ShowPPComponents {
+	extraLines := 0.0
+
+	if settings.Gameplay.PPCounter.ShowPPIfFC {
+		extraLines++
+	}
+
+	if settings.Gameplay.PPCounter.ShowPPComponents || extraLines > 0 {
 		length := ppDisplay.ppFont.GetWidthMonospaced(40*ppScale, "Total: ")
 		pLength := ppDisplay.ppFont.GetWidthMonospaced(40*ppScale, ppDisplay.mText)
 
-		position = position.Add(origin.AddS(1, 1).Mult(vector.NewVec2d(-(length+pLength)/2, -(160*ppScale)/2)))
+		lines := 1 + extraLines
+
+		if settings.Gameplay.PPCounter.ShowPPComponents {
+			lines += 3
 
-		ppDisplay.drawPP(batch, "Aim:", ppDisplay.aimText, position, length, ppScale, color, vector.TopLeft)
-		ppDisplay.drawPP(batch, "Tap:", ppDisplay.tapText, position.AddS(0, 40*ppScale), length, ppScale, color, vector.TopLeft)
-		ppDisplay.drawPP(batch, "Acc:", ppDisplay.accText, position.AddS(0, 80*ppScale), length, ppScale, color, vector.TopLeft)
+			if ppDisplay.mods.Active(difficulty.Flashlight) {
+				lines++
+			}
+		}
+
+		position = position.Add(origin.AddS(1, 1).Mult(vector.NewVec2d(-(length+pLength)/2, -(lines*40*ppScale)/2)))
 
 		offset := 0.0
 
-		if ppDisplay.mods.Active(difficulty.Flashlight) {
-			ppDisplay.drawPP(batch, "FL:", ppDisplay.flashlightText, position.AddS(0, 120*ppScale), length, ppScale, color, vector.TopLeft)
+		if settings.Gameplay.PPCounter.ShowPPComponents {
+			ppDisplay.drawPP(batch, "Aim:", ppDisplay.aimText, position, length, ppScale, color, vector.TopLeft)
+			ppDisplay.drawPP(batch, "Tap:", ppDisplay.tapText, position.AddS(0, 40*ppScale), length, ppScale, color, vector.TopLeft)
+			ppDisplay.drawPP(batch, "Acc:", ppDisplay.accText, position.AddS(0, 80*ppScale), length, ppScale, color, vector.TopLeft)
+
+			offset = 120
+
+			if ppDisplay.mods.Active(difficulty.Flashlight) {
+				ppDisplay.drawPP(batch, "FL:", ppDisplay.flashlightText, position.AddS(0, offset*ppScale), length, ppScale, color, vector.TopLeft)
 
-			offset = 40
+				offset += 40
+			}
 		}
 
-		ppDisplay.drawPP(batch, "Total:", ppDisplay.ppText, position.AddS(0, (120+offset)*ppScale), length, ppScale, color, vector.TopLeft)
+		ppDisplay.drawPP(batch, "Total:", ppDisplay.ppText, position.AddS(0, offset*ppScale), length, ppScale, color, vector.TopLeft)
+
+		if settings.Gameplay.PPCounter.ShowPPIfFC {
+			offset += 40
+
+			ppDisplay.drawPP(batch, "FC:", ppDisplay.ifFCText, position.AddS(0, offset*ppScale), length, ppScale, color, vector.TopLeft)
+		}
 	} else {
 		ppDisplay.drawPP(batch, "", ppDisplay.ppText, position, 0, ppScale, color, origin)
 	}
@@ -144,6 +181,40 @@ func (ppDisplay *PPDisplay) Draw(batch *batch.QuadBatch, alpha float64) {
 	batch.ResetTransform()
 }
 
+// GetBounds returns top-left and bottom-right corners of displayed values
+func (ppDisplay *PPDisplay) GetBounds() (vector.Vector2d, vector.Vector2d) {
+	ppScale := settings.Gameplay.PPCounter.Scale
+
+	position := vector.NewVec2d(settings.Gameplay.PPCounter.XPosition, settings.Gameplay.PPCounter.YPosition)
+	origin := vector.ParseOrigin(settings.Gameplay.PPCounter.Align).AddS(1, 1).Scl(0.5)
+
+	var size vector.Vector2d
+
+	if settings.Gameplay.PPCounter.ShowPPComponents || settings.Gameplay.PPCounter.ShowPPIfFC {
+		lines := 1.0
+
+		if settings.Gameplay.PPCounter.ShowPPIfFC {
+			lines++
+		}
+
+		if settings.Gameplay.PPCounter.ShowPPComponents {
+			lines += 3
+
+			if ppDisplay.mods.Active(difficulty.Flashlight) {
+				lines++
+			}
+		}
+
+		size = vector.NewVec2d(ppDisplay.ppFont.GetWidthMonospaced(40*ppScale, "Total: "+ppDisplay.mText), lines*40*ppScale)
+	} else {
+		size = vector.NewVec2d(ppDisplay.ppFont.GetWidthMonospaced(40*ppScale, ppDisplay.ppText), 40*ppScale)
+	}
+
+	topLeft := position.Sub(origin.Mult(size))
+
+	return topLeft, topLeft.Add(size)
+}
+
 func (ppDisplay *PPDisplay) drawPP(batch *batch.QuadBatch, title, ppXText string, position vector.Vector2d, length float64, ppScale float64, color color2.Color, origin vector.Vector2d) {
 	if title != "" {
 		batch.SetColor(0, 0, 0, float64(color.A)*0.8)
@@ -5,6 +5,7 @@ import (
 	"github.com/faiface/mainthread"
 	"github.com/wieku/danser-go/app/graphics"
 	"github.com/wieku/danser-go/app/rulesets/osu"
+	"github.com/wieku/danser-go/app/rulesets/osu/statistics"
 	"github.com/wieku/danser-go/app/settings"
 	"github.com/wieku/danser-go/app/skin"
 	"github.com/wieku/danser-go/framework/assets"
@@ -15,9 +16,11 @@ import (
 	"github.com/wieku/danser-go/framework/graphics/texture"
 	"github.com/wieku/danser-go/framework/math/color"
 	"github.com/wieku/danser-go/framework/math/math32"
+	"github.com/wieku/danser-go/framework/math/mutils"
 	"github.com/wieku/danser-go/framework/math/scaling"
 	"github.com/wieku/danser-go/framework/math/vector"
 	"log"
+	"math"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -34,6 +37,12 @@ const (
 	row4  = 320 / 0.625
 )
 
+// ResultsPanel is a screen shown after the map ends
+type ResultsPanel interface {
+	Update(time float64)
+	Draw(batch *batch.QuadBatch, alpha float64)
+}
+
 type RankingPanel struct {
 	manager *sprite.Manager
 	time    float64
@@ -61,9 +70,12 @@ type RankingPanel struct {
 	hpGraph        []vector.Vector2d
 	stats          []string
 	perfect        *sprite.Sprite
+
+	histogram statistics.Histogram
+	sections  []statistics.Section
 }
 
-func NewRankingPanel(cursor *graphics.Cursor, ruleset *osu.OsuRuleSet, hitError *HitErrorMeter, hpGraph []vector.Vector2d) *RankingPanel {
+func NewRankingPanel(cursor *graphics.Cursor, ruleset *osu.OsuRuleSet, hitError *HitErrorMeter, hitStatistics *statistics.HitStatistics, hpGraph []vector.Vector2d) *RankingPanel {
 	panel := &RankingPanel{
 		manager:     sprite.NewManager(),
 		ScaledWidth: settings.Graphics.GetAspectRatio() * 768,
@@ -71,37 +83,7 @@ func NewRankingPanel(cursor *graphics.Cursor, ruleset *osu.OsuRuleSet, hitError
 		ruleset:     ruleset,
 	}
 
-	bg := sprite.NewSpriteSingle(nil, -1, vector.NewVec2d(panel.ScaledWidth, 768).Scl(0.5), vector.Centre)
-	bg.SetColor(color.NewL(0.75))
-
-	bgLoadFunc := func() {
-		image, err := texture.NewPixmapFileString(filepath.Join(settings.General.GetSongsDir(), ruleset.GetBeatMap().Dir, ruleset.GetBeatMap().Bg))
-		if err != nil {
-			image, err = assets.GetPixmap("assets/textures/background-1.png")
-			if err != nil {
-				panic(err)
-			}
-		}
-
-		if image != nil {
-			mainthread.CallNonBlock(func() {
-				region := texture.LoadTextureSingle(image.RGBA(), 0).GetRegion()
-				bg.Texture = &region
-
-				result := scaling.Fill.Apply(region.Width, region.Height, float32(panel.ScaledWidth), 768)
-
-				bg.SetScaleV(result.Mult(vector.NewVec2f(1/region.Width, 1/region.Height)).Copy64())
-
-				image.Dispose()
-			})
-		}
-	}
-
-	if settings.RECORD {
-		bgLoadFunc()
-	} else {
-		go bgLoadFunc()
-	}
+	bg := newResultsBackground(ruleset, panel.ScaledWidth)
 
 	panel.loadMods()
 
@@ -220,11 +202,66 @@ func NewRankingPanel(cursor *graphics.Cursor, ruleset *osu.OsuRuleSet, hitError
 		stats += fmt.Sprintf("\n                             (%.2f)", hitError.GetUnstableRateConverted())
 	}
 
+	if settings.Gameplay.HitStatistics.ShowInResults {
+		patterns := []statistics.Pattern{statistics.Stream, statistics.Jump}
+		names := []string{"Stream", "Jump"}
+
+		for i, p := range patterns {
+			breakdown := hitStatistics.GetBreakdown(func(e statistics.HitError) bool {
+				return e.Pattern == p
+			})
+
+			if breakdown.Hits > 0 {
+				stats += fmt.Sprintf("\n%s UR: %.2f (%.2fms avg)", names[i], breakdown.UnstableRate, breakdown.Mean)
+			}
+		}
+
+		panel.histogram = hitStatistics.GetHistogram(settings.Gameplay.HitStatistics.HistogramBinSize)
+		panel.sections = hitStatistics.GetSections()
+	}
+
 	panel.stats = strings.Split(stats, "\n")
 
 	return panel
 }
 
+// newResultsBackground creates a sprite with map's background filling the screen, the texture is loaded asynchronously when not recording
+func newResultsBackground(ruleset *osu.OsuRuleSet, width float64) *sprite.Sprite {
+	bg := sprite.NewSpriteSingle(nil, -1, vector.NewVec2d(width, 768).Scl(0.5), vector.Centre)
+	bg.SetColor(color.NewL(0.75))
+
+	bgLoadFunc := func() {
+		image, err := texture.NewPixmapFileString(filepath.Join(settings.General.GetSongsDir(), ruleset.GetBeatMap().Dir, ruleset.GetBeatMap().Bg))
+		if err != nil {
+			image, err = assets.GetPixmap("assets/textures/background-1.png")
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		if image != nil {
+			mainthread.CallNonBlock(func() {
+				region := texture.LoadTextureSingle(image.RGBA(), 0).GetRegion()
+				bg.Texture = &region
+
+				result := scaling.Fill.Apply(region.Width, region.Height, float32(width), 768)
+
+				bg.SetScaleV(result.Mult(vector.NewVec2f(1/region.Width, 1/region.Height)).Copy64())
+
+				image.Dispose()
+			})
+		}
+	}
+
+	if settings.RECORD {
+		bgLoadFunc()
+	} else {
+		go bgLoadFunc()
+	}
+
+	return bg
+}
+
 func (panel *RankingPanel) loadMods() {
 	mods := panel.ruleset.GetBeatMap().Diff.GetModStringFull()
 
@@ -374,4 +411,109 @@ func (panel *RankingPanel) Draw(batch *batch.QuadBatch, alpha float64) {
 	for i, s := range panel.stats {
 		fnt2.DrawOrigin(batch, float64(sX)+5, float64(sY)+float64(i)*12+6, vector.TopLeft, 12, false, s)
 	}
+
+	if settings.Gameplay.HitStatistics.ShowInResults {
+		panel.drawStatistics(batch, sX, sY-10, alpha)
+	}
+}
+
+// drawStatistics draws hit error histogram and per-section unstable rate above the stats box, bottom is the bottom edge of the graphs
+func (panel *RankingPanel) drawStatistics(batch *batch.QuadBatch, x, bottom float32, alpha float64) {
+	if len(panel.histogram.Bins) == 0 {
+		return
+	}
+
+	const (
+		gWidth  = 260
+		gHeight = 60
+		spacing = 16
+	)
+
+	batch.Flush()
+
+	panel.shapeRenderer.Begin()
+
+	sectionsY := bottom - gHeight
+	histogramY := sectionsY - spacing - gHeight
+
+	panel.shapeRenderer.SetColor(0, 0, 0, alpha*0.8)
+	panel.shapeRenderer.DrawQuad(x, histogramY, x+gWidth, histogramY, x+gWidth, histogramY+gHeight, x, histogramY+gHeight)
+	panel.shapeRenderer.DrawQuad(x, sectionsY, x+gWidth, sectionsY, x+gWidth, sectionsY+gHeight, x, sectionsY+gHeight)
+
+	maxError := drawHistogram(panel.shapeRenderer, panel.histogram, x, histogramY, gWidth, gHeight, alpha)
+
+	// Unstable rate of every section, with early/late bias marked as a line
+	maxUR := 1.0
+	maxMean := 1.0
+
+	for _, sec := range panel.sections {
+		maxUR = math.Max(maxUR, sec.UnstableRate)
+		maxMean = math.Max(maxMean, math.Abs(sec.Mean))
+	}
+
+	secWidth := float32(gWidth-10) / float32(len(panel.sections))
+
+	for i, sec := range panel.sections {
+		if sec.Hits == 0 {
+			continue
+		}
+
+		sX := x + 5 + float32(i)*secWidth
+		sH := float32(sec.UnstableRate/maxUR) * (gHeight - 10)
+
+		panel.shapeRenderer.SetColor(0.44, 0.98, 0.18, alpha*0.8)
+		panel.shapeRenderer.DrawQuad(sX+1, sectionsY+gHeight-5-sH, sX+secWidth-1, sectionsY+gHeight-5-sH, sX+secWidth-1, sectionsY+gHeight-5, sX+1, sectionsY+gHeight-5)
+
+		mY := sectionsY + gHeight/2 - float32(sec.Mean/maxMean)*(gHeight/2-5)
+
+		panel.shapeRenderer.SetColor(1, 1, 1, alpha)
+		panel.shapeRenderer.DrawLine(sX+1, mY, sX+secWidth-1, mY, 2)
+	}
+
+	panel.shapeRenderer.End()
+
+	fnt := font.GetFont("Ubuntu Regular")
+
+	batch.SetColor(1, 1, 1, alpha)
+	fnt.DrawOrigin(batch, float64(x)+3, float64(histogramY)+2, vector.TopLeft, 10, false, "Hit errors")
+	fnt.DrawOrigin(batch, float64(x)+3, float64(sectionsY)+2, vector.TopLeft, 10, false, "UR per section")
+	fnt.DrawOrigin(batch, float64(x+gWidth)-3, float64(histogramY)+2, vector.TopRight, 10, false, fmt.Sprintf("±%.0fms", maxError))
+	fnt.DrawOrigin(batch, float64(x+gWidth)-3, float64(sectionsY)+2, vector.TopRight, 10, false, fmt.Sprintf("max %.0f", maxUR))
+}
+
+// drawHistogram draws bars of hit error histogram centred at 0ms, early hits are blue and late ones orange. Returns the largest error shown at the edges.
+func drawHistogram(renderer *shape.Renderer, histogram statistics.Histogram, x, y, width, height float32, alpha float64) float64 {
+	maxCount := 1
+	maxError := math.Max(math.Abs(histogram.Min), math.Abs(histogram.Min+float64(len(histogram.Bins))*histogram.BinSize))
+
+	for _, c := range histogram.Bins {
+		maxCount = mutils.Max(maxCount, c)
+	}
+
+	pxPerMs := float32(float64(width-10) / (2 * maxError))
+	binWidth := math32.Max(float32(histogram.BinSize)*pxPerMs, 1)
+
+	for i, c := range histogram.Bins {
+		if c == 0 {
+			continue
+		}
+
+		bStart := histogram.Min + float64(i)*histogram.BinSize
+
+		if bStart+histogram.BinSize <= 0 {
+			renderer.SetColor(0.2, 0.8, 1, alpha)
+		} else {
+			renderer.SetColor(1, 0.6, 0.2, alpha)
+		}
+
+		bX := x + width/2 + float32(bStart)*pxPerMs
+		bH := float32(c) / float32(maxCount) * (height - 10)
+
+		renderer.DrawQuad(bX, y+height-5-bH, bX+binWidth, y+height-5-bH, bX+binWidth, y+height-5, bX, y+height-5)
+	}
+
+	renderer.SetColor(1, 1, 1, alpha)
+	renderer.DrawLine(x+width/2, y+2, x+width/2, y+height-2, 1)
+
+	return maxError
 }
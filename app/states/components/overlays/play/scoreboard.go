@@ -1,12 +1,10 @@
 package play
 
 import (
-	"fmt"
-	"github.com/thehowl/go-osuapi"
 	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/scores"
 	"github.com/wieku/danser-go/app/settings"
 	"github.com/wieku/danser-go/app/skin"
-	"github.com/wieku/danser-go/framework/env"
 	"github.com/wieku/danser-go/framework/graphics/batch"
 	"github.com/wieku/danser-go/framework/graphics/sprite"
 	"github.com/wieku/danser-go/framework/math/animation"
@@ -15,9 +13,7 @@ import (
 	"github.com/wieku/danser-go/framework/math/vector"
 	"log"
 	"math"
-	"path/filepath"
 	"sort"
-	"strings"
 )
 
 const spacing = 57.6
@@ -56,67 +52,19 @@ func NewScoreboard(beatMap *beatmap.BeatMap, omitID int64) *ScoreBoard {
 		return board
 	}
 
-	key := strings.TrimSpace(settings.Credentails.ApiV1Key)
-	if key == "" {
-		log.Println(fmt.Sprintf("Please put your osu!api v1 key into '%s' file", filepath.Join(env.ConfigDir(), "credentials.json")))
-	} else {
-		client := osuapi.NewClient(key)
-		err := client.Test()
+	for i, s := range scores.Load(scores.GetSources(), beatMap, omitID, 50) {
+		entry := NewScoreboardEntry(s.Name, s.Score, s.Combo, i+1, false)
 
-		if err != nil {
-			log.Println("Can't connect to osu!api:", err)
-		} else {
-			beatMaps, err := client.GetBeatmaps(osuapi.GetBeatmapsOpts{BeatmapHash: beatMap.MD5})
-			if len(beatMaps) == 0 || err != nil {
-				log.Println("Online beatmap not found!")
-				if err != nil {
-					log.Println(err)
-				}
-			} else {
-				opts := osuapi.GetScoresOpts{BeatmapID: beatMaps[0].BeatmapID, Limit: 51}
-
-				if settings.Gameplay.ScoreBoard.ModsOnly {
-					mods1 := osuapi.Mods(beatMap.Diff.Mods)
-					opts.Mods = &mods1
-				}
-
-				scores, err := client.GetScores(opts)
-				if len(scores) == 0 || err != nil {
-					log.Println("Can't find online scores!")
-					if err != nil {
-						log.Println(err)
-					}
-				} else {
-					for i := 0; i < len(scores); i++ {
-						if scores[i].ScoreID == omitID {
-							scores = append(scores[:i], scores[i+1:]...)
-							i--
-						}
-					}
-
-					sort.SliceStable(scores, func(i, j int) bool {
-						return scores[i].Score.Score > scores[j].Score.Score
-					})
-
-					for i := 0; i < mutils.Min(len(scores), 50); i++ {
-						s := scores[i]
-
-						entry := NewScoreboardEntry(s.Username, s.Score.Score, int64(s.MaxCombo), i+1, false)
-
-						if settings.Gameplay.ScoreBoard.ShowAvatars {
-							entry.LoadAvatarID(s.UserID)
-						}
-
-						board.scores = append(board.scores, entry)
-						board.displayScores = append(board.displayScores, entry)
-					}
-				}
-
-				log.Println("SCORES", len(scores))
-			}
+		if settings.Gameplay.ScoreBoard.ShowAvatars && s.UserID > 0 {
+			entry.LoadAvatarID(s.UserID)
 		}
+
+		board.scores = append(board.scores, entry)
+		board.displayScores = append(board.displayScores, entry)
 	}
 
+	log.Println("SCORES", len(board.scores))
+
 	return board
 }
 
@@ -156,6 +104,20 @@ func (board *ScoreBoard) AddPlayer(name string, autoPlay bool) {
 	board.avatarsVisible = hasAvatar
 }
 
+// Reset brings player's entry back to zero score, scores of other players are kept
+func (board *ScoreBoard) Reset() {
+	board.time = 0
+	board.first = true
+
+	for _, e := range board.scores {
+		e.ClearTransformations()
+	}
+
+	board.UpdatePlayer(0, 0)
+
+	board.explosionManager = sprite.NewManager()
+}
+
 func (board *ScoreBoard) UpdatePlayer(score, combo int64) {
 	board.playerEntry.score = score
 	board.playerEntry.combo = combo
@@ -267,6 +229,25 @@ func (board *ScoreBoard) Update(time float64) {
 	}
 }
 
+// GetBounds returns top-left and bottom-right corners of visible entries
+func (board *ScoreBoard) GetBounds() (vector.Vector2d, vector.Vector2d) {
+	scale := settings.Gameplay.ScoreBoard.Scale
+
+	entryWidth := padding
+	if board.avatarsVisible {
+		entryWidth += 52
+	}
+
+	size := vector.NewVec2d(entryWidth, float64(mutils.Min(len(board.scores), visible))*spacing).Scl(scale)
+
+	topLeft := vector.NewVec2d(settings.Gameplay.ScoreBoard.XOffset, start+settings.Gameplay.ScoreBoard.YOffset-spacing*scale/2)
+	if settings.Gameplay.ScoreBoard.AlignRight {
+		topLeft.X += board.width - size.X
+	}
+
+	return topLeft, topLeft.Add(size)
+}
+
 func (board *ScoreBoard) Draw(batch *batch.QuadBatch, alpha float64) {
 	if !settings.Gameplay.ScoreBoard.Show {
 		return
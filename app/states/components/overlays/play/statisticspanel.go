@@ -0,0 +1,509 @@
+package play
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/graphics"
+	"github.com/wieku/danser-go/app/rulesets/osu"
+	"github.com/wieku/danser-go/app/rulesets/osu/performance/pp220930"
+	"github.com/wieku/danser-go/app/rulesets/osu/statistics"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/skin"
+	"github.com/wieku/danser-go/framework/graphics/batch"
+	"github.com/wieku/danser-go/framework/graphics/font"
+	"github.com/wieku/danser-go/framework/graphics/shape"
+	"github.com/wieku/danser-go/framework/graphics/sprite"
+	"github.com/wieku/danser-go/framework/math/color"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"math"
+	"strconv"
+)
+
+const (
+	statsMargin     = 24.0
+	statsLeftWidth  = 300.0
+	statsGraphsLeft = statsMargin*2 + statsLeftWidth
+)
+
+// Judgement is a non-300 judgement marked on the strain graph of StatisticsPanel
+type Judgement struct {
+	Time   float64
+	Result osu.HitResult
+}
+
+type judgementSection struct {
+	startTime float64
+	endTime   float64
+
+	misses   int
+	count100 int
+	count50  int
+}
+
+type StatisticsPanel struct {
+	manager *sprite.Manager
+	time    float64
+
+	ScaledWidth float64
+
+	beatmapName    string
+	beatmapCreator string
+	playedBy       string
+
+	score    string
+	accuracy string
+	maxCombo string
+
+	hitCounts [4]uint
+	keyCounts [4]int
+
+	pp             pp220930.PPv2Results
+	showFlashlight bool
+
+	histogram    statistics.Histogram
+	unstableRate float64
+	avgNeg       float64
+	avgPos       float64
+
+	accGraph []vector.Vector2d
+
+	strains    []float64
+	startTime  float64
+	endTime    float64
+	judgements []Judgement
+	sections   []judgementSection
+
+	shapeRenderer *shape.Renderer
+}
+
+// NewStatisticsPanel creates the alternate results screen. accGraph contains accuracy after each judgement, keyCounts are presses of K1, K2, M1 and M2.
+func NewStatisticsPanel(cursor *graphics.Cursor, ruleset *osu.OsuRuleSet, hitError *HitErrorMeter, hitStatistics *statistics.HitStatistics, accGraph []vector.Vector2d, judgements []Judgement, strainGraph *StrainGraph, keyCounts [4]int) *StatisticsPanel {
+	panel := &StatisticsPanel{
+		manager:     sprite.NewManager(),
+		ScaledWidth: settings.Graphics.GetAspectRatio() * 768,
+		keyCounts:   keyCounts,
+	}
+
+	bg := newResultsBackground(ruleset, panel.ScaledWidth)
+	bg.SetColor(color.NewL(0.35))
+
+	p := graphics.Pixel.GetRegion()
+	rTop := sprite.NewSpriteSingle(&p, 999, vector.NewVec2d(0, 0), vector.TopLeft)
+	rTop.SetScaleV(vector.NewVec2d(panel.ScaledWidth, 96))
+	rTop.SetColor(color.NewL(0))
+	rTop.SetAlpha(0.8)
+
+	score := ruleset.GetScore(cursor)
+
+	grade := sprite.NewSpriteSingle(skin.GetTexture("ranking-"+score.Grade.TextureName()), 5, vector.NewVec2d(statsMargin+statsLeftWidth-45, 190), vector.Centre)
+	grade.SetScale(0.3)
+
+	panel.manager.Add(bg)
+	panel.manager.Add(rTop)
+	panel.manager.Add(grade)
+
+	bMap := ruleset.GetBeatMap()
+
+	panel.beatmapName = fmt.Sprintf("%s - %s [%s]", bMap.Artist, bMap.Name, bMap.Difficulty)
+	panel.beatmapCreator = fmt.Sprintf("Beatmap by %s", bMap.Creator)
+
+	scoreTime := cursor.ScoreTime
+	if settings.Gameplay.ResultsUseLocalTimeZone {
+		scoreTime = scoreTime.Local()
+	}
+
+	panel.playedBy = fmt.Sprintf("Played by %s on %s", cursor.Name, scoreTime.Format("2006-01-02 15:04:05 MST"))
+
+	if mods := bMap.Diff.GetModString(); mods != "" {
+		panel.playedBy += " with " + mods
+	}
+
+	panel.score = fmt.Sprintf("%08d", score.Score)
+	panel.accuracy = fmt.Sprintf("%.2f%%", score.Accuracy)
+	panel.maxCombo = fmt.Sprintf("%dx", score.Combo)
+
+	panel.hitCounts = [4]uint{score.Count300, score.Count100, score.Count50, score.CountMiss}
+
+	panel.pp = score.PP
+	panel.showFlashlight = bMap.Diff.CheckModActive(difficulty.Flashlight)
+
+	panel.histogram = hitStatistics.GetHistogram(settings.Gameplay.HitStatistics.HistogramBinSize)
+	panel.unstableRate = hitError.GetUnstableRate()
+	panel.avgNeg = hitError.GetAvgNeg()
+	panel.avgPos = hitError.GetAvgPos()
+
+	panel.accGraph = make([]vector.Vector2d, len(accGraph))
+	copy(panel.accGraph, accGraph)
+
+	for len(panel.accGraph) > 200 {
+		for i := len(panel.accGraph) - 1; i >= 0; i -= 2 {
+			panel.accGraph = append(panel.accGraph[:i], panel.accGraph[i+1:]...)
+		}
+	}
+
+	panel.strains, panel.startTime, panel.endTime = strainGraph.GetStrains()
+	panel.judgements = judgements
+
+	panel.createSections()
+
+	return panel
+}
+
+// createSections counts misses, 100s and 50s in sections of settings.Gameplay.HitStatistics.SectionLength
+func (panel *StatisticsPanel) createSections() {
+	length := settings.Gameplay.HitStatistics.SectionLength * 1000
+
+	count := mutils.Max(int(math.Ceil((panel.endTime-panel.startTime)/length)), 1)
+
+	panel.sections = make([]judgementSection, count)
+
+	for i := range panel.sections {
+		panel.sections[i].startTime = panel.startTime + float64(i)*length
+		panel.sections[i].endTime = panel.sections[i].startTime + length
+	}
+
+	for _, j := range panel.judgements {
+		index := mutils.Clamp(int((j.Time-panel.startTime)/length), 0, count-1)
+
+		switch {
+		case j.Result&osu.Miss > 0:
+			panel.sections[index].misses++
+		case j.Result&osu.Hit100 > 0:
+			panel.sections[index].count100++
+		case j.Result&osu.Hit50 > 0:
+			panel.sections[index].count50++
+		}
+	}
+}
+
+func (panel *StatisticsPanel) Update(time float64) {
+	panel.time = time
+	panel.manager.Update(time)
+}
+
+func (panel *StatisticsPanel) Draw(batch *batch.QuadBatch, alpha float64) {
+	batch.SetColor(1, 1, 1, alpha)
+	batch.ResetTransform()
+
+	panel.manager.Draw(panel.time, batch)
+
+	fnt := font.GetFont("Ubuntu Regular")
+
+	fnt.Overlap = 0.7
+
+	fnt.Draw(batch, 5, 30-3, 30, panel.beatmapName)
+
+	fnt.Overlap = 1
+
+	fnt.Draw(batch, 5, 30+22, 22, panel.beatmapCreator)
+
+	fnt.Overlap = 0
+
+	fnt.Draw(batch, 5, 30+22+22, 22, panel.playedBy)
+
+	if panel.shapeRenderer == nil {
+		panel.shapeRenderer = shape.NewRenderer()
+	}
+
+	graphWidth := panel.ScaledWidth - statsGraphsLeft - statsMargin
+
+	batch.Flush()
+
+	panel.shapeRenderer.SetCamera(batch.Projection)
+
+	panel.drawSummary(batch, alpha)
+	panel.drawStrains(batch, statsGraphsLeft, 120, graphWidth, 190, alpha)
+	panel.drawAccuracy(batch, statsGraphsLeft, 330, graphWidth, 150, alpha)
+	panel.drawHitErrors(batch, statsGraphsLeft, 500, graphWidth, 150, alpha)
+}
+
+// drawSummary draws score, judgements, key presses and pp breakdown in the left column
+func (panel *StatisticsPanel) drawSummary(batch *batch.QuadBatch, alpha float64) {
+	fnt := font.GetFont("Ubuntu Regular")
+	hudFnt := font.GetFont("HUDFont")
+
+	x := statsMargin
+
+	drawValue := func(y float64, label, value string) {
+		batch.SetColor(1, 1, 1, alpha*0.7)
+		fnt.DrawOrigin(batch, x, y, vector.TopLeft, 14, false, label)
+
+		batch.SetColor(1, 1, 1, alpha)
+		hudFnt.DrawOrigin(batch, x, y+16, vector.TopLeft, 30, true, value)
+	}
+
+	drawValue(120, "Score", panel.score)
+	drawValue(176, "Accuracy", panel.accuracy)
+	drawValue(232, "Max combo", panel.maxCombo)
+
+	hitNames := []string{"300", "100", "50", "Miss"}
+	keyNames := []string{"K1", "K2", "M1", "M2"}
+
+	boxWidth := (statsLeftWidth - 3*8) / 4
+
+	panel.shapeRenderer.Begin()
+
+	for i := 0; i < 4; i++ {
+		bX := float32(x + float64(i)*(boxWidth+8))
+
+		for _, bY := range []float32{300, 390} {
+			panel.shapeRenderer.SetColor(0, 0, 0, alpha*0.6)
+			panel.shapeRenderer.DrawQuad(bX, bY, bX+float32(boxWidth), bY, bX+float32(boxWidth), bY+50, bX, bY+50)
+		}
+
+		r, g, b := judgementColor(i)
+
+		panel.shapeRenderer.SetColor(r, g, b, alpha)
+		panel.shapeRenderer.DrawQuad(bX, 347, bX+float32(boxWidth), 347, bX+float32(boxWidth), 350, bX, 350)
+	}
+
+	panel.shapeRenderer.End()
+
+	for i := 0; i < 4; i++ {
+		bX := x + float64(i)*(boxWidth+8) + boxWidth/2
+
+		batch.SetColor(1, 1, 1, alpha*0.7)
+		fnt.DrawOrigin(batch, bX, 304, vector.TopCentre, 12, false, hitNames[i])
+		fnt.DrawOrigin(batch, bX, 394, vector.TopCentre, 12, false, keyNames[i])
+
+		batch.SetColor(1, 1, 1, alpha)
+		hudFnt.DrawOrigin(batch, bX, 318, vector.TopCentre, 20, true, strconv.Itoa(int(panel.hitCounts[i])))
+		hudFnt.DrawOrigin(batch, bX, 408, vector.TopCentre, 20, true, strconv.Itoa(panel.keyCounts[i]))
+	}
+
+	batch.SetColor(1, 1, 1, alpha*0.7)
+	fnt.DrawOrigin(batch, x, 372, vector.TopLeft, 14, false, "Key presses")
+
+	drawValue(460, "Performance", fmt.Sprintf("%."+strconv.Itoa(settings.Gameplay.PPCounter.Decimals)+"fpp", panel.pp.Total))
+
+	names := []string{"Aim", "Speed", "Accuracy"}
+	values := []float64{panel.pp.Aim, panel.pp.Speed, panel.pp.Acc}
+
+	if panel.showFlashlight {
+		names = append(names, "Flashlight")
+		values = append(values, panel.pp.Flashlight)
+	}
+
+	maxValue := 1.0
+	for _, v := range values {
+		maxValue = math.Max(maxValue, v)
+	}
+
+	barX := float32(x + 80)
+	barWidth := float32(statsLeftWidth - 80 - 60)
+
+	panel.shapeRenderer.Begin()
+
+	for i, v := range values {
+		bY := float32(520 + i*26)
+
+		panel.shapeRenderer.SetColor(0, 0, 0, alpha*0.6)
+		panel.shapeRenderer.DrawQuad(barX, bY, barX+barWidth, bY, barX+barWidth, bY+14, barX, bY+14)
+
+		bW := barWidth * float32(v/maxValue)
+
+		panel.shapeRenderer.SetColor(1, 0.8, 0.3, alpha)
+		panel.shapeRenderer.DrawQuad(barX, bY, barX+bW, bY, barX+bW, bY+14, barX, bY+14)
+	}
+
+	panel.shapeRenderer.End()
+
+	for i, v := range values {
+		bY := float64(520+i*26) + 7
+
+		batch.SetColor(1, 1, 1, alpha*0.7)
+		fnt.DrawOrigin(batch, x, bY, vector.CentreLeft, 14, false, names[i])
+
+		batch.SetColor(1, 1, 1, alpha)
+		fnt.DrawOrigin(batch, x+statsLeftWidth, bY, vector.CentreRight, 14, false, fmt.Sprintf("%.1fpp", v))
+	}
+}
+
+// drawStrains draws the strain graph with misses marked as lines, and numbers of misses, 100s and 50s per section below it
+func (panel *StatisticsPanel) drawStrains(batch *batch.QuadBatch, x, y, width, height float64, alpha float64) {
+	const (
+		pad        = 10
+		stripSize  = 28
+		labelSpace = 24
+	)
+
+	gLeft := float32(x + pad)
+	gWidth := float32(width - 2*pad)
+	gTop := float32(y + labelSpace)
+	gBottom := float32(y+height) - stripSize - 12
+
+	timeToX := func(time float64) float32 {
+		if panel.endTime <= panel.startTime {
+			return gLeft
+		}
+
+		return gLeft + gWidth*float32(mutils.Clamp((time-panel.startTime)/(panel.endTime-panel.startTime), 0, 1))
+	}
+
+	panel.shapeRenderer.Begin()
+
+	panel.drawBox(float32(x), float32(y), float32(width), float32(height), alpha)
+
+	if len(panel.strains) > 1 {
+		maxStrain := 0.0001
+		for _, s := range panel.strains {
+			maxStrain = math.Max(maxStrain, s)
+		}
+
+		panel.shapeRenderer.SetColor(0.9, 0.55, 0.92, alpha*0.6)
+
+		step := gWidth / float32(len(panel.strains)-1)
+
+		for i := 0; i < len(panel.strains)-1; i++ {
+			x1 := gLeft + float32(i)*step
+			x2 := x1 + step
+			y1 := gBottom - float32(panel.strains[i]/maxStrain)*(gBottom-gTop)
+			y2 := gBottom - float32(panel.strains[i+1]/maxStrain)*(gBottom-gTop)
+
+			panel.shapeRenderer.DrawQuad(x1, gBottom, x1, y1, x2, y2, x2, gBottom)
+		}
+	}
+
+	for _, j := range panel.judgements {
+		jX := timeToX(j.Time)
+
+		switch {
+		case j.Result&osu.Miss > 0:
+			panel.shapeRenderer.SetColor(1, 0.25, 0.25, alpha*0.8)
+			panel.shapeRenderer.DrawLine(jX, gTop, jX, gBottom, 1.5)
+		case j.Result&(osu.Hit100|osu.Hit50) > 0:
+			panel.shapeRenderer.SetColor(0.44, 0.98, 0.18, alpha*0.4)
+			panel.shapeRenderer.DrawLine(jX, gBottom-(gBottom-gTop)/4, jX, gBottom, 1)
+		}
+	}
+
+	maxCount := 1
+	for _, s := range panel.sections {
+		maxCount = mutils.Max(maxCount, s.misses+s.count100+s.count50)
+	}
+
+	stripBottom := float32(y+height) - 6
+
+	for _, s := range panel.sections {
+		sX1 := timeToX(s.startTime) + 1
+		sX2 := timeToX(s.endTime) - 1
+
+		bY := stripBottom
+
+		for i, c := range []int{s.misses, s.count100, s.count50} {
+			if c == 0 {
+				continue
+			}
+
+			r, g, b := judgementColor(3 - i)
+
+			bH := float32(c) / float32(maxCount) * stripSize
+
+			panel.shapeRenderer.SetColor(r, g, b, alpha)
+			panel.shapeRenderer.DrawQuad(sX1, bY-bH, sX2, bY-bH, sX2, bY, sX1, bY)
+
+			bY -= bH
+		}
+	}
+
+	panel.shapeRenderer.End()
+
+	fnt := font.GetFont("Ubuntu Regular")
+
+	batch.SetColor(1, 1, 1, alpha)
+	fnt.DrawOrigin(batch, x+5, y+5, vector.TopLeft, 12, false, "Strain, misses and 100s")
+	fnt.DrawOrigin(batch, x+width-5, y+5, vector.TopRight, 12, false, fmt.Sprintf("max %d per section", maxCount))
+}
+
+// drawAccuracy draws accuracy over time, scaled to the lowest accuracy rounded down to 5%
+func (panel *StatisticsPanel) drawAccuracy(batch *batch.QuadBatch, x, y, width, height float64, alpha float64) {
+	const (
+		pad        = 10
+		labelSpace = 24
+	)
+
+	panel.shapeRenderer.Begin()
+
+	panel.drawBox(float32(x), float32(y), float32(width), float32(height), alpha)
+
+	minAcc := 95.0
+	for _, p := range panel.accGraph {
+		minAcc = math.Min(minAcc, math.Floor(p.Y/5)*5)
+	}
+
+	gLeft := x + pad
+	gWidth := width - 2*pad
+	gTop := y + labelSpace
+	gHeight := height - labelSpace - pad
+
+	if len(panel.accGraph) > 1 {
+		begin := panel.accGraph[0].X
+		end := math.Max(panel.accGraph[len(panel.accGraph)-1].X, begin+1)
+
+		toScreen := func(p vector.Vector2d) (float32, float32) {
+			return float32(gLeft + gWidth*(p.X-begin)/(end-begin)), float32(gTop + gHeight*(100-p.Y)/(100-minAcc))
+		}
+
+		panel.shapeRenderer.SetColor(1, 0.8, 0.3, alpha)
+
+		for i := 0; i < len(panel.accGraph)-1; i++ {
+			x1, y1 := toScreen(panel.accGraph[i])
+			x2, y2 := toScreen(panel.accGraph[i+1])
+
+			panel.shapeRenderer.DrawLine(x1, y1, x2, y2, 2)
+		}
+	}
+
+	panel.shapeRenderer.End()
+
+	fnt := font.GetFont("Ubuntu Regular")
+
+	batch.SetColor(1, 1, 1, alpha)
+	fnt.DrawOrigin(batch, x+5, y+5, vector.TopLeft, 12, false, "Accuracy over time")
+	fnt.DrawOrigin(batch, x+width-5, y+5, vector.TopRight, 12, false, fmt.Sprintf("%.0f%% - 100%%", minAcc))
+}
+
+// drawHitErrors draws hit error histogram with unstable rate and average errors
+func (panel *StatisticsPanel) drawHitErrors(batch *batch.QuadBatch, x, y, width, height float64, alpha float64) {
+	const labelSpace = 20
+
+	panel.shapeRenderer.Begin()
+
+	panel.drawBox(float32(x), float32(y), float32(width), float32(height), alpha)
+
+	maxError := 0.0
+	if len(panel.histogram.Bins) > 0 {
+		maxError = drawHistogram(panel.shapeRenderer, panel.histogram, float32(x), float32(y+labelSpace), float32(width), float32(height-labelSpace), alpha)
+	}
+
+	panel.shapeRenderer.End()
+
+	fnt := font.GetFont("Ubuntu Regular")
+
+	batch.SetColor(1, 1, 1, alpha)
+	fnt.DrawOrigin(batch, x+5, y+5, vector.TopLeft, 12, false, fmt.Sprintf("Hit errors: %.2fms - %.2fms avg, %.2f UR", panel.avgNeg, panel.avgPos, panel.unstableRate))
+
+	if maxError > 0 {
+		fnt.DrawOrigin(batch, x+width-5, y+5, vector.TopRight, 12, false, fmt.Sprintf("±%.0fms", maxError))
+	}
+}
+
+func (panel *StatisticsPanel) drawBox(x, y, width, height float32, alpha float64) {
+	panel.shapeRenderer.SetColor(0, 0, 0, alpha*0.6)
+	panel.shapeRenderer.DrawQuad(x, y, x+width, y, x+width, y+height, x, y+height)
+}
+
+// judgementColor returns color of 300s, 100s, 50s and misses for index 0 to 3
+func judgementColor(index int) (r, g, b float64) {
+	switch index {
+	case 0:
+		return 0.4, 0.8, 1
+	case 1:
+		return 0.44, 0.98, 0.18
+	case 2:
+		return 1, 0.75, 0.2
+	default:
+		return 1, 0.25, 0.25
+	}
+}
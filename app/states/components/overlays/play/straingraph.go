@@ -191,6 +191,18 @@ func (graph *StrainGraph) drawFBO(batch *batch.QuadBatch) {
 	graph.rightSprite.Texture = &region
 }
 
+// GetBounds returns top-left and bottom-right corners of the graph
+func (graph *StrainGraph) GetBounds() (vector.Vector2d, vector.Vector2d) {
+	conf := settings.Gameplay.StrainGraph
+
+	size := vector.NewVec2d(conf.Width, conf.Height)
+	origin := vector.ParseOrigin(conf.Align).AddS(1, 1).Scl(0.5)
+
+	topLeft := vector.NewVec2d(conf.XPosition, conf.YPosition).Sub(origin.Mult(size))
+
+	return topLeft, topLeft.Add(size)
+}
+
 func (graph *StrainGraph) Draw(batch *batch.QuadBatch, alpha float64) {
 	conf := settings.Gameplay.StrainGraph
 
@@ -227,3 +239,14 @@ func (graph *StrainGraph) Draw(batch *batch.QuadBatch, alpha float64) {
 
 	batch.ResetTransform()
 }
+
+// GetStrains returns star rating strain peaks above the baseline, spread evenly between startTime and endTime
+func (graph *StrainGraph) GetStrains() (strains []float64, startTime, endTime float64) {
+	strains = make([]float64, len(graph.strains.Total))
+
+	for i, s := range graph.strains.Total {
+		strains[i] = math.Max(s-graph.baseLine, 0)
+	}
+
+	return strains, graph.startTime, graph.endTime
+}
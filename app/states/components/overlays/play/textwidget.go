@@ -0,0 +1,115 @@
+package play
+
+import (
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states/components/overlays/hud"
+	"github.com/wieku/danser-go/framework/env"
+	"github.com/wieku/danser-go/framework/graphics/batch"
+	"github.com/wieku/danser-go/framework/graphics/font"
+	color2 "github.com/wieku/danser-go/framework/math/color"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TextWidget draws a template filled with gameplay variables, it's configured by settings.Gameplay.TextWidgets[index]
+type TextWidget struct {
+	index int
+
+	variables *hud.Variables
+
+	templateText string
+	template     *hud.Template
+
+	fnt  *font.Font
+	text string
+
+	ScaledWidth  float64
+	ScaledHeight float64
+}
+
+func NewTextWidget(index int, variables *hud.Variables) *TextWidget {
+	widget := &TextWidget{
+		index:     index,
+		variables: variables,
+		fnt:       font.GetFont("HUDFont"),
+	}
+
+	widget.ScaledHeight = 768
+	widget.ScaledWidth = settings.Graphics.GetAspectRatio() * widget.ScaledHeight
+
+	if fPath := strings.TrimSpace(settings.Gameplay.TextWidgets[index].Font); fPath != "" {
+		if !filepath.IsAbs(fPath) {
+			fPath = filepath.Join(env.DataDir(), fPath)
+		}
+
+		file, err := os.Open(fPath)
+
+		if err == nil {
+			widget.fnt = font.LoadFont(file)
+			file.Close()
+		} else {
+			log.Println("Can't open text widget font:", err.Error())
+		}
+	}
+
+	return widget
+}
+
+func (widget *TextWidget) Draw(batch *batch.QuadBatch, alpha float64) {
+	conf := settings.Gameplay.TextWidgets[widget.index]
+
+	wAlpha := conf.Opacity * alpha
+
+	if wAlpha < 0.001 || !conf.Show {
+		return
+	}
+
+	if widget.template == nil || widget.templateText != conf.Template {
+		widget.templateText = conf.Template
+		widget.template = hud.ParseTemplate(conf.Template)
+	}
+
+	widget.text = widget.template.Execute(widget.variables)
+
+	size := conf.Size * conf.Scale
+
+	position := widget.getPosition()
+	origin := vector.ParseOrigin(conf.Align)
+
+	batch.ResetTransform()
+
+	if conf.Shadow {
+		batch.SetColorM(color2.NewHSVA(float32(conf.ShadowColor.Hue), float32(conf.ShadowColor.Saturation), float32(conf.ShadowColor.Value), float32(wAlpha*0.8)))
+		widget.fnt.DrawOriginV(batch, position.AddS(conf.ShadowOffset*conf.Scale, conf.ShadowOffset*conf.Scale), origin, size, false, widget.text)
+	}
+
+	batch.SetColorM(color2.NewHSVA(float32(conf.Color.Hue), float32(conf.Color.Saturation), float32(conf.Color.Value), float32(wAlpha)))
+	widget.fnt.DrawOriginV(batch, position, origin, size, false, widget.text)
+
+	batch.ResetTransform()
+}
+
+// GetBounds returns top-left and bottom-right corners of the text drawn in the last frame
+func (widget *TextWidget) GetBounds() (vector.Vector2d, vector.Vector2d) {
+	conf := settings.Gameplay.TextWidgets[widget.index]
+
+	size := conf.Size * conf.Scale
+
+	textSize := vector.NewVec2d(widget.fnt.GetWidth(size, widget.text), size)
+	origin := vector.ParseOrigin(conf.Align).AddS(1, 1).Scl(0.5)
+
+	topLeft := widget.getPosition().Sub(origin.Mult(textSize))
+
+	return topLeft, topLeft.Add(textSize)
+}
+
+func (widget *TextWidget) getPosition() vector.Vector2d {
+	conf := settings.Gameplay.TextWidgets[widget.index]
+
+	anchor := vector.ParseOrigin(conf.Anchor).AddS(1, 1).Scl(0.5).Mult(vector.NewVec2d(widget.ScaledWidth, widget.ScaledHeight))
+
+	return anchor.AddS(conf.XPosition, conf.YPosition)
+}
@@ -0,0 +1,297 @@
+package overlays
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/wieku/danser-go/app/input"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states/components/overlays/hud"
+	"github.com/wieku/danser-go/app/states/components/overlays/play"
+	"github.com/wieku/danser-go/framework/env"
+	"github.com/wieku/danser-go/framework/graphics/batch"
+	"github.com/wieku/danser-go/framework/math/animation"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// hudPart is a HUD element that can be placed by HUD layout
+type hudPart struct {
+	// Name used in HUD layout, parts without a name can't be moved
+	name string
+
+	// Default z-order
+	z int
+
+	bounds func() hud.Bounds
+	draw   func(batch *batch.QuadBatch, alpha float64)
+
+	fade   *animation.Glider
+	hidden bool
+}
+
+func loadHUDLayout() *hud.Layout {
+	lPath := strings.TrimSpace(settings.Gameplay.HUDLayout)
+
+	if lPath == "" {
+		// Default location used by the editor when no layout is selected
+		lPath = filepath.Join("huds", "layout.json")
+	}
+
+	if !filepath.IsAbs(lPath) {
+		lPath = filepath.Join(env.DataDir(), lPath)
+	}
+
+	layout, err := hud.LoadLayout(lPath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Println("Failed to load HUD layout:", err.Error())
+		}
+
+		return hud.NewLayout(lPath)
+	}
+
+	return layout
+}
+
+func (overlay *ScoreOverlay) initHUD() {
+	overlay.hudLayout = loadHUDLayout()
+
+	if (settings.PLAY || settings.DEBUG) && !settings.RECORD && input.Win != nil {
+		overlay.hudEditor = hud.NewEditor(overlay.hudLayout, overlay.ScaledWidth, overlay.ScaledHeight)
+		overlay.hudEditorListener = input.RegisterListener(overlay.hudEditor.KeyEvent)
+	}
+}
+
+// IsEditingHUD returns true if HUD layout editor is open, other controls shouldn't react to input then
+func (overlay *ScoreOverlay) IsEditingHUD() bool {
+	return overlay.hudEditor != nil && overlay.hudEditor.IsActive()
+}
+
+// Dispose removes HUD editor's key listener, overlay can't be used afterwards
+func (overlay *ScoreOverlay) Dispose() {
+	if overlay.hudEditor != nil {
+		input.UnregisterListener(overlay.hudEditorListener)
+
+		overlay.hudEditor = nil
+	}
+}
+
+// initHUDParts collects HUD elements drawn by the overlay, it has to be called again when they are recreated
+func (overlay *ScoreOverlay) initHUDParts() {
+	fromPlay := func(getBounds func() (vector.Vector2d, vector.Vector2d)) func() hud.Bounds {
+		return func() hud.Bounds {
+			return hud.NewBounds(getBounds())
+		}
+	}
+
+	addPart := func(name string, bounds func() hud.Bounds, draw func(batch *batch.QuadBatch, alpha float64)) {
+		overlay.hudParts = append(overlay.hudParts, &hudPart{
+			name:   name,
+			z:      len(overlay.hudParts),
+			bounds: bounds,
+			draw:   draw,
+			fade:   animation.NewGlider(1),
+		})
+	}
+
+	addPart("ScoreBoard", fromPlay(overlay.entry.GetBounds), overlay.entry.Draw)
+
+	addPart("", nil, func(batch *batch.QuadBatch, _ float64) {
+		overlay.passContainer.Draw(overlay.audioTime, batch)
+	})
+
+	addPart("Score", overlay.getScoreBounds, overlay.drawScore)
+	addPart("ComboCounter", fromPlay(overlay.comboCounter.GetBounds), overlay.comboCounter.Draw)
+	addPart("HpBar", fromPlay(overlay.hpBar.GetBounds), overlay.hpBar.Draw)
+	addPart("KeyOverlay", overlay.getKeysBounds, overlay.drawKeys)
+
+	addPart("Mods", overlay.getModsBounds, func(batch *batch.QuadBatch, _ float64) {
+		if settings.Gameplay.Mods.Show {
+			batch.SetTranslation(vector.NewVec2d(settings.Gameplay.Mods.XOffset, settings.Gameplay.Mods.YOffset))
+			overlay.mods.Draw(overlay.lastTime, batch)
+			batch.ResetTransform()
+		}
+	})
+
+	addPart("", nil, func(batch *batch.QuadBatch, _ float64) {
+		if !overlay.failed && settings.Gameplay.ShowWarningArrows {
+			overlay.arrows.Draw(overlay.audioTime, batch)
+		}
+	})
+
+	addPart("PPCounter", fromPlay(overlay.ppDisplay.GetBounds), overlay.ppDisplay.Draw)
+	addPart("StrainGraph", fromPlay(overlay.strainGraph.GetBounds), overlay.strainGraph.Draw)
+	addPart("HitCounter", fromPlay(overlay.hitCounts.GetBounds), overlay.hitCounts.Draw)
+	addPart("HitErrorTimeline", fromPlay(overlay.hitTimeline.GetBounds), overlay.hitTimeline.Draw)
+
+	if overlay.personalBest != nil {
+		addPart("PersonalBest", fromPlay(overlay.personalBest.GetBounds), overlay.personalBest.Draw)
+	}
+
+	for i := range settings.Gameplay.TextWidgets {
+		widget := play.NewTextWidget(i, overlay.variables)
+		addPart(fmt.Sprintf("TextWidget%d", i+1), fromPlay(widget.GetBounds), widget.Draw)
+	}
+}
+
+// getLayoutElement returns part's placement or nil if it uses the default one
+func (overlay *ScoreOverlay) getLayoutElement(part *hudPart) *hud.Element {
+	if part.name == "" {
+		return nil
+	}
+
+	return overlay.hudLayout.Get(part.name)
+}
+
+// updateTimelineSeek calls seek listener when hit error timeline is clicked. It has to be called on the main thread.
+func (overlay *ScoreOverlay) updateTimelineSeek() {
+	if overlay.seekListener == nil || input.Win == nil || !settings.Gameplay.HitErrorTimeline.Show || overlay.IsEditingHUD() {
+		return
+	}
+
+	pressed := input.Win.GetMouseButton(glfw.MouseButtonLeft) == glfw.Press
+
+	clicked := pressed && !overlay.seekPressed
+	overlay.seekPressed = pressed
+
+	if !clicked {
+		return
+	}
+
+	wWidth, wHeight := input.Win.GetSize()
+	x, y := input.Win.GetCursorPos()
+
+	point := mgl32.Vec4{float32(x * overlay.ScaledWidth / float64(mutils.Max(wWidth, 1))), float32(y * overlay.ScaledHeight / float64(mutils.Max(wHeight, 1))), 0, 1}
+
+	// Bring the point back to timeline's default placement if the layout moves it
+	if element := overlay.hudLayout.Get("HitErrorTimeline"); element != nil {
+		point = element.Transform(hud.NewBounds(overlay.hitTimeline.GetBounds()), overlay.ScaledWidth, overlay.ScaledHeight).Inv().Mul4x1(point)
+	}
+
+	if time, ok := overlay.hitTimeline.GetTimeAt(vector.NewVec2d(float64(point.X()), float64(point.Y()))); ok {
+		overlay.seekListener(time)
+	}
+}
+
+// updateHUDVisibility fades out parts whose layout conditions are met. It's called while drawing as the layout can be changed by the editor.
+func (overlay *ScoreOverlay) updateHUDVisibility(time float64) {
+	for _, part := range overlay.hudParts {
+		part.fade.Update(time)
+
+		hidden := false
+		if element := overlay.getLayoutElement(part); element != nil {
+			hidden = element.IsHidden(overlay.breakMode, overlay.failed, settings.KNOCKOUT)
+		}
+
+		if hidden != part.hidden {
+			part.hidden = hidden
+
+			target := 1.0
+			if hidden {
+				target = 0.0
+			}
+
+			part.fade.AddEvent(time, time+500, target)
+		}
+	}
+}
+
+func (overlay *ScoreOverlay) getSortedHUDParts() []*hudPart {
+	getZ := func(part *hudPart) int {
+		if element := overlay.getLayoutElement(part); element != nil {
+			return element.Z
+		}
+
+		return part.z
+	}
+
+	sorted := make([]*hudPart, len(overlay.hudParts))
+	copy(sorted, overlay.hudParts)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return getZ(sorted[i]) < getZ(sorted[j])
+	})
+
+	return sorted
+}
+
+func (overlay *ScoreOverlay) drawHUDPart(batch *batch.QuadBatch, part *hudPart, projection mgl32.Mat4, alpha float64) {
+	partAlpha := alpha * part.fade.GetValue()
+
+	if partAlpha < 0.001 {
+		return
+	}
+
+	transform := mgl32.Ident4()
+
+	var bounds hud.Bounds
+
+	element := overlay.getLayoutElement(part)
+
+	if element != nil || (part.name != "" && overlay.IsEditingHUD()) {
+		bounds = part.bounds()
+	}
+
+	if element != nil {
+		transform = element.Transform(bounds, overlay.ScaledWidth, overlay.ScaledHeight)
+		partAlpha *= element.Opacity
+	}
+
+	batch.SetCamera(projection.Mul4(transform))
+	batch.ResetTransform()
+	batch.SetColor(1, 1, 1, partAlpha)
+
+	part.draw(batch, partAlpha)
+
+	if part.name != "" && overlay.hudEditor != nil {
+		overlay.hudEditor.Track(part.name, bounds, transform)
+	}
+}
+
+func (overlay *ScoreOverlay) getScoreBounds() hud.Bounds {
+	scoreScale := settings.Gameplay.Score.Scale
+	rightOffset := -9.6 * scoreScale
+
+	scoreSize := overlay.scoreFont.GetSize() * scoreScale * 0.96
+	accSize := scoreSize * 0.6
+
+	width := overlay.scoreFont.GetWidthMonospaced(scoreSize, "00000000")
+	width = mutils.Max(width, overlay.scoreFont.GetWidthMonospaced(accSize, "99.99%")+(38.4+44.8+16)*scoreScale)
+
+	right := overlay.ScaledWidth + rightOffset + settings.Gameplay.Score.XOffset
+	top := settings.Gameplay.Score.YOffset
+
+	return hud.NewBounds(vector.NewVec2d(right-width, top), vector.NewVec2d(right, top+scoreSize+vAccOffset*scoreScale+accSize))
+}
+
+func (overlay *ScoreOverlay) getKeysBounds() hud.Bounds {
+	keyScale := settings.Gameplay.KeyOverlay.Scale
+
+	topLeft := vector.NewVec2d(overlay.ScaledWidth-48*keyScale, overlay.ScaledHeight/2-64).AddS(settings.Gameplay.KeyOverlay.XOffset, settings.Gameplay.KeyOverlay.YOffset)
+
+	return hud.NewBounds(topLeft, topLeft.AddS(48*keyScale, (30.4*2+47.2*3)*keyScale))
+}
+
+func (overlay *ScoreOverlay) getModsBounds() hud.Bounds {
+	scale := settings.Gameplay.Mods.Scale
+
+	count := len(overlay.ruleset.GetBeatMap().Diff.GetModStringFull())
+
+	step := 80 + settings.Gameplay.Mods.AdditionalSpacing
+	if (overlay.cursor.IsPlayer && !overlay.cursor.IsAutoplay) || settings.Gameplay.Mods.FoldInReplays {
+		step = 16 + settings.Gameplay.Mods.AdditionalSpacing
+	}
+
+	centre := vector.NewVec2d(overlay.ScaledWidth-48*scale, 150).AddS(settings.Gameplay.Mods.XOffset, settings.Gameplay.Mods.YOffset)
+
+	left := centre.X - step*scale*float64(mutils.Max(count-1, 0))
+
+	return hud.NewBounds(vector.NewVec2d(left-32*scale, centre.Y-32*scale), centre.AddS(32*scale, 32*scale))
+}
@@ -8,18 +8,23 @@ import (
 	"github.com/wieku/danser-go/app/beatmap/difficulty"
 	"github.com/wieku/danser-go/app/beatmap/objects"
 	camera2 "github.com/wieku/danser-go/app/bmath/camera"
+	"github.com/wieku/danser-go/app/database"
 	"github.com/wieku/danser-go/app/discord"
 	"github.com/wieku/danser-go/app/graphics"
 	"github.com/wieku/danser-go/app/input"
 	"github.com/wieku/danser-go/app/rulesets/osu"
 	"github.com/wieku/danser-go/app/rulesets/osu/performance/pp220930"
+	"github.com/wieku/danser-go/app/rulesets/osu/statistics"
+	"github.com/wieku/danser-go/app/scores"
 	"github.com/wieku/danser-go/app/settings"
 	"github.com/wieku/danser-go/app/skin"
 	"github.com/wieku/danser-go/app/states/components/common"
+	"github.com/wieku/danser-go/app/states/components/overlays/hud"
 	"github.com/wieku/danser-go/app/states/components/overlays/play"
 	"github.com/wieku/danser-go/framework/assets"
 	"github.com/wieku/danser-go/framework/bass"
 	"github.com/wieku/danser-go/framework/env"
+	"github.com/wieku/danser-go/framework/files"
 	"github.com/wieku/danser-go/framework/goroutines"
 	"github.com/wieku/danser-go/framework/graphics/batch"
 	"github.com/wieku/danser-go/framework/graphics/font"
@@ -31,6 +36,7 @@ import (
 	color2 "github.com/wieku/danser-go/framework/math/color"
 	"github.com/wieku/danser-go/framework/math/mutils"
 	"github.com/wieku/danser-go/framework/math/vector"
+	"github.com/wieku/rplpa"
 	"log"
 	"math"
 	"os"
@@ -78,6 +84,9 @@ type ScoreOverlay struct {
 	bgDim *animation.Glider
 
 	hitErrorMeter *play.HitErrorMeter
+	hitTimeline   *play.HitTimeline
+	hitStatistics *statistics.HitStatistics
+	statsExported bool
 
 	aimErrorMeter *play.AimErrorMeter
 
@@ -114,7 +123,9 @@ type ScoreOverlay struct {
 
 	resultsFade *animation.Glider
 	hpSections  []vector.Vector2d
-	panel       *play.RankingPanel
+	accSections []vector.Vector2d
+	judgements  []play.Judgement
+	panel       play.ResultsPanel
 	created     bool
 	skipTo      float64
 
@@ -123,12 +134,27 @@ type ScoreOverlay struct {
 
 	circularMetre *texture.TextureRegion
 
-	hitCounts   *play.HitDisplay
-	ppDisplay   *play.PPDisplay
-	strainGraph *play.StrainGraph
+	hitCounts    *play.HitDisplay
+	ppDisplay    *play.PPDisplay
+	strainGraph  *play.StrainGraph
+	personalBest *play.PersonalBestDisplay
+
+	replayFrames func() []*rplpa.ReplayData
+
+	seekListener func(time float64)
+	seekPressed  bool
 
 	underlay *sprite.Sprite
 	failed   bool
+
+	hudLayout *hud.Layout
+	hudEditor *hud.Editor
+
+	hudEditorListener int
+	hudParts          []*hudPart
+
+	variables *hud.Variables
+	maxCombo  uint
 }
 
 func loadFonts() {
@@ -177,6 +203,54 @@ func NewScoreOverlay(ruleset *osu.OsuRuleSet, cursor *graphics.Cursor) *ScoreOve
 
 	overlay.initUnderlay()
 
+	audio.LoadSample("sectionpass")
+	audio.LoadSample("sectionfail")
+
+	overlay.keyFont = font.GetFont("Quicksand Bold")
+	overlay.scoreEFont = skin.GetFont("scoreentry")
+	overlay.scoreFont = skin.GetFont("score")
+	overlay.circularMetre = skin.GetTextureSource("circularmetre", skin.LOCAL)
+
+	overlay.camera = camera2.NewCamera()
+	overlay.camera.SetViewportF(0, int(overlay.ScaledHeight), int(overlay.ScaledWidth), 0)
+	overlay.camera.Update()
+
+	overlay.shapeRenderer = shape.NewRenderer()
+
+	overlay.boundaries = common.NewBoundaries()
+
+	overlay.initHUD()
+
+	overlay.Reset(ruleset, cursor)
+
+	return overlay
+}
+
+// Reset brings the overlay back to its state before the map started, scoring the given ruleset and cursor of the same beatmap.
+// Textures, HUD layout and editor are kept, so restarts and seeks don't need a new overlay.
+func (overlay *ScoreOverlay) Reset(ruleset *osu.OsuRuleSet, cursor *graphics.Cursor) {
+	*overlay = ScoreOverlay{
+		ScaledWidth:       overlay.ScaledWidth,
+		ScaledHeight:      overlay.ScaledHeight,
+		camera:            overlay.camera,
+		keyFont:           overlay.keyFont,
+		scoreFont:         overlay.scoreFont,
+		scoreEFont:        overlay.scoreEFont,
+		circularMetre:     overlay.circularMetre,
+		shapeRenderer:     overlay.shapeRenderer,
+		boundaries:        overlay.boundaries,
+		underlay:          overlay.underlay,
+		music:             overlay.music,
+		beatmapEnd:        overlay.beatmapEnd,
+		audioDisabled:     overlay.audioDisabled,
+		replayFrames:      overlay.replayFrames,
+		seekListener:      overlay.seekListener,
+		entry:             overlay.entry,
+		hudLayout:         overlay.hudLayout,
+		hudEditor:         overlay.hudEditor,
+		hudEditorListener: overlay.hudEditorListener,
+	}
+
 	overlay.results = play.NewHitResults(ruleset.GetBeatMap().Diff)
 	overlay.ruleset = ruleset
 	overlay.cursor = cursor
@@ -192,9 +266,6 @@ func NewScoreOverlay(ruleset *osu.OsuRuleSet, cursor *graphics.Cursor) *ScoreOve
 
 	overlay.bgDim = animation.NewGlider(1)
 
-	audio.LoadSample("sectionpass")
-	audio.LoadSample("sectionfail")
-
 	overlay.sPass = sprite.NewSpriteSingle(skin.GetTexture("section-pass"), 0, vector.NewVec2d(overlay.ScaledWidth, overlay.ScaledHeight).Scl(0.5), vector.Centre)
 	overlay.sPass.SetAlpha(0)
 
@@ -213,17 +284,8 @@ func NewScoreOverlay(ruleset *osu.OsuRuleSet, cursor *graphics.Cursor) *ScoreOve
 
 	discord.UpdatePlay(cursor)
 
-	overlay.keyFont = font.GetFont("Quicksand Bold")
-	overlay.scoreEFont = skin.GetFont("scoreentry")
-	overlay.scoreFont = skin.GetFont("score")
-	overlay.circularMetre = skin.GetTextureSource("circularmetre", skin.LOCAL)
-
 	ruleset.SetListener(overlay.hitReceived)
 
-	overlay.camera = camera2.NewCamera()
-	overlay.camera.SetViewportF(0, int(overlay.ScaledHeight), int(overlay.ScaledWidth), 0)
-	overlay.camera.Update()
-
 	overlay.keyOverlay = sprite.NewManager()
 
 	keyBg := sprite.NewSpriteSingle(skin.GetTexture("inputoverlay-background"), 0, vector.NewVec2d(overlay.ScaledWidth, overlay.ScaledHeight/2-64), vector.TopLeft)
@@ -244,9 +306,13 @@ func NewScoreOverlay(ruleset *osu.OsuRuleSet, cursor *graphics.Cursor) *ScoreOve
 	}
 
 	overlay.hitErrorMeter = play.NewHitErrorMeter(overlay.ScaledWidth, overlay.ScaledHeight, ruleset.GetBeatMap().Diff)
+	overlay.hitStatistics = statistics.NewHitStatistics(ruleset.GetBeatMap(), settings.Gameplay.HitStatistics.SectionLength*1000)
 
 	overlay.aimErrorMeter = play.NewAimErrorMeter(ruleset.GetBeatMap().Diff)
 
+	lastObject := ruleset.GetBeatMap().HitObjects[len(ruleset.GetBeatMap().HitObjects)-1]
+	overlay.hitTimeline = play.NewHitTimeline(overlay.ScaledWidth, overlay.ScaledHeight, ruleset.GetBeatMap().Diff, ruleset.GetBeatMap().HitObjects[0].GetStartTime(), lastObject.GetEndTime()+float64(ruleset.GetBeatMap().Diff.Hit50))
+
 	showAfterSkip := 2000.0
 
 	beatLen := overlay.ruleset.GetBeatMap().Timings.GetPointAt(0).GetBaseBeatLength()
@@ -270,27 +336,35 @@ func NewScoreOverlay(ruleset *osu.OsuRuleSet, cursor *graphics.Cursor) *ScoreOve
 	}
 
 	overlay.comboCounter = play.NewComboCounter()
+	overlay.comboCounter.DisableAudioSubmission(overlay.audioDisabled)
 
 	overlay.hpBar = play.NewHpBar()
 
 	overlay.hitCounts = play.NewHitDisplay(overlay.ruleset, overlay.cursor)
 
-	overlay.shapeRenderer = shape.NewRenderer()
-
-	overlay.boundaries = common.NewBoundaries()
-
 	overlay.mods = sprite.NewManager()
 
 	if overlay.ruleset.GetBeatMap().Diff.Mods.Active(difficulty.Flashlight) {
 		overlay.flashlight = common.NewFlashlight(overlay.ruleset.GetBeatMap())
 	}
 
-	overlay.entry = play.NewScoreboard(overlay.ruleset.GetBeatMap(), overlay.cursor.ScoreID)
-	overlay.entry.AddPlayer(overlay.cursor.Name, overlay.cursor.IsAutoplay)
+	// Scores of other players don't change, so they are loaded only once
+	if overlay.entry == nil {
+		overlay.entry = play.NewScoreboard(overlay.ruleset.GetBeatMap(), overlay.cursor.ScoreID)
+		overlay.entry.AddPlayer(overlay.cursor.Name, overlay.cursor.IsAutoplay)
+	} else {
+		overlay.entry.Reset()
+	}
+
+	if settings.PLAY && !overlay.cursor.IsAutoplay {
+		overlay.initPersonalBest()
+	}
 
 	overlay.initArrows()
 
-	return overlay
+	overlay.initVariables()
+
+	overlay.initHUDParts()
 }
 
 func (overlay *ScoreOverlay) initUnderlay() {
@@ -324,6 +398,106 @@ func (overlay *ScoreOverlay) initUnderlay() {
 	overlay.underlay.SetScale(uScale)
 }
 
+func (overlay *ScoreOverlay) exportStatistics() {
+	bMap := overlay.ruleset.GetBeatMap()
+
+	name := files.FixName(fmt.Sprintf("%s - %s - %s [%s] (%s).json", overlay.cursor.Name, bMap.Artist, bMap.Name, bMap.Difficulty, overlay.cursor.ScoreTime.Format("2006-01-02_15-04-05")))
+
+	path := filepath.Join(env.DataDir(), "statistics", name)
+
+	report := overlay.hitStatistics.CreateReport(overlay.cursor.Name, settings.Gameplay.HitStatistics.HistogramBinSize, settings.Gameplay.HitStatistics.BiasWindow)
+
+	if err := report.ExportJSON(path); err != nil {
+		log.Println("Failed to export hit statistics:", err)
+		return
+	}
+
+	log.Println("Hit statistics exported to:", path)
+}
+
+// initPersonalBest loads the best local score of the player on the map with the same mods
+func (overlay *ScoreOverlay) initPersonalBest() {
+	bMap := overlay.ruleset.GetBeatMap()
+
+	best, err := database.GetPersonalBest(bMap.MD5, overlay.cursor.Name, int64(bMap.Diff.Mods))
+	if err != nil {
+		log.Println("Failed to load personal best:", err)
+		return
+	}
+
+	if best != nil {
+		overlay.personalBest = play.NewPersonalBestDisplay(best.Score, best.Accuracy)
+	}
+}
+
+// SetReplayFrames sets the source of frames saved as a replay with the score
+func (overlay *ScoreOverlay) SetReplayFrames(frames func() []*rplpa.ReplayData) {
+	overlay.replayFrames = frames
+}
+
+// SetSeekListener sets the function called with the time clicked on hit error timeline
+func (overlay *ScoreOverlay) SetSeekListener(listener func(time float64)) {
+	overlay.seekListener = listener
+}
+
+// saveScore saves the result of the play so it's shown on the scoreboard later
+func (overlay *ScoreOverlay) saveScore() {
+	sc := overlay.ruleset.GetScore(overlay.cursor)
+	bMap := overlay.ruleset.GetBeatMap()
+
+	score := &database.Score{
+		MD5:       bMap.MD5,
+		Name:      overlay.cursor.Name,
+		Mods:      int64(bMap.Diff.Mods),
+		Score:     sc.Score,
+		Combo:     int64(overlay.maxCombo),
+		Accuracy:  sc.Accuracy,
+		Date:      overlay.cursor.ScoreTime.Unix(),
+		Count300:  int64(sc.Count300),
+		Count100:  int64(sc.Count100),
+		Count50:   int64(sc.Count50),
+		CountMiss: int64(sc.CountMiss),
+		CountGeki: int64(sc.CountGeki),
+		CountKatu: int64(sc.CountKatu),
+		PP:        sc.PP.Total,
+		UR:        overlay.hitErrorMeter.GetUnstableRateConverted(),
+	}
+
+	if settings.Gameplay.SaveReplays && overlay.replayFrames != nil {
+		path, err := scores.SaveReplay(&rplpa.Replay{
+			BeatmapMD5: bMap.MD5,
+			Username:   overlay.cursor.Name,
+			Count300:   uint16(sc.Count300),
+			Count100:   uint16(sc.Count100),
+			Count50:    uint16(sc.Count50),
+			CountGeki:  uint16(sc.CountGeki),
+			CountKatu:  uint16(sc.CountKatu),
+			CountMiss:  uint16(sc.CountMiss),
+			Score:      int32(sc.Score),
+			MaxCombo:   uint16(overlay.maxCombo),
+			Fullcombo:  sc.PerfectCombo,
+			Mods:       uint32(bMap.Diff.Mods),
+			Timestamp:  overlay.cursor.ScoreTime,
+			ReplayData: overlay.replayFrames(),
+		})
+
+		if err != nil {
+			log.Println("Failed to save the replay:", err)
+		} else {
+			score.Replay = path
+
+			log.Println("Replay saved to:", path)
+		}
+	}
+
+	if err := database.AddScore(score); err != nil {
+		log.Println("Failed to save the score:", err)
+		return
+	}
+
+	log.Println("Score saved to local scores")
+}
+
 func (overlay *ScoreOverlay) hitReceived(c *graphics.Cursor, time int64, number int64, position vector.Vector2d, result osu.HitResult, comboResult osu.ComboResult, ppResults pp220930.PPv2Results, _ int64) {
 	object := overlay.ruleset.GetBeatMap().HitObjects[number]
 
@@ -340,6 +514,11 @@ func (overlay *ScoreOverlay) hitReceived(c *graphics.Cursor, time int64, number
 		timeDiff := float64(time) - object.GetStartTime()
 
 		overlay.hitErrorMeter.Add(float64(time), timeDiff, result == osu.PositionalMiss)
+		overlay.hitTimeline.Add(float64(time), timeDiff, result == osu.PositionalMiss)
+
+		if result != osu.PositionalMiss {
+			overlay.hitStatistics.Add(number, timeDiff)
+		}
 
 		var startPos *vector.Vector2f
 		if number > 0 {
@@ -356,6 +535,10 @@ func (overlay *ScoreOverlay) hitReceived(c *graphics.Cursor, time int64, number
 		return
 	}
 
+	if result&osu.Miss > 0 {
+		overlay.hitTimeline.AddMiss(object.GetStartTime())
+	}
+
 	if comboResult == osu.Increase {
 		overlay.comboCounter.Increase()
 	} else if comboResult == osu.Reset {
@@ -373,10 +556,22 @@ func (overlay *ScoreOverlay) hitReceived(c *graphics.Cursor, time int64, number
 	overlay.scoreGlider.SetValue(float64(sc.Score), settings.Gameplay.Score.StaticScore)
 	overlay.accuracyGlider.SetValue(sc.Accuracy, settings.Gameplay.Score.StaticAccuracy)
 
-	overlay.ppDisplay.Add(ppResults)
+	overlay.ppDisplay.Add(ppResults, sc.PPIfFC.Total)
+
+	if overlay.personalBest != nil {
+		overlay.personalBest.Add(sc.Score, sc.Accuracy)
+	}
 
 	overlay.hpSections = append(overlay.hpSections, vector.NewVec2d(float64(time), overlay.ruleset.GetHP(overlay.cursor)))
 
+	if result&osu.BaseHitsM > 0 {
+		overlay.accSections = append(overlay.accSections, vector.NewVec2d(float64(time), sc.Accuracy))
+
+		if result&(osu.Hit100|osu.Hit50|osu.Miss) > 0 {
+			overlay.judgements = append(overlay.judgements, play.Judgement{Time: float64(time), Result: result})
+		}
+	}
+
 	if overlay.oldGrade != sc.Grade {
 		goroutines.Run(func() {
 			var tex *texture.TextureRegion
@@ -414,7 +609,8 @@ func (overlay *ScoreOverlay) Update(time float64) {
 		overlay.initMods()
 	}
 
-	if input.Win.GetKey(glfw.KeySpace) == glfw.Press {
+	// Replay controls use Space for pausing, intro can be skipped by seeking there
+	if input.Win != nil && overlay.seekListener == nil && input.Win.GetKey(glfw.KeySpace) == glfw.Press {
 		if overlay.skip != nil && overlay.music != nil && overlay.music.GetState() == bass.MusicPlaying {
 			if overlay.audioTime < overlay.skipTo {
 				overlay.music.SetPosition(overlay.skipTo / 1000)
@@ -424,6 +620,7 @@ func (overlay *ScoreOverlay) Update(time float64) {
 
 	overlay.results.Update(time)
 	overlay.hitErrorMeter.Update(time)
+	overlay.hitTimeline.Update(time)
 	overlay.aimErrorMeter.Update(time)
 
 	if overlay.skip != nil {
@@ -438,11 +635,25 @@ func (overlay *ScoreOverlay) Update(time float64) {
 
 	//normal timing
 	overlay.updateNormal(overlay.normalTime)
+
+	overlay.updateVariables()
 }
 
 func (overlay *ScoreOverlay) updateNormal(time float64) {
 	overlay.updateBreaks(time)
 
+	if !overlay.statsExported && overlay.audioTime >= overlay.beatmapEnd {
+		overlay.statsExported = true
+
+		if settings.Gameplay.HitStatistics.ExportJSON {
+			overlay.exportStatistics()
+		}
+
+		if settings.PLAY && !overlay.failed && !overlay.cursor.IsAutoplay && settings.START == 0 && math.IsInf(settings.END, 1) {
+			overlay.saveScore()
+		}
+	}
+
 	if overlay.panel != nil {
 		overlay.panel.Update(time)
 	} else if !overlay.failed && settings.Gameplay.ShowResultsScreen && !overlay.created && overlay.audioTime >= overlay.beatmapEnd {
@@ -450,7 +661,11 @@ func (overlay *ScoreOverlay) updateNormal(time float64) {
 		cTime := overlay.normalTime
 
 		createPanel := func() {
-			overlay.panel = play.NewRankingPanel(overlay.cursor, overlay.ruleset, overlay.hitErrorMeter, overlay.hpSections)
+			if settings.Gameplay.ResultsScreenStyle == settings.StatisticsResults {
+				overlay.panel = play.NewStatisticsPanel(overlay.cursor, overlay.ruleset, overlay.hitErrorMeter, overlay.hitStatistics, overlay.accSections, overlay.judgements, overlay.strainGraph, overlay.keyCounters)
+			} else {
+				overlay.panel = play.NewRankingPanel(overlay.cursor, overlay.ruleset, overlay.hitErrorMeter, overlay.hitStatistics, overlay.hpSections)
+			}
 
 			s := cTime
 
@@ -625,43 +840,36 @@ func (overlay *ScoreOverlay) DrawHUD(batch *batch.QuadBatch, _ []color2.Color, a
 	batch.SetCamera(overlay.camera.GetProjectionView())
 	batch.ResetTransform()
 
-	if !settings.Gameplay.Underlay.AboveHpBar {
-		batch.SetColor(1, 1, 1, alpha)
-		overlay.underlay.Draw(0, batch)
+	if overlay.hudEditor != nil {
+		overlay.hudEditor.Update()
 	}
 
-	overlay.entry.Draw(batch, alpha)
-
-	overlay.passContainer.Draw(overlay.audioTime, batch)
+	overlay.updateTimelineSeek()
 
-	overlay.drawScore(batch, alpha)
-	overlay.comboCounter.Draw(batch, alpha)
-	overlay.hpBar.Draw(batch, alpha)
+	overlay.updateHUDVisibility(overlay.lastTime)
 
-	if settings.Gameplay.Underlay.AboveHpBar {
-		batch.ResetTransform()
+	if !settings.Gameplay.Underlay.AboveHpBar {
 		batch.SetColor(1, 1, 1, alpha)
 		overlay.underlay.Draw(0, batch)
 	}
 
-	overlay.drawKeys(batch, alpha)
-
-	batch.ResetTransform()
-	batch.SetColor(1, 1, 1, alpha)
+	for _, part := range overlay.getSortedHUDParts() {
+		overlay.drawHUDPart(batch, part, overlay.camera.GetProjectionView(), alpha)
 
-	if settings.Gameplay.Mods.Show {
-		batch.SetTranslation(vector.NewVec2d(settings.Gameplay.Mods.XOffset, settings.Gameplay.Mods.YOffset))
-		overlay.mods.Draw(overlay.lastTime, batch)
-		batch.ResetTransform()
+		if part.name == "HpBar" && settings.Gameplay.Underlay.AboveHpBar {
+			batch.SetCamera(overlay.camera.GetProjectionView())
+			batch.ResetTransform()
+			batch.SetColor(1, 1, 1, alpha)
+			overlay.underlay.Draw(0, batch)
+		}
 	}
 
-	if !overlay.failed && settings.Gameplay.ShowWarningArrows {
-		overlay.arrows.Draw(overlay.audioTime, batch)
-	}
+	batch.SetCamera(overlay.camera.GetProjectionView())
+	batch.ResetTransform()
 
-	overlay.ppDisplay.Draw(batch, alpha)
-	overlay.strainGraph.Draw(batch, alpha)
-	overlay.hitCounts.Draw(batch, alpha)
+	if overlay.hudEditor != nil {
+		overlay.hudEditor.Draw(batch, 1)
+	}
 
 	if overlay.panel != nil {
 		settings.Playfield.Bloom.Enabled = false
@@ -697,7 +905,7 @@ func (overlay *ScoreOverlay) drawScore(batch *batch.QuadBatch, alpha float64) {
 
 	batch.Flush()
 
-	overlay.shapeRenderer.SetCamera(overlay.camera.GetProjectionView())
+	overlay.shapeRenderer.SetCamera(batch.Projection)
 
 	if settings.Gameplay.Score.ProgressBar == "Pie" {
 		if progress < 0.0 {
@@ -1009,6 +1217,11 @@ func (overlay *ScoreOverlay) SetBeatmapEnd(end float64) {
 	overlay.beatmapEnd = end
 }
 
+// GetUnstableRate returns unstable rate converted to real time
+func (overlay *ScoreOverlay) GetUnstableRate() float64 {
+	return overlay.hitErrorMeter.GetUnstableRateConverted()
+}
+
 func (overlay *ScoreOverlay) ShouldDrawHUDBeforeCursor() bool {
 	return true
 }
@@ -0,0 +1,107 @@
+package overlays
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/graphics"
+	"github.com/wieku/danser-go/app/rulesets/osu"
+	"github.com/wieku/danser-go/app/rulesets/osu/performance/pp220930"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states/components/overlays/hud"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"log"
+	"math"
+	"strings"
+)
+
+// initVariables registers values available in text widgets. Beatmap metadata is set once, the rest is updated by hit listener and Update.
+func (overlay *ScoreOverlay) initVariables() {
+	overlay.variables = hud.NewVariables()
+
+	bMap := overlay.ruleset.GetBeatMap()
+
+	overlay.variables.Set("artist", bMap.Artist)
+	overlay.variables.Set("title", bMap.Name)
+	overlay.variables.Set("version", bMap.Difficulty)
+	overlay.variables.Set("mapper", bMap.Creator)
+	overlay.variables.Set("mods", bMap.Diff.GetModString())
+	overlay.variables.Set("stars", math.Max(0, bMap.Stars))
+	overlay.variables.Set("ar", bMap.Diff.GetAR())
+	overlay.variables.Set("cs", bMap.Diff.GetCS())
+	overlay.variables.Set("od", bMap.Diff.GetOD())
+	overlay.variables.Set("hp", bMap.Diff.GetHP())
+	overlay.variables.Set("player", overlay.cursor.Name)
+
+	overlay.variables.Set("score", int64(0))
+	overlay.variables.Set("acc", 100.0)
+	overlay.variables.Set("combo", 0)
+	overlay.variables.Set("maxcombo", 0)
+	overlay.variables.Set("grade", osu.NONE.String())
+	overlay.variables.Set("n300", 0)
+	overlay.variables.Set("n100", 0)
+	overlay.variables.Set("n50", 0)
+	overlay.variables.Set("misses", 0)
+	overlay.variables.Set("sliderbreaks", 0)
+	overlay.variables.Set("pp", 0.0)
+	overlay.variables.Set("ppfc", 0.0)
+
+	overlay.updateVariables()
+
+	overlay.ruleset.AddListener(overlay.updateScoreVariables)
+
+	if len(settings.Gameplay.TextWidgets) > 0 {
+		log.Println("Text widget variables:", strings.Join(overlay.variables.Names(), ", "))
+	}
+}
+
+func (overlay *ScoreOverlay) updateScoreVariables(c *graphics.Cursor, _ int64, _ int64, _ vector.Vector2d, result osu.HitResult, _ osu.ComboResult, _ pp220930.PPv2Results, _ int64) {
+	if c != overlay.cursor || result == osu.PositionalMiss {
+		return
+	}
+
+	sc := overlay.ruleset.GetScore(overlay.cursor)
+
+	overlay.maxCombo = mutils.Max(overlay.maxCombo, sc.Combo)
+
+	overlay.variables.Set("score", sc.Score)
+	overlay.variables.Set("acc", sc.Accuracy)
+	overlay.variables.Set("combo", int(sc.Combo))
+	overlay.variables.Set("maxcombo", int(overlay.maxCombo))
+	overlay.variables.Set("grade", sc.Grade.String())
+	overlay.variables.Set("n300", int(sc.Count300))
+	overlay.variables.Set("n100", int(sc.Count100))
+	overlay.variables.Set("n50", int(sc.Count50))
+	overlay.variables.Set("misses", int(sc.CountMiss))
+	overlay.variables.Set("sliderbreaks", int(sc.CountSB))
+	overlay.variables.Set("pp", sc.PP.Total)
+	overlay.variables.Set("ppfc", sc.PPIfFC.Total)
+}
+
+// updateVariables refreshes values that change over time
+func (overlay *ScoreOverlay) updateVariables() {
+	tempo := 1.0
+	length := 0.0
+
+	if overlay.music != nil {
+		tempo = overlay.music.GetTempo()
+		length = overlay.music.GetLength()
+	}
+
+	bpm := 0.0
+	if beatLen := overlay.ruleset.GetBeatMap().Timings.GetPointAt(overlay.audioTime).GetBaseBeatLength(); beatLen > 0 {
+		bpm = 60000 / beatLen * tempo
+	}
+
+	overlay.variables.Set("bpm", bpm)
+	overlay.variables.Set("ur", overlay.hitErrorMeter.GetUnstableRateConverted())
+	overlay.variables.Set("health", overlay.ruleset.GetHP(overlay.cursor)*100)
+	overlay.variables.Set("time", formatVariableTime(overlay.audioTime/1000))
+	overlay.variables.Set("length", formatVariableTime(length))
+	overlay.variables.Set("progress", math.Max(0, overlay.getProgress())*100)
+}
+
+func formatVariableTime(seconds float64) string {
+	s := int(math.Max(0, seconds))
+
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -0,0 +1,141 @@
+package overlays
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/analysis"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/dance"
+	"github.com/wieku/danser-go/framework/graphics/batch"
+	"github.com/wieku/danser-go/framework/graphics/shape"
+	color2 "github.com/wieku/danser-go/framework/math/color"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"math"
+)
+
+// Divergence in osu!pixels at which the graph is capped
+const maxGraphDivergence = 100.0
+
+// SimilarityOverlay is a knockout overlay of two replays with a graph of their cursor divergence
+type SimilarityOverlay struct {
+	*KnockoutOverlay
+
+	similarity    *analysis.Similarity
+	shapeRenderer *shape.Renderer
+
+	// Highest divergence in every column of the graph
+	graph []float64
+}
+
+func NewSimilarityOverlay(replayController *dance.ReplayController, similarity *analysis.Similarity) *SimilarityOverlay {
+	overlay := &SimilarityOverlay{
+		KnockoutOverlay: NewKnockoutOverlay(replayController),
+		similarity:      similarity,
+		shapeRenderer:   shape.NewRenderer(),
+	}
+
+	overlay.graph = make([]float64, mutils.Min(len(similarity.Divergence), 500))
+
+	for i, d := range similarity.Divergence {
+		column := i * len(overlay.graph) / len(similarity.Divergence)
+
+		overlay.graph[column] = math.Max(overlay.graph[column], math.Min(d, maxGraphDivergence))
+	}
+
+	return overlay
+}
+
+// DrawNormal also draws B's cursor shifted by the detected offset and transform, so it lies on A's cursor if the replay was copied
+func (overlay *SimilarityOverlay) DrawNormal(batch *batch.QuadBatch, colors []color2.Color, alpha float64) {
+	overlay.KnockoutOverlay.DrawNormal(batch, colors, alpha)
+
+	position, ok := overlay.similarity.GetAlignedPositionAt(overlay.audioTime)
+	if !ok {
+		return
+	}
+
+	if overlay.controller.GetBeatMap().Diff.CheckModActive(difficulty.HardRock) {
+		position.Y = 384 - position.Y
+	}
+
+	color := color2.NewL(1)
+	if len(colors) > 1 {
+		color = colors[1]
+	}
+
+	alpha *= overlay.fade.GetValue()
+
+	batch.Flush()
+
+	overlay.shapeRenderer.SetCamera(batch.Projection)
+	overlay.shapeRenderer.Begin()
+
+	if cursors := overlay.controller.GetCursors(); len(cursors) > 0 {
+		overlay.shapeRenderer.SetColor(1, 1, 1, alpha*0.3)
+		overlay.shapeRenderer.DrawLineV(cursors[0].Position, position, 1)
+	}
+
+	overlay.shapeRenderer.SetColor(float64(color.R), float64(color.G), float64(color.B), alpha*0.6)
+	overlay.shapeRenderer.DrawCircle(position, 4)
+
+	overlay.shapeRenderer.End()
+}
+
+func (overlay *SimilarityOverlay) DrawHUD(batch *batch.QuadBatch, colors []color2.Color, alpha float64) {
+	overlay.KnockoutOverlay.DrawHUD(batch, colors, alpha)
+
+	if len(overlay.graph) == 0 {
+		return
+	}
+
+	alpha *= overlay.fade.GetValue()
+
+	scl := overlay.ScaledHeight * 0.9 / 20.0
+
+	gWidth := float32(overlay.ScaledWidth * 0.6)
+	gHeight := float32(overlay.ScaledHeight * 0.08)
+	gX := float32(overlay.ScaledWidth * 0.2)
+	gY := float32(overlay.ScaledHeight) - gHeight - float32(scl)
+
+	batch.Flush()
+
+	overlay.shapeRenderer.SetCamera(batch.Projection)
+	overlay.shapeRenderer.Begin()
+
+	overlay.shapeRenderer.SetColor(0, 0, 0, alpha*0.8)
+	overlay.shapeRenderer.DrawQuad(gX, gY, gX+gWidth, gY, gX+gWidth, gY+gHeight, gX, gY+gHeight)
+
+	cWidth := gWidth / float32(len(overlay.graph))
+
+	for i, d := range overlay.graph {
+		t := d / maxGraphDivergence
+
+		overlay.shapeRenderer.SetColor(0.2+0.8*t, 1-0.8*t, 0.2, alpha)
+
+		cX := gX + float32(i)*cWidth
+		cH := float32(t) * (gHeight - 4)
+
+		overlay.shapeRenderer.DrawQuad(cX, gY+gHeight-2-cH, cX+cWidth, gY+gHeight-2-cH, cX+cWidth, gY+gHeight-2, cX, gY+gHeight-2)
+	}
+
+	similarity := overlay.similarity
+	span := float64(len(similarity.Divergence)) * similarity.DivergenceInterval
+
+	progress := float32(mutils.ClampF((overlay.audioTime-similarity.StartTime)/span, 0, 1))
+
+	overlay.shapeRenderer.SetColor(1, 1, 1, alpha)
+	overlay.shapeRenderer.DrawLine(gX+progress*gWidth, gY, gX+progress*gWidth, gY+gHeight, 2)
+
+	overlay.shapeRenderer.End()
+
+	batch.ResetTransform()
+	batch.SetColor(1, 1, 1, alpha)
+
+	divergence := "-"
+	if d := similarity.GetDivergenceAt(overlay.audioTime); !math.IsNaN(d) {
+		divergence = fmt.Sprintf("%.1fpx", d)
+	}
+
+	overlay.font.DrawOrigin(batch, float64(gX), float64(gY), vector.BottomLeft, scl*0.6, false, fmt.Sprintf("%s vs %s | Divergence: %s", similarity.PlayerA, similarity.PlayerB, divergence))
+	overlay.font.DrawOrigin(batch, float64(gX+gWidth), float64(gY), vector.BottomRight, scl*0.6, false, fmt.Sprintf("Transform: %s | Offset: %.0fms | Suspicion: %.1f", similarity.Transform, similarity.Offset, similarity.Suspicion))
+}
@@ -4,12 +4,12 @@ import (
 	"fmt"
 	"log"
 	"math"
-	"math/rand"
 	"path/filepath"
 	"runtime"
 	"strconv"
 
 	"github.com/go-gl/mathgl/mgl32"
+	"github.com/wieku/danser-go/app/analysis"
 	"github.com/wieku/danser-go/app/audio"
 	"github.com/wieku/danser-go/app/beatmap"
 	"github.com/wieku/danser-go/app/beatmap/difficulty"
@@ -39,16 +39,21 @@ import (
 	"github.com/wieku/danser-go/framework/math/vector"
 	"github.com/wieku/danser-go/framework/qpc"
 	"github.com/wieku/danser-go/framework/statistic"
+	"github.com/wieku/danser-go/framework/util"
 )
 
 const windowsOffset = 15
 
+// Similarity of replays compared by -similarity, it's shown instead of knockout overlay when settings.SIMILARITY is set
+var Similarity *analysis.Similarity
+
 type Player struct {
 	font        *font.Font
 	bMap        *beatmap.BeatMap
 	bloomEffect *effects.BloomEffect
 
 	lastTime        int64
+	drawnFrames     int64
 	lastMusicPos    float64
 	lastProgressMsF float64
 	progressMsF     float64
@@ -124,6 +129,21 @@ type Player struct {
 	failing bool
 	failAt  float64
 	failed  bool
+
+	practice *practice
+
+	// Objects ending after it don't play hitsounds, set when -end is used
+	audioEnd   float64
+	overlayEnd float64
+
+	// Time range of objects kept by -start and -end, objects parsed again are trimmed to it
+	objectsStart float64
+	objectsEnd   float64
+
+	seekRequested bool
+	seekTime      float64
+
+	replayControls *replayControls
 }
 
 func NewPlayer(beatMap *beatmap.BeatMap) *Player {
@@ -166,37 +186,16 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 
 	settings.START = math.Min(settings.START, (beatMap.HitObjects[len(beatMap.HitObjects)-1].GetStartTime()-1)/1000) // cap start to start time of the last HitObject - 1ms
 
-	if (settings.START > 0.01 || !math.IsInf(settings.END, 1)) && (settings.PLAY || !settings.KNOCKOUT) {
-		scrub := math.Max(0, settings.START*1000)
-		end := settings.END * 1000
-
-		removed := false
-
-		for i := 0; i < len(beatMap.HitObjects); i++ {
-			o := beatMap.HitObjects[i]
-			if o.GetStartTime() > scrub && end > o.GetEndTime() {
-				continue
-			}
-
-			beatMap.HitObjects = append(beatMap.HitObjects[:i], beatMap.HitObjects[i+1:]...)
-			i--
-
-			removed = true
-		}
+	if settings.PLAY && settings.PRACTICE {
+		player.practice = newPractice(beatMap, settings.START*1000, settings.END*1000)
+	}
 
-		for i := 0; i < len(beatMap.HitObjects); i++ {
-			beatMap.HitObjects[i].SetID(int64(i))
-		}
+	player.objectsStart, player.objectsEnd = -1, math.Inf(1)
 
-		for i := 0; i < len(beatMap.Pauses); i++ {
-			o := beatMap.Pauses[i]
-			if o.GetStartTime() > scrub && end > o.GetEndTime() {
-				continue
-			}
+	if (settings.START > 0.01 || !math.IsInf(settings.END, 1)) && (settings.PLAY || !settings.KNOCKOUT) {
+		player.objectsStart, player.objectsEnd = math.Max(0, settings.START*1000), settings.END*1000
 
-			beatMap.Pauses = append(beatMap.Pauses[:i], beatMap.Pauses[i+1:]...)
-			i--
-		}
+		removed := trimObjects(beatMap, player.objectsStart, player.objectsEnd)
 
 		if removed && settings.START > 0.01 {
 			settings.START = 0
@@ -238,11 +237,16 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 	player.bMap.Reset()
 
 	if settings.PLAY {
-		player.controller = dance.NewPlayerController()
+		controller := dance.NewPlayerController().(*dance.PlayerController)
+		player.controller = controller
 
 		player.controller.SetBeatMap(player.bMap)
 		player.controller.InitCursors()
-		player.overlay = overlays.NewScoreOverlay(player.controller.(*dance.PlayerController).GetRuleset(), player.controller.GetCursors()[0])
+
+		scoreOverlay := overlays.NewScoreOverlay(controller.GetRuleset(), controller.GetCursors()[0])
+		scoreOverlay.SetReplayFrames(controller.GetReplayFrames)
+
+		player.overlay = scoreOverlay
 	} else if settings.KNOCKOUT {
 		controller := dance.NewReplayController()
 		player.controller = controller
@@ -250,7 +254,13 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 		player.controller.SetBeatMap(player.bMap)
 		player.controller.InitCursors()
 
-		if settings.PLAYERS == 1 {
+		if settings.SIMILARITY {
+			if settings.PLAYERS != 2 || Similarity == nil {
+				panic(fmt.Sprintf("Similarity overlay needs both compared replays, %d could be loaded", settings.PLAYERS))
+			}
+
+			player.overlay = overlays.NewSimilarityOverlay(controller.(*dance.ReplayController), Similarity)
+		} else if settings.PLAYERS == 1 {
 			player.overlay = overlays.NewScoreOverlay(player.controller.(*dance.ReplayController).GetRuleset(), player.controller.GetCursors()[0])
 		} else {
 			player.overlay = overlays.NewKnockoutOverlay(controller.(*dance.ReplayController))
@@ -295,6 +305,14 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 
 	player.trySetupFail()
 
+	if player.practice != nil {
+		player.setupPractice()
+	}
+
+	if !settings.PLAY && !settings.RECORD {
+		player.setupReplayControls()
+	}
+
 	preempt := math.Min(1800, beatMap.Diff.Preempt)
 
 	skipTime := 0.0
@@ -312,6 +330,11 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 		beatmapEnd = math.Min(end, beatMap.HitObjects[len(beatMap.HitObjects)-1].GetEndTime()) + float64(beatMap.Diff.Hit50)
 	}
 
+	if player.practice != nil {
+		// Section can be extended live, so the map ends only at its last object
+		beatmapEnd = player.practice.mapEnd + float64(beatMap.Diff.Hit50)
+	}
+
 	startOffset := 0.0
 
 	if math.Max(0, skipTime) > 0.01 {
@@ -374,20 +397,28 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 
 	fadeOut := settings.Playfield.FadeOutTime * 1000
 
-	if s, ok := player.overlay.(*overlays.ScoreOverlay); ok {
-		if settings.Gameplay.ShowResultsScreen {
-			beatmapEnd += 1000
-			fadeOut = 250
-		}
+	if player.hasResultsScreen() {
+		beatmapEnd += 1000
+		fadeOut = 250
+	}
+
+	player.overlayEnd = beatmapEnd + 3000 + fadeOut
 
-		s.SetBeatmapEnd(beatmapEnd + 3000 + fadeOut)
+	if s, ok := player.overlay.(*overlays.ScoreOverlay); ok {
+		s.SetBeatmapEnd(player.overlayEnd)
+	} else if k, ok := player.overlay.(overlays.Knockout); ok {
+		k.SetBeatmapEnd(player.overlayEnd)
 	}
 
 	beatmapEnd += 5000
 
-	if !math.IsInf(settings.END, 1) {
+	player.audioEnd = math.Inf(1)
+
+	if !math.IsInf(settings.END, 1) && player.practice == nil {
+		player.audioEnd = beatmapEnd
+
 		for _, o := range beatMap.HitObjects {
-			if o.GetEndTime() <= beatmapEnd {
+			if o.GetEndTime() <= player.audioEnd {
 				continue
 			}
 
@@ -407,7 +438,7 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 	player.mapEndL = beatmapEnd + fadeOut
 	player.MapEnd = beatmapEnd + fadeOut
 
-	if _, ok := player.overlay.(*overlays.ScoreOverlay); ok && settings.Gameplay.ShowResultsScreen {
+	if player.hasResultsScreen() {
 		player.speedGlider.AddEvent(beatmapEnd+fadeOut, beatmapEnd+fadeOut, 1)
 		player.pitchGlider.AddEvent(beatmapEnd+fadeOut, beatmapEnd+fadeOut, 1)
 
@@ -525,17 +556,7 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 				}
 			}
 
-			platformOffset := 0.0
-			if runtime.GOOS == "windows" { // For some reason WASAPI reports time with 15ms delay, so we need to correct it
-				platformOffset = windowsOffset
-			}
-
-			oldOffset := 0.0
-			if player.bMap.Version < 5 {
-				oldOffset = -24
-			}
-
-			player.progressMsF = player.rawPositionF + (platformOffset+float64(settings.Audio.Offset)+float64(settings.LOCALOFFSET))*speed + oldOffset
+			player.progressMsF = player.rawPositionF + player.getAudioOffset(speed)
 
 			player.updateMain(delta)
 
@@ -551,6 +572,51 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 	return player
 }
 
+// trimObjects removes objects and breaks outside given time range, returns true if any object was removed
+func trimObjects(beatMap *beatmap.BeatMap, start, end float64) bool {
+	removed := false
+
+	for i := 0; i < len(beatMap.HitObjects); i++ {
+		o := beatMap.HitObjects[i]
+		if o.GetStartTime() > start && end > o.GetEndTime() {
+			continue
+		}
+
+		beatMap.HitObjects = append(beatMap.HitObjects[:i], beatMap.HitObjects[i+1:]...)
+		i--
+
+		removed = true
+	}
+
+	for i := 0; i < len(beatMap.HitObjects); i++ {
+		beatMap.HitObjects[i].SetID(int64(i))
+	}
+
+	for i := 0; i < len(beatMap.Pauses); i++ {
+		o := beatMap.Pauses[i]
+		if o.GetStartTime() > start && end > o.GetEndTime() {
+			continue
+		}
+
+		beatMap.Pauses = append(beatMap.Pauses[:i], beatMap.Pauses[i+1:]...)
+		i--
+	}
+
+	return removed
+}
+
+// hasResultsScreen returns true if the overlay shows results after the map ends, which extends the map
+func (player *Player) hasResultsScreen() bool {
+	switch o := player.overlay.(type) {
+	case *overlays.ScoreOverlay:
+		return settings.Gameplay.ShowResultsScreen
+	case overlays.Knockout:
+		return o.ShowsPodium()
+	}
+
+	return false
+}
+
 func (player *Player) trySetupFail() {
 	if sO, ok := player.overlay.(*overlays.ScoreOverlay); ok {
 		var ruleset *osu.OsuRuleSet
@@ -574,10 +640,10 @@ func (player *Player) trySetupFail() {
 				player.frequencyGlider.AddEvent(player.realTime, player.realTime+2400, 0.0)
 				player.objectsAlphaFail.AddEvent(player.realTime, player.realTime+2400, 0.0)
 
-				player.failOX.AddEvent(player.realTime, player.realTime+2400, camera2.OsuWidth*(rand.Float64()-0.5)/2)
-				player.failOY.AddEvent(player.realTime, player.realTime+2400, -camera2.OsuHeight*(1+rand.Float64()*0.2))
+				player.failOX.AddEvent(player.realTime, player.realTime+2400, camera2.OsuWidth*(util.RandomFloat64()-0.5)/2)
+				player.failOY.AddEvent(player.realTime, player.realTime+2400, -camera2.OsuHeight*(1+util.RandomFloat64()*0.2))
 
-				rotBase := rand.Float64()
+				rotBase := util.RandomFloat64()
 
 				player.failRotation.AddEvent(player.realTime, player.realTime+2400, math.Copysign((math.Abs(rotBase)*0.5+0.5)/6*math.Pi, rotBase))
 
@@ -599,6 +665,21 @@ func (player *Player) trySetupFail() {
 	}
 }
 
+// getAudioOffset returns the difference between map time and music position in ms when music plays at given speed
+func (player *Player) getAudioOffset(speed float64) float64 {
+	platformOffset := 0.0
+	if runtime.GOOS == "windows" { // For some reason WASAPI reports time with 15ms delay, so we need to correct it
+		platformOffset = windowsOffset
+	}
+
+	oldOffset := 0.0
+	if player.bMap.Version < 5 {
+		oldOffset = -24
+	}
+
+	return (platformOffset+float64(settings.Audio.Offset)+float64(settings.LOCALOFFSET))*speed + oldOffset
+}
+
 func (player *Player) Update(delta float64) bool {
 	speed := 1.0
 
@@ -637,9 +718,35 @@ func (player *Player) GetTimeOffset() float64 {
 	return player.progressMsF - player.startOffset
 }
 
+// AlignFrames starts the player up to one frame earlier, so that after the intro, frames rendered every frameTime ms of real time
+// are shown at multiples of frameTime*SPEED in map time. Returns the index of the frame shown at map time 0, so frames of
+// players started at different times can be matched.
+func (player *Player) AlignFrames(frameTime float64) int64 {
+	// Map time advances at normal speed during intro
+	intro := player.startPointE - player.startOffset
+
+	frames := math.Floor((player.startPointE/settings.SPEED - intro) / frameTime)
+	shift := player.startPointE/settings.SPEED - intro - frames*frameTime
+
+	player.startOffset -= shift
+	player.rawPositionF -= shift
+	player.progressMsF -= shift
+	player.RunningTime += shift
+
+	return -int64(frames)
+}
+
 func (player *Player) updateMain(delta float64) {
 	player.realTime += delta
 
+	if player.practice != nil {
+		player.updatePractice()
+	}
+
+	if player.replayControls != nil {
+		player.updateReplayControls()
+	}
+
 	if player.rawPositionF >= player.startPoint && !player.start {
 		player.musicPlayer.Play()
 
@@ -761,11 +868,15 @@ func (player *Player) updateMusic(delta float64) {
 }
 
 func (player *Player) Draw(float64) {
+	if settings.RECORD {
+		player.drawnFrames++
+	}
+
 	if player.lastTime <= 0 {
-		player.lastTime = qpc.GetNanoTime()
+		player.lastTime = player.getDrawTime()
 	}
 
-	tim := qpc.GetNanoTime()
+	tim := player.getDrawTime()
 	timMs := float64(tim-player.lastTime) / 1000000.0
 
 	fps := player.profiler.GetFPS()
@@ -793,7 +904,9 @@ func (player *Player) Draw(float64) {
 		bgAlpha = mutils.ClampF(bgAlpha*player.Scl, 0, 1)
 	}
 
-	player.background.Draw(player.progressMsF, player.batch, player.blurGlider.GetValue(), bgAlpha, player.bgCamera.GetProjectionView())
+	if !settings.TRANSPARENT {
+		player.background.Draw(player.progressMsF, player.batch, player.blurGlider.GetValue(), bgAlpha, player.bgCamera.GetProjectionView())
+	}
 
 	if player.progressMsF > 0 {
 		timeDiff := player.progressMsF - player.lastProgressMsF
@@ -803,6 +916,10 @@ func (player *Player) Draw(float64) {
 
 	cursorColors := settings.Cursor.GetColors(settings.DIVIDES, len(player.controller.GetCursors()), player.Scl, player.cursorGlider.GetValue())
 
+	if kO, ok := player.overlay.(overlays.Knockout); ok {
+		kO.ApplyTeamColors(cursorColors)
+	}
+
 	if player.overlay != nil {
 		player.drawOverlayPart(player.overlay.DrawBackground, cursorColors, cursorCameras[0], 1)
 	}
@@ -850,7 +967,9 @@ func (player *Player) Draw(float64) {
 		player.drawOverlayPart(player.overlay.DrawNormal, cursorColors, objectCameras[0], 1)
 	}
 
-	player.background.DrawOverlay(player.progressMsF, player.batch, bgAlpha, player.bgCamera.GetProjectionView())
+	if !settings.TRANSPARENT {
+		player.background.DrawOverlay(player.progressMsF, player.batch, bgAlpha, player.bgCamera.GetProjectionView())
+	}
 
 	if player.overlay != nil && player.overlay.ShouldDrawHUDBeforeCursor() {
 		player.drawOverlayPart(player.overlay.DrawHUD, cursorColors, player.uiCamera.GetProjectionView(), 1)
@@ -900,9 +1019,26 @@ func (player *Player) Draw(float64) {
 		player.bloomEffect.EndAndRender()
 	}
 
+	player.drawPractice()
+	player.drawReplayControls()
 	player.drawDebug()
 }
 
+// getDrawTime returns time of the frame in nanoseconds, offscreen every frame advances it by the length of a recorded frame, so rendered frames don't depend on rendering speed
+func (player *Player) getDrawTime() int64 {
+	if settings.RECORD {
+		fps := float64(settings.Recording.FPS)
+
+		if settings.Recording.MotionBlur.Enabled {
+			fps *= float64(settings.Recording.MotionBlur.OversampleMultiplier)
+		}
+
+		return int64(float64(player.drawnFrames) * 1000000000 / fps)
+	}
+
+	return qpc.GetNanoTime()
+}
+
 func (player *Player) drawEpilepsyWarning() {
 	if player.epiGlider.GetValue() < 0.01 {
 		return
@@ -1079,8 +1215,50 @@ func (player *Player) drawDebug() {
 	}
 }
 
+// GetRecordingTime converts map time to time elapsed since the start of recording, both in milliseconds
+func (player *Player) GetRecordingTime(time float64) float64 {
+	if time < player.startPointE { // Lead-in is played at normal speed
+		return time - player.startOffset
+	}
+
+	return player.startPointE - player.startOffset + (time-player.startPointE)/settings.SPEED
+}
+
+func (player *Player) GetBeatMap() *beatmap.BeatMap {
+	return player.bMap
+}
+
+func (player *Player) GetController() dance.Controller {
+	return player.controller
+}
+
+func (player *Player) GetOverlay() overlays.Overlay {
+	return player.overlay
+}
+
+// IsEditingHUD returns true if HUD editor is open, its keys and clicks shouldn't control anything else then
+func (player *Player) IsEditingHUD() bool {
+	sO, ok := player.overlay.(*overlays.ScoreOverlay)
+
+	return ok && sO.IsEditingHUD()
+}
+
 func (player *Player) Show() {}
 
 func (player *Player) Hide() {}
 
-func (player *Player) Dispose() {}
+func (player *Player) Dispose() {
+	player.musicPlayer.Stop()
+
+	if sO, ok := player.overlay.(*overlays.ScoreOverlay); ok {
+		sO.Dispose()
+	}
+
+	if player.replayControls != nil {
+		input.UnregisterListener(player.replayControls.keyListener)
+	}
+
+	if player.practice != nil {
+		input.UnregisterListener(player.practice.keyListener)
+	}
+}
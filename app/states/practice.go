@@ -0,0 +1,334 @@
+package states
+
+import (
+	"fmt"
+	"github.com/faiface/mainthread"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/dance"
+	"github.com/wieku/danser-go/app/graphics"
+	"github.com/wieku/danser-go/app/input"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states/components/containers"
+	"github.com/wieku/danser-go/app/states/components/overlays"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"github.com/wieku/danser-go/framework/platform"
+	"log"
+	"math"
+	"strings"
+	"sync"
+)
+
+// Time in ms after the last object's hit window at which the next attempt starts
+const practiceEndDelay = 750.0
+
+type loopKey int
+
+const (
+	loopStartKey loopKey = iota
+	loopEndKey
+)
+
+type attempt struct {
+	number   int
+	rate     float64
+	accuracy float64
+	ur       float64
+	misses   uint
+	failed   bool
+}
+
+type practice struct {
+	// Requested section bounds in ms of map time, changes are applied on the next attempt
+	start float64
+	end   float64
+
+	// Bounds of the section played in current attempt
+	sectionStart float64
+	sectionEnd   float64
+
+	// End time of the last object of the whole map
+	mapEnd float64
+
+	pauses []*beatmap.Pause
+
+	rate            float64
+	baseSpeed       float64
+	baseCustomSpeed float64
+
+	failed  bool
+	restart bool
+	aborted bool
+
+	attempts []attempt
+
+	keyListener int
+
+	// Loop keys are pressed on the main thread, they are applied in the next update
+	keyLock     sync.Mutex
+	pendingKeys []loopKey
+}
+
+func newPractice(beatMap *beatmap.BeatMap, start, end float64) *practice {
+	return &practice{
+		start:           math.Max(0, start),
+		end:             end,
+		mapEnd:          beatMap.HitObjects[len(beatMap.HitObjects)-1].GetEndTime(),
+		pauses:          append([]*beatmap.Pause(nil), beatMap.Pauses...),
+		rate:            1,
+		baseSpeed:       settings.SPEED,
+		baseCustomSpeed: beatMap.Diff.CustomSpeed,
+	}
+}
+
+func (player *Player) setupPractice() {
+	player.practice.updateSection(player.bMap)
+
+	player.setupPracticeFail()
+
+	player.practice.keyListener = input.RegisterListener(player.practiceKeyEvent)
+
+	log.Println(fmt.Sprintf("Practice: looping section %s - %s", formatPracticeTime(player.practice.sectionStart), formatPracticeTime(player.practice.sectionEnd)))
+}
+
+func (practice *practice) updateSection(beatMap *beatmap.BeatMap) {
+	practice.sectionStart = beatMap.HitObjects[0].GetStartTime()
+	practice.sectionEnd = beatMap.HitObjects[len(beatMap.HitObjects)-1].GetEndTime()
+}
+
+func (player *Player) setupPracticeFail() {
+	ruleset := player.controller.(*dance.PlayerController).GetRuleset()
+
+	ruleset.SetFailListener(func(_ *graphics.Cursor) {
+		player.practice.failed = true
+
+		if settings.Gameplay.Practice.RestartOnFail {
+			player.practice.restart = true
+		}
+	})
+}
+
+func (player *Player) practiceKeyEvent(_ *glfw.Window, key glfw.Key, scancode int, action glfw.Action, _ glfw.ModifierKey) {
+	if action != glfw.Press {
+		return
+	}
+
+	kName, ok := platform.GetKeyName(key, scancode)
+	if !ok {
+		return
+	}
+
+	var lKey loopKey
+
+	if strings.EqualFold(kName, settings.Input.LoopStartKey) {
+		lKey = loopStartKey
+	} else if strings.EqualFold(kName, settings.Input.LoopEndKey) {
+		lKey = loopEndKey
+	} else {
+		return
+	}
+
+	player.practice.keyLock.Lock()
+	player.practice.pendingKeys = append(player.practice.pendingKeys, lKey)
+	player.practice.keyLock.Unlock()
+}
+
+func (player *Player) updatePractice() {
+	practice := player.practice
+
+	practice.keyLock.Lock()
+	keys := practice.pendingKeys
+	practice.pendingKeys = nil
+	practice.keyLock.Unlock()
+
+	for _, key := range keys {
+		player.applyLoopKey(key)
+	}
+
+	loopEnd := practice.sectionEnd + float64(player.bMap.Diff.Hit50) + practiceEndDelay
+
+	if practice.restart || player.progressMsF >= loopEnd {
+		player.restartPractice()
+	}
+}
+
+// applyLoopKey moves requested section bounds to the current time
+func (player *Player) applyLoopKey(key loopKey) {
+	practice := player.practice
+
+	time := player.progressMsF
+
+	switch key {
+	case loopStartKey:
+		practice.start = time
+
+		if practice.end <= time {
+			practice.end = math.Inf(1)
+		}
+
+		log.Println("Practice: loop start set to", formatPracticeTime(time))
+	case loopEndKey:
+		if time <= practice.start {
+			log.Println("Practice: loop end has to be after loop start, ignoring")
+			return
+		}
+
+		practice.end = time
+
+		log.Println("Practice: loop end set to", formatPracticeTime(time))
+
+		// Start the shortened section right away, unfinished attempt is not recorded
+		practice.aborted = true
+		practice.restart = true
+	}
+}
+
+func (player *Player) restartPractice() {
+	practice := player.practice
+
+	controller := player.controller.(*dance.PlayerController)
+	cursor := controller.GetCursors()[0]
+
+	if !practice.aborted {
+		score := controller.GetRuleset().GetScore(cursor)
+
+		a := attempt{
+			number:   len(practice.attempts) + 1,
+			rate:     practice.rate,
+			accuracy: score.Accuracy,
+			misses:   score.CountMiss,
+			failed:   practice.failed,
+		}
+
+		if sO, ok := player.overlay.(*overlays.ScoreOverlay); ok {
+			a.ur = sO.GetUnstableRate()
+		}
+
+		practice.attempts = append(practice.attempts, a)
+
+		log.Println(fmt.Sprintf("Practice: attempt %d at %.2fx: %.2f%%, %.2f UR, %d misses", a.number, a.rate, a.accuracy, a.ur, a.misses))
+
+		if step := settings.Gameplay.Practice.RateStep; step > 0 {
+			if !a.failed && a.accuracy >= settings.Gameplay.Practice.TargetAccuracy {
+				practice.rate += step
+			} else {
+				practice.rate -= step
+			}
+
+			practice.rate = mutils.ClampF(practice.rate, settings.Gameplay.Practice.MinRate, settings.Gameplay.Practice.MaxRate)
+		}
+	}
+
+	practice.failed = false
+	practice.restart = false
+	practice.aborted = false
+
+	settings.SPEED = practice.baseSpeed * practice.rate
+
+	mainthread.Call(func() {
+		for _, o := range player.bMap.HitObjects {
+			o.Finalize()
+		}
+
+		player.bMap.Diff.SetCustomSpeed(practice.baseCustomSpeed * practice.rate)
+
+		player.bMap.HitObjects = nil
+		beatmap.ParseObjects(player.bMap, false, false)
+
+		fullObjects := player.bMap.GetObjectsCopy()
+
+		player.bMap.Pauses = append([]*beatmap.Pause(nil), practice.pauses...)
+
+		trimObjects(player.bMap, practice.start, practice.end)
+
+		if len(player.bMap.HitObjects) == 0 {
+			log.Println("Practice: no objects in selected section, looping the whole map")
+
+			practice.start = 0
+			practice.end = math.Inf(1)
+
+			player.bMap.HitObjects = fullObjects
+			player.bMap.Pauses = append([]*beatmap.Pause(nil), practice.pauses...)
+
+			trimObjects(player.bMap, -1, math.Inf(1))
+		}
+
+		practice.updateSection(player.bMap)
+
+		player.bMap.Reset()
+
+		player.objectContainer = containers.NewHitObjectContainer(player.bMap)
+
+		controller.Reset()
+
+		player.setupPracticeFail()
+
+		if sO, ok := player.overlay.(*overlays.ScoreOverlay); ok {
+			sO.Reset(controller.GetRuleset(), cursor)
+		}
+	})
+
+	player.speedGlider.Reset()
+	player.speedGlider.SetValue(settings.SPEED)
+
+	seekTime := practice.sectionStart - math.Min(1800, player.bMap.Diff.Preempt) - settings.Gameplay.Practice.LeadIn*1000
+
+	player.musicPlayer.SetPosition(math.Max(0, seekTime) / 1000)
+
+	player.rawPositionF = math.Max(0, seekTime)
+	player.progressMsF = player.rawPositionF
+}
+
+func (player *Player) drawPractice() {
+	if player.practice == nil || !settings.Gameplay.Practice.ShowAttempts || len(player.practice.attempts) == 0 {
+		return
+	}
+
+	size := 16.0
+
+	lines := []string{fmt.Sprintf("%-3s %6s %8s %7s %5s", "#", "Rate", "Acc", "UR", "Miss")}
+
+	attempts := player.practice.attempts
+	attempts = attempts[mutils.Max(0, len(attempts)-settings.Gameplay.Practice.AttemptsShown):]
+
+	for _, a := range attempts {
+		line := fmt.Sprintf("%-3d %5.2fx %7.2f%% %7.2f %5d", a.number, a.rate, a.accuracy, a.ur, a.misses)
+
+		if a.failed {
+			line += " F"
+		}
+
+		lines = append(lines, line)
+	}
+
+	player.batch.Begin()
+	player.batch.ResetTransform()
+	player.batch.SetCamera(player.uiCamera.GetProjectionView())
+
+	pY := (player.ScaledHeight - float64(len(lines))*size*1.2) / 2
+
+	for i, line := range lines {
+		y := pY + float64(i)*size*1.2
+
+		player.batch.SetColor(0, 0, 0, 1)
+		player.font.DrawOrigin(player.batch, size*0.5+size*0.1, y+size*0.1, vector.TopLeft, size, true, line)
+
+		player.batch.SetColor(1, 1, 1, 1)
+		player.font.DrawOrigin(player.batch, size*0.5, y, vector.TopLeft, size, true, line)
+	}
+
+	player.batch.End()
+	player.batch.ResetTransform()
+	player.batch.SetColor(1, 1, 1, 1)
+}
+
+func formatPracticeTime(time float64) string {
+	if math.IsInf(time, 1) {
+		return "end"
+	}
+
+	t := int(math.Max(0, time))
+
+	return fmt.Sprintf("%02d:%02d.%03d", t/60000, t/1000%60, t%1000)
+}
@@ -0,0 +1,222 @@
+package states
+
+import (
+	"fmt"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/wieku/danser-go/app/dance"
+	"github.com/wieku/danser-go/app/graphics"
+	"github.com/wieku/danser-go/app/input"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states/components/overlays"
+	"github.com/wieku/danser-go/framework/math/animation"
+	color2 "github.com/wieku/danser-go/framework/math/color"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"github.com/wieku/danser-go/framework/qpc"
+	"log"
+	"math"
+)
+
+const (
+	replaySeekStep  = 5000.0
+	replayFrameStep = 1000.0 / 60
+	replaySpeedStep = 0.25
+	replayMinSpeed  = 0.25
+	replayMaxSpeed  = 2.0
+
+	// Time in ms for which controls stay visible after being used
+	replayControlsShowTime = 2000.0
+
+	replayBarHeight = 8.0
+
+	// Controls are shown when the mouse is that close to the bottom of the screen, clicks seek only near the bar
+	replayBarHover = 60.0
+	replayBarClick = 20.0
+)
+
+// replayControls lets single replays be paused, seeked, stepped frame by frame and slowed down or sped up:
+// Space pauses, Left/Right seek by 5s, Comma/Period step one frame back/forward, Up/Down change speed.
+// Progress bar at the bottom of the screen seeks to the clicked time.
+type replayControls struct {
+	// Speed relative to -speed, applied to music tempo so hitsounds keep their pitch
+	speed float64
+
+	paused bool
+
+	togglePause  bool
+	speedChanged bool
+
+	lastAction   float64
+	mousePressed bool
+
+	visible bool
+	fade    *animation.Glider
+
+	keyListener int
+}
+
+func (player *Player) setupReplayControls() {
+	if _, ok := player.controller.(*dance.ReplayController); !ok {
+		return
+	}
+
+	if _, ok := player.overlay.(*overlays.ScoreOverlay); !ok {
+		return
+	}
+
+	player.replayControls = &replayControls{
+		speed:      1,
+		lastAction: math.Inf(-1),
+		fade:       animation.NewGlider(0),
+	}
+
+	player.setSeekListener()
+
+	player.replayControls.keyListener = input.RegisterListener(player.replayKeyEvent)
+
+	log.Println("Replay controls: Space - pause, Left/Right - seek by 5s, Comma/Period - step one frame, Up/Down - change speed")
+}
+
+func (player *Player) replayKeyEvent(_ *glfw.Window, key glfw.Key, _ int, action glfw.Action, _ glfw.ModifierKey) {
+	if (action != glfw.Press && action != glfw.Repeat) || player.IsEditingHUD() {
+		return
+	}
+
+	controls := player.replayControls
+
+	switch key {
+	case glfw.KeySpace:
+		if action == glfw.Press {
+			controls.togglePause = true
+		}
+	case glfw.KeyLeft:
+		player.seekBy(-replaySeekStep)
+	case glfw.KeyRight:
+		player.seekBy(replaySeekStep)
+	case glfw.KeyComma:
+		if controls.paused {
+			player.seekBy(-replayFrameStep)
+		}
+	case glfw.KeyPeriod:
+		if controls.paused {
+			player.seekBy(replayFrameStep)
+		}
+	case glfw.KeyUp:
+		controls.speed = mutils.ClampF(controls.speed+replaySpeedStep, replayMinSpeed, replayMaxSpeed)
+		controls.speedChanged = true
+	case glfw.KeyDown:
+		controls.speed = mutils.ClampF(controls.speed-replaySpeedStep, replayMinSpeed, replayMaxSpeed)
+		controls.speedChanged = true
+	default:
+		return
+	}
+
+	controls.lastAction = qpc.GetMilliTimeF()
+}
+
+// updateReplayControls applies requested changes on the update thread
+func (player *Player) updateReplayControls() {
+	controls := player.replayControls
+
+	if controls.togglePause {
+		controls.togglePause = false
+
+		if player.start && !player.failing {
+			controls.paused = !controls.paused
+
+			if controls.paused {
+				player.musicPlayer.Pause()
+			} else {
+				player.musicPlayer.Resume()
+			}
+		}
+	}
+
+	if controls.speedChanged {
+		controls.speedChanged = false
+
+		// Map end events stay queued, so results screen still plays at normal speed
+		player.speedGlider.SetValue(settings.SPEED * controls.speed)
+	}
+
+	player.updateSeek()
+}
+
+// drawReplayControls draws the progress bar and handles clicks on it, it has to be called on the main thread
+func (player *Player) drawReplayControls() {
+	controls := player.replayControls
+
+	if controls == nil || input.Win == nil {
+		return
+	}
+
+	time := qpc.GetMilliTimeF()
+
+	wWidth, wHeight := input.Win.GetSize()
+	x, y := input.Win.GetCursorPos()
+
+	mouse := vector.NewVec2d(x*player.ScaledWidth/float64(mutils.Max(wWidth, 1)), y*player.ScaledHeight/float64(mutils.Max(wHeight, 1)))
+
+	hovered := mouse.Y >= player.ScaledHeight-replayBarHover
+
+	pressed := input.Win.GetMouseButton(glfw.MouseButtonLeft) == glfw.Press
+
+	if pressed && !controls.mousePressed && mouse.Y >= player.ScaledHeight-replayBarClick && !player.IsEditingHUD() {
+		player.requestSeek(mouse.X / player.ScaledWidth * player.mapEndL)
+
+		controls.lastAction = time
+	}
+
+	controls.mousePressed = pressed
+
+	visible := controls.paused || hovered || time-controls.lastAction < replayControlsShowTime
+
+	if visible != controls.visible {
+		controls.visible = visible
+
+		target := 0.0
+		if visible {
+			target = 1.0
+		}
+
+		controls.fade.AddEvent(time, time+200, target)
+	}
+
+	controls.fade.Update(time)
+
+	alpha := controls.fade.GetValue()
+	if alpha < 0.001 {
+		return
+	}
+
+	progress := mutils.ClampF(player.progressMsF/player.mapEndL, 0, 1)
+
+	player.batch.Begin()
+	player.batch.ResetTransform()
+	player.batch.SetCamera(player.uiCamera.GetProjectionView())
+	player.batch.SetColor(1, 1, 1, alpha)
+
+	pixel := graphics.Pixel.GetRegion()
+
+	barTop := player.ScaledHeight - replayBarHeight
+
+	player.batch.DrawStObject(vector.NewVec2d(0, barTop), vector.TopLeft, vector.NewVec2d(player.ScaledWidth, replayBarHeight), false, false, 0, color2.NewLA(0, 0.6), false, pixel)
+	player.batch.DrawStObject(vector.NewVec2d(0, barTop), vector.TopLeft, vector.NewVec2d(player.ScaledWidth*progress, replayBarHeight), false, false, 0, color2.NewL(1), false, pixel)
+
+	status := fmt.Sprintf("%s / %s  %.2fx", formatPracticeTime(player.progressMsF), formatPracticeTime(player.mapEndL), controls.speed)
+	if controls.paused {
+		status += "  Paused"
+	}
+
+	size := 20.0
+
+	player.batch.SetColor(0, 0, 0, alpha)
+	player.font.DrawOrigin(player.batch, size*0.5+size*0.1, barTop-size*0.5+size*0.1, vector.BottomLeft, size, true, status)
+
+	player.batch.SetColor(1, 1, 1, alpha)
+	player.font.DrawOrigin(player.batch, size*0.5, barTop-size*0.5, vector.BottomLeft, size, true, status)
+
+	player.batch.End()
+	player.batch.ResetTransform()
+	player.batch.SetColor(1, 1, 1, 1)
+}
@@ -0,0 +1,130 @@
+package states
+
+import (
+	"github.com/faiface/mainthread"
+	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/dance"
+	"github.com/wieku/danser-go/app/states/components/containers"
+	"github.com/wieku/danser-go/app/states/components/overlays"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"log"
+	"math"
+)
+
+// setSeekListener lets the replay be seeked by clicking hit error timeline
+func (player *Player) setSeekListener() {
+	if sO, ok := player.overlay.(*overlays.ScoreOverlay); ok {
+		sO.SetSeekListener(player.requestSeek)
+	}
+}
+
+// requestSeek queues seeking to the given time, it's done in the next update as it can be requested from the main thread
+func (player *Player) requestSeek(time float64) {
+	if !player.start || player.failing {
+		return
+	}
+
+	player.seekTime = time
+	player.seekRequested = true
+}
+
+// seekBy seeks relative to the current time, or to the already requested time, so repeated seeks add up
+func (player *Player) seekBy(offset float64) {
+	base := player.progressMsF
+	if player.seekRequested {
+		base = player.seekTime
+	}
+
+	player.requestSeek(base + offset)
+}
+
+func (player *Player) updateSeek() {
+	if !player.seekRequested {
+		return
+	}
+
+	player.seekRequested = false
+
+	player.seek(player.seekTime)
+}
+
+// seek moves replay playback to the given time. Seeking back rebuilds objects, ruleset and overlay and simulates the replay from the start again.
+func (player *Player) seek(time float64) {
+	time = mutils.ClampF(time, 0, player.mapEndL-1)
+
+	log.Println("Seeking to", formatPracticeTime(time))
+
+	from := player.progressMsF
+
+	if time < from {
+		mainthread.Call(player.rebuildReplay)
+
+		from = -1000
+	}
+
+	// Simulated on the update thread like regular playback, so drawing isn't blocked during long seeks
+	for _, o := range player.bMap.HitObjects {
+		if o.GetStartTime() > time {
+			break
+		}
+
+		o.DisableAudioSubmission(true)
+	}
+
+	player.overlay.DisableAudioSubmission(true)
+
+	for t := math.Floor(from) + 1; t <= time; t++ {
+		player.controller.Update(t, 1)
+		player.overlay.Update(t)
+	}
+
+	player.overlay.DisableAudioSubmission(false)
+
+	// Objects still in progress at target time have to be heard from there on
+	for _, o := range player.bMap.HitObjects {
+		if o.GetStartTime() > time {
+			break
+		}
+
+		if o.GetEndTime() > time && o.GetEndTime() <= player.audioEnd {
+			o.DisableAudioSubmission(false)
+		}
+	}
+
+	// Map time is ahead of music position by audio offsets
+	musicTime := time - player.getAudioOffset(player.musicPlayer.GetTempo())
+
+	player.musicPlayer.SetPosition(musicTime / 1000)
+
+	player.rawPositionF = musicTime
+	player.progressMsF = time
+}
+
+// rebuildReplay recreates objects and ruleset and resets the overlay to their state before the map started. It has to be called on the main thread.
+func (player *Player) rebuildReplay() {
+	for _, o := range player.bMap.HitObjects {
+		o.Finalize()
+	}
+
+	player.bMap.HitObjects = nil
+	beatmap.ParseObjects(player.bMap, false, false)
+
+	trimObjects(player.bMap, player.objectsStart, player.objectsEnd)
+
+	player.bMap.Reset()
+
+	for _, o := range player.bMap.HitObjects {
+		if o.GetEndTime() > player.audioEnd {
+			o.DisableAudioSubmission(true)
+		}
+	}
+
+	player.objectContainer = containers.NewHitObjectContainer(player.bMap)
+
+	controller := player.controller.(*dance.ReplayController)
+	controller.Reset()
+
+	player.overlay.(*overlays.ScoreOverlay).Reset(controller.GetRuleset(), controller.GetCursors()[0])
+
+	player.trySetupFail()
+}
@@ -0,0 +1,224 @@
+package states
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/beatmap"
+	camera2 "github.com/wieku/danser-go/app/bmath/camera"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/utils"
+	batch2 "github.com/wieku/danser-go/framework/graphics/batch"
+	"github.com/wieku/danser-go/framework/graphics/font"
+	"github.com/wieku/danser-go/framework/graphics/texture"
+	"github.com/wieku/danser-go/framework/math/animation"
+	"github.com/wieku/danser-go/framework/math/scaling"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"log"
+	"math"
+	"path/filepath"
+)
+
+const titleCardFade = 500.0
+
+// PlaylistStanding is player's cumulative result over maps already recorded in a playlist
+type PlaylistStanding struct {
+	Name     string
+	Score    int64
+	Accuracy float64 // Mean accuracy over played maps
+	Maps     int
+}
+
+// TitleCard is a transition shown between maps of a playlist.
+// It shows the next map's metadata on top of its background together with standings after previous maps.
+// Without info it's just a fade through black.
+type TitleCard struct {
+	batch  *batch2.QuadBatch
+	font   *font.Font
+	camera *camera2.Camera
+
+	bMap     *beatmap.BeatMap
+	header   string
+	playedBy string
+
+	standings []PlaylistStanding
+
+	showInfo bool
+
+	bg      *texture.TextureSingle
+	bgScale vector.Vector2d
+
+	time     float64
+	duration float64
+	fade     *animation.Glider
+
+	ScaledWidth  float64
+	ScaledHeight float64
+}
+
+// NewTitleCard creates a transition lasting duration ms, bMap can be nil for the final standings. It has to be called on the main thread.
+func NewTitleCard(bMap *beatmap.BeatMap, header, playedBy string, standings []PlaylistStanding, showInfo bool, duration float64) *TitleCard {
+	card := &TitleCard{
+		batch:     batch2.NewQuadBatch(),
+		font:      font.GetFont("Quicksand Bold"),
+		bMap:      bMap,
+		header:    header,
+		playedBy:  playedBy,
+		standings: standings,
+		showInfo:  showInfo,
+		duration:  duration,
+		fade:      animation.NewGlider(0),
+	}
+
+	card.ScaledHeight = 1080
+	card.ScaledWidth = card.ScaledHeight * settings.Graphics.GetAspectRatio()
+
+	card.camera = camera2.NewCamera()
+	card.camera.SetViewport(int(card.ScaledWidth), int(card.ScaledHeight), true)
+	card.camera.SetViewportF(0, int(card.ScaledHeight), int(card.ScaledWidth), 0)
+	card.camera.Update()
+
+	fadeTime := math.Min(titleCardFade, duration/4)
+
+	card.fade.AddEvent(0, fadeTime, 1)
+	card.fade.AddEvent(duration-fadeTime, duration, 0)
+
+	if showInfo && bMap != nil {
+		card.loadBackground()
+	}
+
+	return card
+}
+
+func (card *TitleCard) loadBackground() {
+	image, err := texture.NewPixmapFileString(filepath.Join(settings.General.GetSongsDir(), card.bMap.Dir, card.bMap.Bg))
+	if err != nil {
+		log.Println("Failed to load title card background:", err)
+		return
+	}
+
+	card.bg = texture.LoadTextureSingle(image.RGBA(), 0)
+
+	image.Dispose()
+
+	region := card.bg.GetRegion()
+
+	result := scaling.Fill.Apply(region.Width, region.Height, float32(card.ScaledWidth), float32(card.ScaledHeight))
+
+	card.bgScale = result.Mult(vector.NewVec2f(1/region.Width, 1/region.Height)).Copy64()
+}
+
+// Update advances the card by delta ms, returns true when it's finished
+func (card *TitleCard) Update(delta float64) bool {
+	card.time += delta
+	card.fade.Update(card.time)
+
+	return card.time >= card.duration
+}
+
+func (card *TitleCard) Draw(float64) {
+	alpha := card.fade.GetValue()
+
+	if !card.showInfo || alpha < 0.001 {
+		return
+	}
+
+	card.batch.Begin()
+	card.batch.SetCamera(card.camera.GetProjectionView())
+	card.batch.ResetTransform()
+
+	if card.bg != nil {
+		card.batch.SetColor(1, 1, 1, alpha*0.35)
+		card.batch.SetTranslation(vector.NewVec2d(card.ScaledWidth/2, card.ScaledHeight/2))
+		card.batch.SetScale(card.bgScale.X, card.bgScale.Y)
+		card.batch.DrawTexture(card.bg.GetRegion())
+		card.batch.ResetTransform()
+	}
+
+	left := card.ScaledWidth * 0.08
+	y := card.ScaledHeight * 0.3
+
+	drawLine := func(text string, size, lAlpha float64) {
+		card.batch.SetColor(0, 0, 0, alpha*lAlpha*0.6)
+		card.font.DrawOrigin(card.batch, left+2, y+2, vector.TopLeft, size, false, text)
+
+		card.batch.SetColor(1, 1, 1, alpha*lAlpha)
+		card.font.DrawOrigin(card.batch, left, y, vector.TopLeft, size, false, text)
+
+		y += size * 1.2
+	}
+
+	drawLine(card.header, 36, 0.7)
+
+	if card.bMap != nil {
+		y += 10
+
+		drawLine(card.bMap.Name, 72, 1)
+		drawLine(card.bMap.Artist, 44, 0.9)
+
+		y += 20
+
+		drawLine(fmt.Sprintf("[%s] mapped by %s", card.bMap.Difficulty, card.bMap.Creator), 36, 0.9)
+
+		stats := fmt.Sprintf("AR %.1f  CS %.1f  OD %.1f  HP %.1f", card.bMap.Diff.GetAR(), card.bMap.Diff.GetCS(), card.bMap.Diff.GetOD(), card.bMap.Diff.GetHP())
+		if card.bMap.Stars >= 0 {
+			stats = fmt.Sprintf("%.2f stars  %s", card.bMap.Stars, stats)
+		}
+
+		if mods := card.bMap.Diff.Mods.String(); mods != "" {
+			stats += "  +" + mods
+		}
+
+		drawLine(stats, 30, 0.8)
+
+		if card.playedBy != "" {
+			drawLine("played by "+card.playedBy, 30, 0.8)
+		}
+	}
+
+	card.drawStandings(alpha)
+
+	card.batch.End()
+}
+
+func (card *TitleCard) drawStandings(alpha float64) {
+	if len(card.standings) == 0 {
+		return
+	}
+
+	size := 30.0
+	right := card.ScaledWidth * 0.92
+	y := card.ScaledHeight * 0.3
+
+	card.batch.SetColor(1, 1, 1, alpha*0.7)
+	card.font.DrawOrigin(card.batch, right, y, vector.TopRight, 36, false, "Standings")
+
+	y += 36 * 1.4
+
+	for i, standing := range card.standings {
+		if y > card.ScaledHeight*0.9 {
+			break
+		}
+
+		card.batch.SetColor(1, 1, 1, alpha)
+		card.font.DrawOrigin(card.batch, right, y, vector.TopRight, size, true, fmt.Sprintf("%s  %.2f%%", utils.Humanize(standing.Score), standing.Accuracy))
+
+		name := fmt.Sprintf("%d. %s", i+1, standing.Name)
+		if standing.Maps > 1 {
+			name += fmt.Sprintf(" (%d maps)", standing.Maps)
+		}
+
+		card.batch.SetColor(1, 1, 1, alpha*0.8)
+		card.font.DrawOrigin(card.batch, right-card.ScaledWidth*0.22, y, vector.TopRight, size, false, name)
+
+		y += size * 1.3
+	}
+}
+
+func (card *TitleCard) Show() {}
+
+func (card *TitleCard) Hide() {}
+
+func (card *TitleCard) Dispose() {
+	if card.bg != nil {
+		card.bg.Dispose()
+	}
+}
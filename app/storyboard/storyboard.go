@@ -352,7 +352,9 @@ func (storyboard *Storyboard) getSample(sample string) (bassSample *bass.Sample)
 			return
 		}
 
-		bassSample = bass.NewSample(path)
+		if bassSample = bass.NewSample(path); bassSample != nil {
+			bassSample.SetStem(bass.StemStoryboard)
+		}
 	}
 
 	return
@@ -10,6 +10,43 @@ import (
 	"unsafe"
 )
 
+type Stem int
+
+const (
+	StemMusic Stem = iota
+	StemHitsounds
+	StemStoryboard
+)
+
+var StemNames = []string{"music", "hitsounds", "storyboard"}
+
+// Separate mixers of every stem, created only offscreen
+var stemMixers []C.HSTREAM
+
+var stemsEnabled bool
+
+func createStemMixers(flags C.DWORD) {
+	stemMixers = make([]C.HSTREAM, len(StemNames))
+
+	for i := range stemMixers {
+		stemMixers[i] = C.BASS_Mixer_StreamCreate(C.DWORD(sampleRate), 2, flags)
+		C.BASS_ChannelSetAttribute(stemMixers[i], C.BASS_ATTRIB_BUFFER, 0)
+	}
+}
+
+// SetStems routes music, hitsounds and storyboard samples played from now on to separate mixers. Works only offscreen.
+func SetStems(enabled bool) {
+	stemsEnabled = enabled && stemMixers != nil
+}
+
+func getMixer(stem Stem) C.HSTREAM {
+	if stemsEnabled {
+		return stemMixers[stem]
+	}
+
+	return masterMixer
+}
+
 func GetMixerRequiredBufferSize(seconds float64) int {
 	return int(C.BASS_ChannelSeconds2Bytes(masterMixer, C.double(seconds)))
 }
@@ -17,3 +54,20 @@ func GetMixerRequiredBufferSize(seconds float64) int {
 func ProcessMixer(buffer []byte) {
 	C.BASS_ChannelGetData(masterMixer, unsafe.Pointer(&buffer[0]), C.DWORD(len(buffer)))
 }
+
+// ProcessStems fills stems with data of separate mixers and buffer with their sum. Stems have to be enabled.
+func ProcessStems(buffer []byte, stems [][]byte) {
+	ProcessMixer(buffer) // Master mixer is still advanced, virtual tracks depend on its position
+
+	mix := unsafe.Slice((*float32)(unsafe.Pointer(&buffer[0])), len(buffer)/4)
+
+	for i, stem := range stems {
+		C.BASS_ChannelGetData(stemMixers[i], unsafe.Pointer(&stem[0]), C.DWORD(len(stem)))
+
+		samples := unsafe.Slice((*float32)(unsafe.Pointer(&stem[0])), len(stem)/4)
+
+		for j, v := range samples {
+			mix[j] += v
+		}
+	}
+}
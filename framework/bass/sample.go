@@ -22,6 +22,7 @@ type SampleChannel struct {
 
 type Sample struct {
 	bassSample C.DWORD
+	stem       Stem
 }
 
 var loopingStreams = make(map[*SampleChannel]int)
@@ -50,6 +51,7 @@ func NewSample(path string) *Sample {
 
 func NewSampleData(data []byte) *Sample {
 	sample := new(Sample)
+	sample.stem = StemHitsounds
 
 	if len(data) < 1024 { // If we have useless data, create ~10ms empty sample, simpler solution than creating a flag and checking it later
 		sample.bassSample = C.BASS_SampleCreate(1024, 44100, 2, 32, C.BASS_SAMPLE_OVER_POS)
@@ -62,6 +64,16 @@ func NewSampleData(data []byte) *Sample {
 	return sample
 }
 
+// SetStem changes the stem this sample is recorded to, samples are hitsounds by default
+func (sample *Sample) SetStem(stem Stem) {
+	sample.stem = stem
+}
+
+// Free releases sample's data, it can't be played afterwards
+func (sample *Sample) Free() {
+	C.BASS_SampleFree(sample.bassSample)
+}
+
 func (sample *Sample) GetLength() float64 {
 	return float64(C.BASS_ChannelBytes2Seconds(sample.bassSample, C.BASS_ChannelGetLength(sample.bassSample, C.BASS_POS_BYTE)))
 }
@@ -78,7 +90,7 @@ func (sample *Sample) Play() *SampleChannel {
 	if channel.channel != 0 {
 		C.BASS_ChannelSetAttribute(channel.channel, C.BASS_ATTRIB_VOL, C.float(settings.Audio.GeneralVolume*settings.Audio.SampleVolume))
 
-		C.BASS_Mixer_StreamAddChannel(masterMixer, channel.channel, C.BASS_MIXER_CHAN_NORAMPIN|C.BASS_STREAM_AUTOFREE)
+		C.BASS_Mixer_StreamAddChannel(getMixer(sample.stem), channel.channel, C.BASS_MIXER_CHAN_NORAMPIN|C.BASS_STREAM_AUTOFREE)
 	}
 
 	return channel
@@ -104,7 +116,7 @@ func (sample *Sample) PlayV(volume float64) *SampleChannel {
 	if channel.channel != 0 {
 		C.BASS_ChannelSetAttribute(channel.channel, C.BASS_ATTRIB_VOL, C.float(volume))
 
-		C.BASS_Mixer_StreamAddChannel(masterMixer, channel.channel, C.BASS_MIXER_CHAN_NORAMPIN|C.BASS_STREAM_AUTOFREE)
+		C.BASS_Mixer_StreamAddChannel(getMixer(sample.stem), channel.channel, C.BASS_MIXER_CHAN_NORAMPIN|C.BASS_STREAM_AUTOFREE)
 	}
 
 	return channel
@@ -130,7 +142,7 @@ func (sample *Sample) PlayRV(volume float64) *SampleChannel {
 	if channel.channel != 0 {
 		C.BASS_ChannelSetAttribute(channel.channel, C.BASS_ATTRIB_VOL, C.float(settings.Audio.GeneralVolume*settings.Audio.SampleVolume*volume))
 
-		C.BASS_Mixer_StreamAddChannel(masterMixer, channel.channel, C.BASS_MIXER_CHAN_NORAMPIN|C.BASS_STREAM_AUTOFREE)
+		C.BASS_Mixer_StreamAddChannel(getMixer(sample.stem), channel.channel, C.BASS_MIXER_CHAN_NORAMPIN|C.BASS_STREAM_AUTOFREE)
 	}
 
 	return channel
@@ -157,7 +169,7 @@ func (sample *Sample) PlayRVPos(volume float64, balance float64) *SampleChannel
 		C.BASS_ChannelSetAttribute(channel.channel, C.BASS_ATTRIB_VOL, C.float(settings.Audio.GeneralVolume*settings.Audio.SampleVolume*volume))
 		C.BASS_ChannelSetAttribute(channel.channel, C.BASS_ATTRIB_PAN, C.float(balance))
 
-		C.BASS_Mixer_StreamAddChannel(masterMixer, channel.channel, C.BASS_MIXER_CHAN_NORAMPIN|C.BASS_STREAM_AUTOFREE)
+		C.BASS_Mixer_StreamAddChannel(getMixer(sample.stem), channel.channel, C.BASS_MIXER_CHAN_NORAMPIN|C.BASS_STREAM_AUTOFREE)
 	}
 
 	return channel
@@ -83,6 +83,8 @@ func Init(offscreen bool) {
 
 		if !offscreen {
 			C.BASS_ChannelPlay(masterMixer, 0)
+		} else {
+			createStemMixers(C.DWORD(mixerFlags))
 		}
 	} else {
 		err := GetError()
@@ -20,6 +20,14 @@ var stack []data
 
 var current data
 
+// If true, alpha is always blended with "over" operator, so the framebuffer keeps correct coverage for compositing
+var alphaOver bool
+
+// SetAlphaOver changes how alpha is blended by SetFunction
+func SetAlphaOver(value bool) {
+	alphaOver = value
+}
+
 func Enable() {
 	if current.enabled {
 		return
@@ -41,6 +49,11 @@ func Disable() {
 }
 
 func SetFunction(src Factor, dst Factor) {
+	if alphaOver {
+		SetFunctionSeparate(src, dst, One, OneMinusSrcAlpha)
+		return
+	}
+
 	SetFunctionSeparate(src, dst, src, dst)
 }
 
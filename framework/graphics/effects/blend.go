@@ -71,7 +71,7 @@ func NewBlend(width, height, frames int, weights []float32) *Blend {
 		effect.blendShader.SetUniformArr("weights", i, v/sum)
 	}
 
-	effect.multiTexture = texture.NewTextureMultiLayerFormat(width, height, texture.RGB, 0, frames)
+	effect.multiTexture = texture.NewTextureMultiLayerFormat(width, height, texture.RGBA, 0, frames)
 
 	for i := 0; i < frames; i++ {
 		effect.fbos = append(effect.fbos, buffer.NewFrameLayer(effect.multiTexture, i))
@@ -80,10 +80,21 @@ func NewBlend(width, height, frames int, weights []float32) *Blend {
 	return effect
 }
 
+// Reset clears all frames, so they don't bleed into the next recording
+func (effect *Blend) Reset() {
+	effect.head = 0
+
+	for _, fbo := range effect.fbos {
+		fbo.Bind()
+		fbo.ClearColor(0, 0, 0, 0)
+		fbo.Unbind()
+	}
+}
+
 func (effect *Blend) Begin() {
 	effect.head = (effect.head + 1) % effect.layers
 	effect.fbos[effect.head].Bind()
-	effect.fbos[effect.head].ClearColor(0, 0, 0, 1)
+	effect.fbos[effect.head].ClearColor(0, 0, 0, 0)
 	viewport.Push(effect.width, effect.height)
 }
 
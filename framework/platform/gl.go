@@ -11,6 +11,8 @@ import (
 	"unsafe"
 )
 
+var supportedExtensions map[string]bool
+
 // SetupContext sets glfw hints about OpenGL version
 func SetupContext() {
 	glfw.WindowHint(glfw.ContextVersionMajor, 3)
@@ -23,12 +25,14 @@ func SetupContext() {
 func GLInit(debugLogs bool, additionalExtensions ...string) error {
 	log.Println("Initializing OpenGL...")
 
-	err := gl.Init()
-	if err != nil {
-		return err
+	var err error
+
+	if headless {
+		err = gl.InitWithProcAddrFunc(headlessProcAddress)
+	} else {
+		err = gl.Init()
 	}
 
-	err = extensionCheck(additionalExtensions)
 	if err != nil {
 		return err
 	}
@@ -56,8 +60,14 @@ func GLInit(debugLogs bool, additionalExtensions ...string) error {
 	var numExtensions int32
 	gl.GetIntegerv(gl.NUM_EXTENSIONS, &numExtensions)
 
+	supportedExtensions = make(map[string]bool)
+
 	for i := int32(0); i < numExtensions; i++ {
-		extensions += C.GoString((*C.char)(unsafe.Pointer(gl.GetStringi(gl.EXTENSIONS, uint32(i)))))
+		ext := C.GoString((*C.char)(unsafe.Pointer(gl.GetStringi(gl.EXTENSIONS, uint32(i)))))
+
+		supportedExtensions[ext] = true
+
+		extensions += ext
 		extensions += " "
 	}
 
@@ -66,6 +76,12 @@ func GLInit(debugLogs bool, additionalExtensions ...string) error {
 	log.Println("GL Version:   ", glVersion)
 	log.Println("GLSL Version: ", glslVersion)
 	log.Println("GL Extensions:", extensions)
+
+	err = extensionCheck(additionalExtensions)
+	if err != nil {
+		return err
+	}
+
 	log.Println("OpenGL initialized!")
 
 	if debugLogs {
@@ -87,6 +103,11 @@ func GLInit(debugLogs bool, additionalExtensions ...string) error {
 	return nil
 }
 
+// ExtensionSupported returns true if current OpenGL context supports given extension, works also without a window
+func ExtensionSupported(extension string) bool {
+	return supportedExtensions[extension]
+}
+
 func extensionCheck(additionalExtensions []string) error {
 	extensions := []string{
 		"GL_ARB_clear_texture",
@@ -103,7 +124,7 @@ func extensionCheck(additionalExtensions []string) error {
 	var notSupported []string
 
 	for _, ext := range extensions {
-		if !glfw.ExtensionSupported(ext) {
+		if !ExtensionSupported(ext) {
 			notSupported = append(notSupported, ext)
 		}
 	}
@@ -0,0 +1,244 @@
+package platform
+
+/*
+#cgo LDFLAGS: -ldl
+
+#include <stdlib.h>
+#include <dlfcn.h>
+#include <EGL/egl.h>
+#include <EGL/eglext.h>
+
+#ifndef EGL_PLATFORM_SURFACELESS_MESA
+#define EGL_PLATFORM_SURFACELESS_MESA 0x31DD
+#endif
+
+// libEGL is loaded at runtime, so danser still starts on systems without it when headless mode is not used
+typedef void* (*getProcAddressFunc)(const char*);
+typedef EGLDisplay (*getDisplayFunc)(EGLNativeDisplayType);
+typedef EGLBoolean (*initializeFunc)(EGLDisplay, EGLint*, EGLint*);
+typedef EGLBoolean (*bindAPIFunc)(EGLenum);
+typedef EGLBoolean (*chooseConfigFunc)(EGLDisplay, const EGLint*, EGLConfig*, EGLint, EGLint*);
+typedef EGLContext (*createContextFunc)(EGLDisplay, EGLConfig, EGLContext, const EGLint*);
+typedef EGLSurface (*createPbufferSurfaceFunc)(EGLDisplay, EGLConfig, const EGLint*);
+typedef EGLBoolean (*makeCurrentFunc)(EGLDisplay, EGLSurface, EGLSurface, EGLContext);
+typedef EGLBoolean (*destroySurfaceFunc)(EGLDisplay, EGLSurface);
+typedef EGLBoolean (*destroyContextFunc)(EGLDisplay, EGLContext);
+typedef EGLBoolean (*terminateFunc)(EGLDisplay);
+
+static void* libEGL = NULL;
+
+static getProcAddressFunc eglGetProcAddressPtr;
+static getDisplayFunc eglGetDisplayPtr;
+static initializeFunc eglInitializePtr;
+static bindAPIFunc eglBindAPIPtr;
+static chooseConfigFunc eglChooseConfigPtr;
+static createContextFunc eglCreateContextPtr;
+static createPbufferSurfaceFunc eglCreatePbufferSurfacePtr;
+static makeCurrentFunc eglMakeCurrentPtr;
+static destroySurfaceFunc eglDestroySurfacePtr;
+static destroyContextFunc eglDestroyContextPtr;
+static terminateFunc eglTerminatePtr;
+
+static const char* loadEGL() {
+	if (libEGL != NULL) {
+		return NULL;
+	}
+
+	void* lib = dlopen("libEGL.so.1", RTLD_NOW | RTLD_GLOBAL);
+	if (lib == NULL) {
+		lib = dlopen("libEGL.so", RTLD_NOW | RTLD_GLOBAL);
+	}
+
+	if (lib == NULL) {
+		return "libEGL is not installed";
+	}
+
+	eglGetProcAddressPtr = (getProcAddressFunc) dlsym(lib, "eglGetProcAddress");
+	eglGetDisplayPtr = (getDisplayFunc) dlsym(lib, "eglGetDisplay");
+	eglInitializePtr = (initializeFunc) dlsym(lib, "eglInitialize");
+	eglBindAPIPtr = (bindAPIFunc) dlsym(lib, "eglBindAPI");
+	eglChooseConfigPtr = (chooseConfigFunc) dlsym(lib, "eglChooseConfig");
+	eglCreateContextPtr = (createContextFunc) dlsym(lib, "eglCreateContext");
+	eglCreatePbufferSurfacePtr = (createPbufferSurfaceFunc) dlsym(lib, "eglCreatePbufferSurface");
+	eglMakeCurrentPtr = (makeCurrentFunc) dlsym(lib, "eglMakeCurrent");
+	eglDestroySurfacePtr = (destroySurfaceFunc) dlsym(lib, "eglDestroySurface");
+	eglDestroyContextPtr = (destroyContextFunc) dlsym(lib, "eglDestroyContext");
+	eglTerminatePtr = (terminateFunc) dlsym(lib, "eglTerminate");
+
+	if (eglGetProcAddressPtr == NULL || eglGetDisplayPtr == NULL || eglInitializePtr == NULL || eglBindAPIPtr == NULL ||
+		eglChooseConfigPtr == NULL || eglCreateContextPtr == NULL || eglCreatePbufferSurfacePtr == NULL || eglMakeCurrentPtr == NULL ||
+		eglDestroySurfacePtr == NULL || eglDestroyContextPtr == NULL || eglTerminatePtr == NULL) {
+		dlclose(lib);
+		return "libEGL is missing required functions";
+	}
+
+	libEGL = lib;
+
+	return NULL;
+}
+
+static EGLDisplay headlessDisplay = EGL_NO_DISPLAY;
+static EGLContext headlessContext = EGL_NO_CONTEXT;
+static EGLSurface headlessSurface = EGL_NO_SURFACE;
+
+static EGLDisplay getHeadlessDisplay() {
+	PFNEGLGETPLATFORMDISPLAYEXTPROC getPlatformDisplay = (PFNEGLGETPLATFORMDISPLAYEXTPROC) eglGetProcAddressPtr("eglGetPlatformDisplayEXT");
+
+	if (getPlatformDisplay != NULL) {
+		EGLDisplay display = getPlatformDisplay(EGL_PLATFORM_SURFACELESS_MESA, EGL_DEFAULT_DISPLAY, NULL);
+		if (display != EGL_NO_DISPLAY) {
+			return display;
+		}
+	}
+
+	return eglGetDisplayPtr(EGL_DEFAULT_DISPLAY);
+}
+
+static const char* createHeadlessContext() {
+	const char* err = loadEGL();
+	if (err != NULL) {
+		return err;
+	}
+
+	headlessDisplay = getHeadlessDisplay();
+	if (headlessDisplay == EGL_NO_DISPLAY) {
+		return "no EGL display available";
+	}
+
+	if (!eglInitializePtr(headlessDisplay, NULL, NULL)) {
+		return "failed to initialize EGL";
+	}
+
+	if (!eglBindAPIPtr(EGL_OPENGL_API)) {
+		return "EGL does not support OpenGL";
+	}
+
+	const EGLint configAttribs[] = {
+		EGL_SURFACE_TYPE, EGL_PBUFFER_BIT,
+		EGL_RENDERABLE_TYPE, EGL_OPENGL_BIT,
+		EGL_RED_SIZE, 8,
+		EGL_GREEN_SIZE, 8,
+		EGL_BLUE_SIZE, 8,
+		EGL_ALPHA_SIZE, 8,
+		EGL_NONE
+	};
+
+	EGLConfig config;
+	EGLint numConfigs = 0;
+
+	if (!eglChooseConfigPtr(headlessDisplay, configAttribs, &config, 1, &numConfigs) || numConfigs == 0) {
+		return "no suitable EGL config found";
+	}
+
+	const EGLint contextAttribs[] = {
+		EGL_CONTEXT_MAJOR_VERSION, 3,
+		EGL_CONTEXT_MINOR_VERSION, 3,
+		EGL_CONTEXT_OPENGL_PROFILE_MASK, EGL_CONTEXT_OPENGL_CORE_PROFILE_BIT,
+		EGL_CONTEXT_OPENGL_FORWARD_COMPATIBLE, EGL_TRUE,
+		EGL_NONE
+	};
+
+	headlessContext = eglCreateContextPtr(headlessDisplay, config, EGL_NO_CONTEXT, contextAttribs);
+	if (headlessContext == EGL_NO_CONTEXT) {
+		return "failed to create OpenGL 3.3 core context";
+	}
+
+	// Everything is drawn to framebuffers, the surface exists only for drivers without EGL_KHR_surfaceless_context
+	const EGLint surfaceAttribs[] = {
+		EGL_WIDTH, 1,
+		EGL_HEIGHT, 1,
+		EGL_NONE
+	};
+
+	headlessSurface = eglCreatePbufferSurfacePtr(headlessDisplay, config, surfaceAttribs);
+
+	if (!eglMakeCurrentPtr(headlessDisplay, headlessSurface, headlessSurface, headlessContext)) {
+		return "failed to make the context current";
+	}
+
+	return NULL;
+}
+
+static void destroyHeadlessContext() {
+	if (headlessDisplay == EGL_NO_DISPLAY) {
+		return;
+	}
+
+	eglMakeCurrentPtr(headlessDisplay, EGL_NO_SURFACE, EGL_NO_SURFACE, EGL_NO_CONTEXT);
+
+	if (headlessSurface != EGL_NO_SURFACE) {
+		eglDestroySurfacePtr(headlessDisplay, headlessSurface);
+	}
+
+	if (headlessContext != EGL_NO_CONTEXT) {
+		eglDestroyContextPtr(headlessDisplay, headlessContext);
+	}
+
+	eglTerminatePtr(headlessDisplay);
+
+	headlessDisplay = EGL_NO_DISPLAY;
+	headlessContext = EGL_NO_CONTEXT;
+	headlessSurface = EGL_NO_SURFACE;
+}
+
+static void* getHeadlessProcAddress(const char* name) {
+	return eglGetProcAddressPtr(name);
+}
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"unsafe"
+)
+
+var headless bool
+
+// SetSoftwareRendering forces Mesa to use llvmpipe rasterizer, has to be called before CreateHeadlessContext.
+// Variables already set by the user are kept, so e.g. LP_NUM_THREADS can still be tuned from the outside.
+func SetSoftwareRendering() {
+	setEnvDefault("LIBGL_ALWAYS_SOFTWARE", "1")
+	setEnvDefault("GALLIUM_DRIVER", "llvmpipe")
+	setEnvDefault("LP_NUM_THREADS", strconv.Itoa(runtime.NumCPU()))
+}
+
+func setEnvDefault(key, value string) {
+	if _, ok := os.LookupEnv(key); !ok {
+		_ = os.Setenv(key, value)
+	}
+}
+
+// CreateHeadlessContext creates OpenGL 3.3 core context without a window using EGL and makes it current on this thread.
+// On machines without a GPU Mesa falls back to llvmpipe, see SetSoftwareRendering.
+func CreateHeadlessContext() error {
+	if err := C.createHeadlessContext(); err != nil {
+		C.destroyHeadlessContext()
+
+		return fmt.Errorf("failed to create headless context: %s", C.GoString(err))
+	}
+
+	headless = true
+
+	return nil
+}
+
+// DestroyHeadlessContext releases the context created by CreateHeadlessContext
+func DestroyHeadlessContext() {
+	C.destroyHeadlessContext()
+
+	headless = false
+}
+
+// IsHeadless returns true if OpenGL context was created without a window
+func IsHeadless() bool {
+	return headless
+}
+
+func headlessProcAddress(name string) unsafe.Pointer {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	return C.getHeadlessProcAddress(cName)
+}
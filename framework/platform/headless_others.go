@@ -0,0 +1,28 @@
+//go:build !linux
+
+package platform
+
+import (
+	"errors"
+	"unsafe"
+)
+
+const headless = false
+
+// SetSoftwareRendering is a no-op, llvmpipe is used only on Linux
+func SetSoftwareRendering() {}
+
+// CreateHeadlessContext is supported only on Linux
+func CreateHeadlessContext() error {
+	return errors.New("headless rendering is supported only on Linux")
+}
+
+func DestroyHeadlessContext() {}
+
+func IsHeadless() bool {
+	return headless
+}
+
+func headlessProcAddress(_ string) unsafe.Pointer {
+	return nil
+}
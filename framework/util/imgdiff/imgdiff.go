@@ -0,0 +1,133 @@
+package imgdiff
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// Tolerance defines how different two images can be to still be considered equal
+type Tolerance struct {
+	// Maximum difference of a single 8-bit channel for pixels to be considered equal
+	Channel uint8
+
+	// Fraction of pixels that can differ more than Channel allows
+	Pixels float64
+}
+
+// Result describes the difference between two images
+type Result struct {
+	// Number of pixels that differ more than Tolerance.Channel allows
+	Different int
+
+	// Total number of compared pixels
+	Total int
+
+	// Largest difference of a single channel
+	MaxDifference uint8
+
+	// Image with differing pixels marked red over dimmed expected image
+	Diff *image.NRGBA
+}
+
+// Ratio returns the fraction of pixels that differ
+func (r Result) Ratio() float64 {
+	if r.Total == 0 {
+		return 0
+	}
+
+	return float64(r.Different) / float64(r.Total)
+}
+
+// Passes returns true if the difference is within tolerance
+func (r Result) Passes(tolerance Tolerance) bool {
+	return r.Ratio() <= tolerance.Pixels
+}
+
+// Compare compares images pixel by pixel, images have to have the same size
+func Compare(expected, actual image.Image, tolerance Tolerance) (Result, error) {
+	eBounds, aBounds := expected.Bounds(), actual.Bounds()
+
+	if eBounds.Dx() != aBounds.Dx() || eBounds.Dy() != aBounds.Dy() {
+		return Result{}, fmt.Errorf("image sizes differ: expected %dx%d, got %dx%d", eBounds.Dx(), eBounds.Dy(), aBounds.Dx(), aBounds.Dy())
+	}
+
+	result := Result{
+		Total: eBounds.Dx() * eBounds.Dy(),
+		Diff:  image.NewNRGBA(image.Rect(0, 0, eBounds.Dx(), eBounds.Dy())),
+	}
+
+	for y := 0; y < eBounds.Dy(); y++ {
+		for x := 0; x < eBounds.Dx(); x++ {
+			e := color.NRGBAModel.Convert(expected.At(eBounds.Min.X+x, eBounds.Min.Y+y)).(color.NRGBA)
+			a := color.NRGBAModel.Convert(actual.At(aBounds.Min.X+x, aBounds.Min.Y+y)).(color.NRGBA)
+
+			diff := max8(absDiff(e.R, a.R), absDiff(e.G, a.G), absDiff(e.B, a.B), absDiff(e.A, a.A))
+
+			if diff > result.MaxDifference {
+				result.MaxDifference = diff
+			}
+
+			if diff > tolerance.Channel {
+				result.Different++
+				result.Diff.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+			} else {
+				result.Diff.SetNRGBA(x, y, color.NRGBA{R: e.R / 4, G: e.G / 4, B: e.B / 4, A: 255})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// LoadPNG reads PNG image from given path
+func LoadPNG(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return png.Decode(file)
+}
+
+// SavePNG saves image as PNG, missing directories are created
+func SavePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err = png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+
+	return b - a
+}
+
+func max8(values ...uint8) (m uint8) {
+	for _, v := range values {
+		if v > m {
+			m = v
+		}
+	}
+
+	return
+}
@@ -0,0 +1,100 @@
+package imgdiff
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func newFilled(w, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	return img
+}
+
+func TestCompareEqual(t *testing.T) {
+	a := newFilled(4, 4, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+	b := newFilled(4, 4, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+
+	result, err := Compare(a, b, Tolerance{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Different != 0 || result.MaxDifference != 0 || result.Total != 16 {
+		t.Errorf("expected identical images, got %+v", result)
+	}
+}
+
+func TestCompareTolerance(t *testing.T) {
+	a := newFilled(4, 4, color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+	b := newFilled(4, 4, color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+
+	b.SetNRGBA(0, 0, color.NRGBA{R: 103, G: 100, B: 100, A: 255})
+	b.SetNRGBA(1, 0, color.NRGBA{R: 100, G: 150, B: 100, A: 255})
+
+	tolerance := Tolerance{Channel: 3, Pixels: 0.1}
+
+	result, err := Compare(a, b, tolerance)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Different != 1 {
+		t.Errorf("expected 1 different pixel, got %d", result.Different)
+	}
+
+	if result.MaxDifference != 50 {
+		t.Errorf("expected max difference 50, got %d", result.MaxDifference)
+	}
+
+	if !result.Passes(tolerance) {
+		t.Errorf("1/16 differing pixels should pass %.2f tolerance", tolerance.Pixels)
+	}
+
+	if result.Passes(Tolerance{Channel: 3, Pixels: 0.05}) {
+		t.Error("1/16 differing pixels should not pass 0.05 tolerance")
+	}
+
+	if c := result.Diff.NRGBAAt(1, 0); c.R != 255 || c.G != 0 {
+		t.Errorf("differing pixel should be marked red, got %v", c)
+	}
+}
+
+func TestCompareSizeMismatch(t *testing.T) {
+	_, err := Compare(newFilled(4, 4, color.NRGBA{}), newFilled(4, 5, color.NRGBA{}), Tolerance{})
+	if err == nil {
+		t.Error("expected an error for different sizes")
+	}
+}
+
+func TestPNGRoundTrip(t *testing.T) {
+	img := newFilled(3, 2, color.NRGBA{R: 1, G: 2, B: 3, A: 255})
+
+	path := filepath.Join(t.TempDir(), "sub", "img.png")
+
+	if err := SavePNG(path, img); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadPNG(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Compare(img, loaded, Tolerance{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Different != 0 {
+		t.Errorf("loaded image differs from saved one in %d pixels", result.Different)
+	}
+}
@@ -3,8 +3,53 @@ package util
 import (
 	"encoding/hex"
 	"math/rand"
+	"sync"
+	"time"
 )
 
+// Source of visual randomness, seeded with SetRandomSeed to render the same frames every time
+var random = rand.New(rand.NewSource(time.Now().UnixNano()))
+var randomMutex sync.Mutex
+
+// SetRandomSeed resets the random generator used by visual effects to a fixed state
+func SetRandomSeed(seed int64) {
+	randomMutex.Lock()
+	random.Seed(seed)
+	randomMutex.Unlock()
+}
+
+// RandomFloat64 returns a number in [0, 1) from the seedable generator
+func RandomFloat64() float64 {
+	randomMutex.Lock()
+	defer randomMutex.Unlock()
+
+	return random.Float64()
+}
+
+// RandomFloat32 returns a number in [0, 1) from the seedable generator
+func RandomFloat32() float32 {
+	randomMutex.Lock()
+	defer randomMutex.Unlock()
+
+	return random.Float32()
+}
+
+// RandomIntn returns a number in [0, n) from the seedable generator
+func RandomIntn(n int) int {
+	randomMutex.Lock()
+	defer randomMutex.Unlock()
+
+	return random.Intn(n)
+}
+
+// RandomInt returns a non-negative number from the seedable generator
+func RandomInt() int {
+	randomMutex.Lock()
+	defer randomMutex.Unlock()
+
+	return random.Int()
+}
+
 // RandomHexString creates a base16 random text with given length
 func RandomHexString(length int) string {
 	b := make([]byte, length/2+length%2)
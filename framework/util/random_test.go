@@ -0,0 +1,19 @@
+package util
+
+import "testing"
+
+func TestSetRandomSeed(t *testing.T) {
+	SetRandomSeed(727)
+
+	first := []float64{RandomFloat64(), float64(RandomFloat32()), float64(RandomIntn(100)), float64(RandomInt())}
+
+	SetRandomSeed(727)
+
+	second := []float64{RandomFloat64(), float64(RandomFloat32()), float64(RandomIntn(100)), float64(RandomInt())}
+
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("value %d differs after reseeding: %v != %v", i, first[i], second[i])
+		}
+	}
+}
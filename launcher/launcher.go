@@ -1119,6 +1119,7 @@ func (l *launcher) showSelect() {
 	if imgui.ButtonV("Select map", bSize) {
 		if l.selectWindow == nil {
 			l.selectWindow = newSongSelectPopup(l.bld, l.beatmaps)
+			l.selectWindow.watchReplay = l.trySelectReplayFromPath
 		}
 
 		l.selectWindow.open()
@@ -1850,6 +1851,7 @@ func (l *launcher) loadOSZs(names []string) {
 		l.reloadMaps(func() {
 			if l.selectWindow == nil {
 				l.selectWindow = newSongSelectPopup(l.bld, l.beatmaps)
+				l.selectWindow.watchReplay = l.trySelectReplayFromPath
 			}
 
 			if l.bld.knockoutReplays == nil && l.bld.currentReplay == nil {
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"github.com/inkyblackness/imgui-go/v4"
 	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/database"
 	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/utils"
 	"github.com/wieku/danser-go/framework/bass"
 	"github.com/wieku/danser-go/framework/graphics/texture"
 	"github.com/wieku/danser-go/framework/math/animation"
@@ -13,11 +16,13 @@ import (
 	"github.com/wieku/danser-go/framework/qpc"
 	"github.com/wieku/danser-go/framework/util"
 	"golang.org/x/exp/slices"
+	"log"
 	"math"
 	"math/rand"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -102,6 +107,13 @@ type songSelectPopup struct {
 	focusTheMap         bool
 
 	comboOpened bool
+
+	showScores bool
+	scoresMD5  string
+	scores     []*database.Score
+
+	// Called when saved replay of a score is selected to watch
+	watchReplay func(path string)
 }
 
 func newSongSelectPopup(bld *builder, beatmaps []*beatmap.BeatMap) *songSelectPopup {
@@ -212,8 +224,31 @@ func (m *songSelectPopup) drawSongSelect() {
 
 		imgui.TableNextColumn()
 
+		noMap := m.bld.currentMap == nil
+
+		if noMap {
+			imgui.PushItemFlag(imgui.ItemFlagsDisabled, true)
+		}
+
+		sName := "Scores"
+		if m.showScores {
+			sName = "Maps"
+		}
+
+		if imgui.Button(sName) {
+			m.showScores = !m.showScores
+		}
+
+		if noMap {
+			imgui.PopItemFlag()
+			m.showScores = false
+		}
+
+		imgui.SameLine()
+
 		if imgui.Button("Random") {
 			m.selectRandom()
+			m.showScores = false
 		}
 
 		imgui.EndTable()
@@ -221,6 +256,11 @@ func (m *songSelectPopup) drawSongSelect() {
 
 	imgui.PopFont()
 
+	if m.showScores {
+		m.drawScores()
+		return
+	}
+
 	csPos := imgui.CursorScreenPos()
 
 	imgui.BeginChild("##bsets")
@@ -452,6 +492,83 @@ func (m *songSelectPopup) drawSongSelect() {
 	imgui.WindowDrawList().AddLine(csPos, csPos.Plus(vec2(imgui.ContentRegionAvail().X, 0)), imgui.PackedColorFromVec4(imgui.CurrentStyle().Color(imgui.StyleColorSeparator)))
 }
 
+// drawScores shows scores set in -play mode on the selected map
+func (m *songSelectPopup) drawScores() {
+	bMap := m.bld.currentMap
+
+	if m.scoresMD5 != bMap.MD5 {
+		m.scoresMD5 = bMap.MD5
+
+		var err error
+
+		m.scores, err = database.GetScores(bMap.MD5)
+		if err != nil {
+			log.Println("Failed to load scores:", err)
+		}
+	}
+
+	imgui.PushFont(Font20)
+
+	imgui.Text(fmt.Sprintf("%s - %s [%s]", bMap.Artist, bMap.Name, bMap.Difficulty))
+
+	if len(m.scores) == 0 {
+		imgui.Text("No scores set on this map yet. Play it in \"Play\" mode to set one!")
+		imgui.PopFont()
+
+		return
+	}
+
+	if imgui.BeginTableV("scores table", 12, imgui.TableFlagsBorders|imgui.TableFlagsScrollY, vec2(-1, imgui.ContentRegionAvail().Y), -1) {
+		imgui.TableSetupScrollFreeze(0, 1)
+
+		for i, name := range []string{"Name", "Score", "Accuracy", "Combo", "Mods", "300", "100", "50", "Miss", "PP", "UR", "Date"} {
+			flags := imgui.TableColumnFlagsWidthFixed
+			if i == 0 {
+				flags = imgui.TableColumnFlagsWidthStretch
+			}
+
+			imgui.TableSetupColumnV(name, flags|imgui.TableColumnFlagsNoSort, 0, uint(i))
+		}
+
+		imgui.TableHeadersRow()
+
+		for i, score := range m.scores {
+			imgui.TableNextColumn()
+
+			imgui.Text(score.Name)
+
+			if score.Replay != "" {
+				imgui.SameLine()
+
+				if imgui.Button("Watch##"+strconv.Itoa(i)) && m.watchReplay != nil {
+					m.watchReplay(score.Replay)
+					m.opened = false
+				}
+
+				if imgui.IsItemHovered() {
+					imgui.SetTooltip(score.Replay)
+				}
+			}
+
+			textColumn(utils.Humanize(score.Score))
+			textColumn(fmt.Sprintf("%.2f%%", score.Accuracy))
+			textColumn(utils.Humanize(score.Combo) + "x")
+			textColumn(difficulty.Modifier(score.Mods).String())
+			textColumn(utils.Humanize(score.Count300))
+			textColumn(utils.Humanize(score.Count100))
+			textColumn(utils.Humanize(score.Count50))
+			textColumn(utils.Humanize(score.CountMiss))
+			textColumn(fmt.Sprintf("%.2f", score.PP))
+			textColumn(fmt.Sprintf("%.2f", score.UR))
+			textColumn(time.Unix(score.Date, 0).Format("2006-01-02 15:04"))
+		}
+
+		imgui.EndTable()
+	}
+
+	imgui.PopFont()
+}
+
 func (m *songSelectPopup) showMapTooltip(bMap *beatmap.BeatMap) {
 	imgui.PushFont(Font24)
 
@@ -658,6 +775,7 @@ func (m *songSelectPopup) search() {
 
 func (m *songSelectPopup) open() {
 	m.focusTheMap = true
+	m.scoresMD5 = "" // new scores could be set since last time
 
 	m.popup.open()
 }